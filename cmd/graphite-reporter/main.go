@@ -1,10 +1,21 @@
 package main
 
 import (
+	"os"
+
 	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/apps/graphite-reporter/app"
 )
 
 func main() {
 	cfg := app.LoadConfig()
-	app.NewReporter(cfg).Run()
+	r := app.NewReporter(cfg)
+
+	if cfg.Once {
+		if err := r.RunOnce(); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	r.Run()
 }
@@ -0,0 +1,261 @@
+// Package admin provides the HTTP endpoints for inspecting the alerts, the
+// noisy neighbour ranking and the app names, and managing silences while
+// the reporter is running.
+//
+//	GET    /status          pending and firing alerts, the silences and the ranking
+//	GET    /ranking         highest scoring apps of the last interval
+//	GET    /names           current names of every app GUID and the renames observed
+//	GET    /silences        silences that have not ended yet
+//	POST   /silences        adds the silence in the body, returns it with its ID
+//	DELETE /silences/{id}   expires a silence added at runtime
+//
+// The silence endpoints are only served with an alert engine, the ranking
+// endpoint only with a ranking and the names endpoint only with names.
+// Silences can only be added and expired when a token is configured.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/names"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/score"
+)
+
+// AlertEngine is the interface used for reading the alerts and managing the
+// silences.
+type AlertEngine interface {
+	Alerts() []alert.Alert
+	Silences() []alert.Silence
+	AddSilence(alert.Silence) (alert.Silence, error)
+	ExpireSilence(id string) error
+}
+
+// Ranker is the interface used for reading the noisy neighbour ranking.
+type Ranker interface {
+	Ranking() score.Ranking
+}
+
+// Namer is the interface used for reading the names of the app GUIDs.
+type Namer interface {
+	Mapping() names.Mapping
+}
+
+// Status is the body of the status endpoint.
+type Status struct {
+	Alerts   []alert.Alert   `json:"alerts"`
+	Silences []alert.Silence `json:"silences"`
+	Ranking  *score.Ranking  `json:"ranking,omitempty"`
+}
+
+// Handler serves the admin endpoints.
+type Handler struct {
+	engine AlertEngine
+	ranker Ranker
+	namer  Namer
+	token  string
+	logger *logging.Logger
+	mux    *http.ServeMux
+}
+
+// NewHandler returns a Handler for the alerts and silences of engine, which
+// is nil when no alert rules are configured.
+func NewHandler(engine AlertEngine, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		engine: engine,
+		logger: logging.Default(),
+		mux:    http.NewServeMux(),
+	}
+
+	for _, o := range opts {
+		o(h)
+	}
+	h.logger = h.logger.With(logging.Fields{"stage": "admin"})
+
+	h.mux.HandleFunc("/status", h.status)
+	if h.ranker != nil {
+		h.mux.HandleFunc("/ranking", h.ranking)
+	}
+	if h.namer != nil {
+		h.mux.HandleFunc("/names", h.names)
+	}
+	if h.engine != nil {
+		h.mux.HandleFunc("/silences", h.silences)
+		h.mux.HandleFunc("/silences/", h.silence)
+	}
+
+	return h
+}
+
+// ServeHTTP checks the token of the request, if one is configured, and
+// serves it. Without a token only reading requests are served.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token == "" {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusForbidden, "changes require an admin token to be configured")
+			return
+		}
+	} else {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(h.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	status := Status{
+		Alerts:   []alert.Alert{},
+		Silences: []alert.Silence{},
+	}
+	if h.engine != nil {
+		status.Alerts = h.engine.Alerts()
+		status.Silences = nonNil(h.engine.Silences())
+	}
+	if h.ranker != nil {
+		ranking := h.ranker.Ranking()
+		status.Ranking = &ranking
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *Handler) ranking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.ranker.Ranking())
+}
+
+func (h *Handler) names(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.namer.Mapping())
+}
+
+func (h *Handler) silences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, nonNil(h.engine.Silences()))
+	case http.MethodPost:
+		var s alert.Silence
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&s); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid silence: "+err.Error())
+			return
+		}
+		if s.ID != "" {
+			writeError(w, http.StatusBadRequest, "the ID of a silence is assigned")
+			return
+		}
+
+		added, err := h.engine.AddSilence(s)
+		if err != nil && added.ID == "" {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			h.logger.Error("failed to save silence", logging.Fields{
+				"silence": added.ID,
+				"error":   err,
+			})
+		}
+
+		writeJSON(w, http.StatusCreated, added)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) silence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/silences/")
+	err := h.engine.ExpireSilence(id)
+	if err == alert.ErrSilenceNotFound {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to save silences", logging.Fields{
+			"silence": id,
+			"error":   err,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func nonNil(silences []alert.Silence) []alert.Silence {
+	if silences == nil {
+		return []alert.Silence{}
+	}
+
+	return silences
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// HandlerOption is a func that is used to configure optional settings on a
+// Handler.
+type HandlerOption func(*Handler)
+
+// WithToken returns a HandlerOption for requiring the token as bearer token
+// on every request. Without it silences cannot be added or expired.
+func WithToken(token string) HandlerOption {
+	return func(h *Handler) {
+		h.token = token
+	}
+}
+
+// WithRanking returns a HandlerOption for serving the noisy neighbour
+// ranking of ranker.
+func WithRanking(ranker Ranker) HandlerOption {
+	return func(h *Handler) {
+		h.ranker = ranker
+	}
+}
+
+// WithNames returns a HandlerOption for serving the names of the app GUIDs
+// kept by namer.
+func WithNames(namer Namer) HandlerOption {
+	return func(h *Handler) {
+		h.namer = namer
+	}
+}
+
+// WithLogger returns a HandlerOption for configuring the logger used by the
+// Handler.
+func WithLogger(l *logging.Logger) HandlerOption {
+	return func(h *Handler) {
+		h.logger = l
+	}
+}
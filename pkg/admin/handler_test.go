@@ -0,0 +1,188 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/admin"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/names"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/score"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var (
+		engine  *alert.Engine
+		handler *admin.Handler
+	)
+
+	now := func() time.Time { return time.Unix(1520259600, 0) }
+
+	BeforeEach(func() {
+		var err error
+		engine, err = alert.NewEngine(
+			[]alert.Rule{{Name: "loud", Metric: alert.CountMetric, Threshold: 100}},
+			alert.WithSilences([]alert.Silence{{ID: "load-test", App: "noisy", EndsAt: time.Unix(1520263200, 0)}}),
+			alert.WithClock(now),
+			alert.WithLogger(logging.Discard()),
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		engine.Evaluate([]point.Point{{
+			Name:      point.Ingress,
+			Timestamp: 1520259540,
+			Value:     150,
+			Identity:  point.Identity{Org: "org", Space: "prod", App: "noisy", AppGUID: "n"},
+		}})
+
+		handler = admin.NewHandler(engine, admin.WithToken("secret"), admin.WithLogger(logging.Discard()))
+	})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer secret")
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	It("reports silenced alerts in the status", func() {
+		w := serve(http.MethodGet, "/status", "")
+
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var status admin.Status
+		Expect(json.Unmarshal(w.Body.Bytes(), &status)).To(Succeed())
+		Expect(status.Alerts).To(HaveLen(1))
+		Expect(status.Alerts[0].Status).To(Equal(alert.Firing))
+		Expect(status.Alerts[0].SilencedBy).To(Equal([]string{"load-test"}))
+		Expect(status.Silences).To(HaveLen(1))
+	})
+
+	It("adds, lists and expires silences", func() {
+		w := serve(http.MethodPost, "/silences", `{"space": "prod", "ends_at": "2018-03-05T16:00:00Z", "created_by": "ops", "comment": "migration"}`)
+		Expect(w.Code).To(Equal(http.StatusCreated))
+
+		var added alert.Silence
+		Expect(json.Unmarshal(w.Body.Bytes(), &added)).To(Succeed())
+		Expect(added.ID).ToNot(BeEmpty())
+		Expect(added.StartsAt.Unix()).To(Equal(int64(1520259600)))
+
+		w = serve(http.MethodGet, "/silences", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(added.ID))
+		Expect(w.Body.String()).To(ContainSubstring("load-test"))
+
+		Expect(serve(http.MethodDelete, "/silences/"+added.ID, "").Code).To(Equal(http.StatusNoContent))
+		Expect(engine.Silences()).To(HaveLen(1))
+		Expect(serve(http.MethodDelete, "/silences/"+added.ID, "").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("does not expire configured silences", func() {
+		Expect(serve(http.MethodDelete, "/silences/load-test", "").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects invalid silences", func() {
+		for _, body := range []string{
+			`{`,
+			`{"app": "noisy"}`,
+			`{"id": "mine", "ends_at": "2018-03-05T16:00:00Z"}`,
+		} {
+			w := serve(http.MethodPost, "/silences", body)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(w.Body.String()).To(ContainSubstring(`"error"`))
+		}
+	})
+
+	It("rejects unsupported methods", func() {
+		Expect(serve(http.MethodPost, "/status", "").Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(serve(http.MethodPut, "/silences", "").Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(serve(http.MethodGet, "/silences/load-test", "").Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("serves the ranking", func() {
+		Expect(serve(http.MethodGet, "/ranking", "").Code).To(Equal(http.StatusNotFound))
+
+		scorer := score.NewScorer()
+		scorer.ProcessPoints([]point.Point{{
+			Name:      point.Ingress,
+			Timestamp: 1520259540,
+			Value:     150,
+			Identity:  point.Identity{Org: "org", Space: "prod", App: "noisy", AppGUID: "n"},
+		}})
+		handler = admin.NewHandler(engine, admin.WithRanking(scorer), admin.WithLogger(logging.Discard()))
+
+		w := serve(http.MethodGet, "/ranking", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var ranking score.Ranking
+		Expect(json.Unmarshal(w.Body.Bytes(), &ranking)).To(Succeed())
+		Expect(ranking.Timestamp).To(Equal(int64(1520259540)))
+		Expect(ranking.Apps).To(HaveLen(1))
+		Expect(ranking.Apps[0].App).To(Equal("noisy"))
+		Expect(ranking.Apps[0].Rank).To(Equal(1))
+
+		var status admin.Status
+		Expect(json.Unmarshal(serve(http.MethodGet, "/status", "").Body.Bytes(), &status)).To(Succeed())
+		Expect(status.Alerts).To(HaveLen(1))
+		Expect(status.Ranking).To(Equal(&ranking))
+	})
+
+	It("serves the ranking without alert engine", func() {
+		handler = admin.NewHandler(nil, admin.WithRanking(score.NewScorer()), admin.WithLogger(logging.Discard()))
+
+		w := serve(http.MethodGet, "/status", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{"alerts": [], "silences": [], "ranking": {"timestamp": 0, "apps": []}}`))
+
+		Expect(serve(http.MethodGet, "/ranking", "").Body.String()).To(MatchJSON(`{"timestamp": 0, "apps": []}`))
+		Expect(serve(http.MethodGet, "/silences", "").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("serves the names of the app GUIDs", func() {
+		Expect(serve(http.MethodGet, "/names", "").Code).To(Equal(http.StatusNotFound))
+
+		registry := names.NewRegistry(names.WithLogger(logging.Discard()))
+		registry.ObservePoints([]point.Point{{
+			Name:      point.Ingress,
+			Timestamp: 1520259540,
+			Value:     150,
+			Identity:  point.Identity{Org: "org", Space: "prod", App: "noisy", AppGUID: "n"},
+		}})
+		handler = admin.NewHandler(nil, admin.WithNames(registry), admin.WithLogger(logging.Discard()))
+
+		w := serve(http.MethodGet, "/names", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{
+			"apps": {"n": {"org": "org", "space": "prod", "app": "noisy", "last_seen": 1520259540}},
+			"renames": {"orgs": 0, "spaces": 0, "apps": 0}
+		}`))
+	})
+
+	It("requires the token when one is configured", func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+		Expect(w.Code).To(Equal(http.StatusUnauthorized))
+
+		Expect(serve(http.MethodGet, "/status", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects changes to silences without a configured token", func() {
+		handler = admin.NewHandler(engine, admin.WithLogger(logging.Discard()))
+
+		w := serve(http.MethodPost, "/silences", `{"space": "prod", "ends_at": "2018-03-05T16:00:00Z"}`)
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		Expect(w.Body.String()).To(ContainSubstring(`"error"`))
+		Expect(engine.Silences()).To(HaveLen(1))
+
+		Expect(serve(http.MethodDelete, "/silences/load-test", "").Code).To(Equal(http.StatusForbidden))
+		Expect(serve(http.MethodGet, "/silences", "").Code).To(Equal(http.StatusOK))
+	})
+})
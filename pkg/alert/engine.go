@@ -0,0 +1,662 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Status is the state of an alert.
+type Status string
+
+// Alert states. Pending alerts are above the threshold but not for long
+// enough yet, resolved alerts are only reported on the interval they resolve.
+const (
+	Pending  Status = "pending"
+	Firing   Status = "firing"
+	Resolved Status = "resolved"
+)
+
+// Subject is what an alert is about: an app, or a single instance of it for
+// rules on the instance metric.
+type Subject struct {
+	Org     string `json:"org"`
+	Space   string `json:"space"`
+	App     string `json:"app"`
+	AppGUID string `json:"app_guid"`
+	// Index is the instance index, or -1 when the alert is about the app.
+	Index int `json:"index"`
+}
+
+func (s Subject) key() string {
+	return fmt.Sprintf("%s/%d", s.AppGUID, s.Index)
+}
+
+// Alert is the state of a rule for a subject.
+type Alert struct {
+	Rule      string  `json:"rule"`
+	Subject   Subject `json:"subject"`
+	Status    Status  `json:"status"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// Count and Share are the ingress of the subject on the last interval
+	// evaluated and its percentage of the total ingress, whatever the
+	// metric of the rule.
+	Count float64 `json:"count"`
+	Share float64 `json:"share"`
+	// Breaches counts the consecutive intervals at or above the threshold.
+	Breaches int `json:"breaches"`
+	// ActiveAt is the first interval at or above the threshold, FiredAt and
+	// ResolvedAt the intervals the alert fired and resolved on, as unix
+	// timestamps.
+	ActiveAt   int64 `json:"active_at"`
+	FiredAt    int64 `json:"fired_at,omitempty"`
+	ResolvedAt int64 `json:"resolved_at,omitempty"`
+	// SilencedBy are the IDs of the silences matching the alert when it
+	// fired or resolved, or when it was listed.
+	SilencedBy []string `json:"silenced_by,omitempty"`
+	// Suppressed is set while the notification of a firing alert is held
+	// back by a silence. Resolving an alert nobody was told about is not
+	// notified either.
+	Suppressed bool `json:"suppressed,omitempty"`
+}
+
+// Notifier is the interface used for telling someone about alerts that fired
+// or resolved.
+type Notifier interface {
+	Notify(Alert) error
+}
+
+// Engine evaluates alert rules on the points of every interval. The state of
+// pending and firing alerts is kept in memory and, when a state file is
+// configured, written to disk after every evaluation, so that a restart
+// neither fires an alert again nor forgets that it is firing.
+//
+// Silences hold back the notifications of the alerts they match. An alert
+// that fired while silenced is notified on the first interval after the
+// silence ended, if it is still firing. Silences are either configured or
+// added at runtime, the latter are kept in the state file.
+type Engine struct {
+	rules      []Rule
+	configured []Silence
+	stateFile  string
+	notifiers  []Notifier
+	now        func() time.Time
+	logger     *logging.Logger
+
+	mu       sync.Mutex
+	alerts   map[string]*Alert
+	silences map[string]Silence
+
+	saveMu sync.Mutex
+}
+
+// NewEngine returns an Engine evaluating rules. It fails when a rule is
+// invalid.
+func NewEngine(rules []Rule, opts ...EngineOption) (*Engine, error) {
+	e := &Engine{
+		alerts:   make(map[string]*Alert),
+		silences: make(map[string]Silence),
+		now:      time.Now,
+		logger:   logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(e)
+	}
+
+	names := make(map[string]bool)
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate alert rule %s", r.Name)
+		}
+		names[r.Name] = true
+
+		e.rules = append(e.rules, r)
+	}
+
+	ids := make(map[string]bool)
+	for i := range e.configured {
+		s := &e.configured[i]
+		if s.ID == "" {
+			s.ID = fmt.Sprintf("config-%d", i)
+		}
+		if ids[s.ID] {
+			return nil, fmt.Errorf("duplicate silence %s", s.ID)
+		}
+		ids[s.ID] = true
+
+		if err := s.validate(e.now()); err != nil {
+			return nil, fmt.Errorf("silence %s: %s", s.ID, err)
+		}
+	}
+
+	if e.stateFile != "" {
+		e.load(names)
+	}
+
+	return e, nil
+}
+
+// ObservePoints evaluates the rules on the points of an interval, logs the
+// alerts that fired or resolved, hands them to the notifiers and saves the
+// state.
+func (e *Engine) ObservePoints(points []point.Point) {
+	for _, a := range e.Evaluate(points) {
+		fields := logging.Fields{
+			"stage":     "alert",
+			"rule":      a.Rule,
+			"org":       a.Subject.Org,
+			"space":     a.Subject.Space,
+			"app":       a.Subject.App,
+			"app_guid":  a.Subject.AppGUID,
+			"value":     a.Value,
+			"threshold": a.Threshold,
+		}
+		if a.Subject.Index >= 0 {
+			fields["instance"] = a.Subject.Index
+		}
+		if len(a.SilencedBy) > 0 {
+			fields["silences"] = strings.Join(a.SilencedBy, ",")
+		}
+
+		switch {
+		case a.Status == Firing && a.Suppressed:
+			e.logger.Info("alert firing, silenced", fields)
+		case a.Status == Firing:
+			e.logger.Warn("alert firing", fields)
+		default:
+			e.logger.Info("alert resolved", fields)
+		}
+
+		if a.Suppressed {
+			continue
+		}
+		for _, n := range e.notifiers {
+			if err := n.Notify(a); err != nil {
+				fields["error"] = err
+				e.logger.Error("failed to notify about alert", fields)
+			}
+		}
+	}
+
+	if err := e.Save(); err != nil {
+		e.logger.Error("failed to save alert state", logging.Fields{
+			"stage": "alert",
+			"file":  e.stateFile,
+			"error": err,
+		})
+	}
+}
+
+// Evaluate updates the alerts with the points of an interval and returns
+// the alerts that fired or resolved on it, and the silenced alerts whose
+// silence ended. Only ingress and z-score points are taken into account.
+// Subjects without points count as zero.
+func (e *Engine) Evaluate(points []point.Point) []Alert {
+	var timestamp int64
+	var ingress []point.Point
+	zscores := make(map[string]float64)
+	for _, p := range points {
+		switch p.Name {
+		case point.Ingress:
+			timestamp = p.Timestamp
+			ingress = append(ingress, p)
+		case point.ZScore:
+			zscores[p.Identity.AppGUID] = p.Value
+		}
+	}
+	if len(ingress) == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changed []Alert
+	for _, r := range e.rules {
+		counts, shares, subjects := subjectCounts(r, ingress)
+
+		for _, a := range e.alerts {
+			if a.Rule != r.Name {
+				continue
+			}
+			if key := ruleKey(r.Name, a.Subject); !hasKey(subjects, key) {
+				subjects[key] = a.Subject
+			}
+		}
+
+		for key, s := range subjects {
+			m := measurement{count: counts[key], share: shares[key], zscore: zscores[s.AppGUID]}
+
+			if a, ok := e.update(r, key, s, m, timestamp); ok {
+				changed = append(changed, a)
+			}
+		}
+	}
+
+	now := e.now()
+	for i := range changed {
+		a := &changed[i]
+		a.SilencedBy = e.silencedBy(a, now)
+		if a.Status == Firing && len(a.SilencedBy) > 0 {
+			a.Suppressed = true
+			e.alerts[ruleKey(a.Rule, a.Subject)].Suppressed = true
+		}
+	}
+
+	for _, a := range e.alerts {
+		if a.Status == Firing && a.Suppressed && len(e.silencedBy(a, now)) == 0 {
+			a.Suppressed = false
+			changed = append(changed, *a)
+		}
+	}
+
+	sort.Slice(changed, func(i, j int) bool {
+		if changed[i].Rule != changed[j].Rule {
+			return changed[i].Rule < changed[j].Rule
+		}
+		return changed[i].Subject.key() < changed[j].Subject.key()
+	})
+
+	return changed
+}
+
+// measurement is the ingress of a subject on an interval.
+type measurement struct {
+	count  float64
+	share  float64
+	zscore float64
+}
+
+// update applies the measurement of a subject to its alert and returns the
+// alert when it fired or resolved.
+func (e *Engine) update(r Rule, key string, s Subject, m measurement, timestamp int64) (Alert, bool) {
+	value := m.count
+	switch r.Metric {
+	case ShareMetric:
+		value = m.share
+	case AnomalyMetric:
+		value = m.zscore
+	}
+
+	a, ok := e.alerts[key]
+	if !ok {
+		if value < r.Threshold {
+			return Alert{}, false
+		}
+
+		a = &Alert{
+			Rule:      r.Name,
+			Subject:   s,
+			Status:    Pending,
+			Threshold: r.Threshold,
+			ActiveAt:  timestamp,
+		}
+		e.alerts[key] = a
+	}
+	a.Value = value
+	a.Count = m.count
+	a.Share = m.share
+
+	switch a.Status {
+	case Pending:
+		if value < r.Threshold {
+			delete(e.alerts, key)
+			return Alert{}, false
+		}
+
+		a.Breaches++
+		if a.Breaches < r.For {
+			return Alert{}, false
+		}
+		a.Status = Firing
+		a.FiredAt = timestamp
+
+		return *a, true
+	case Firing:
+		if value >= r.Clear {
+			return Alert{}, false
+		}
+
+		delete(e.alerts, key)
+		a.Status = Resolved
+		a.ResolvedAt = timestamp
+
+		return *a, true
+	}
+
+	return Alert{}, false
+}
+
+// Close closes the notifiers that deliver in the background, so that queued
+// notifications are sent.
+func (e *Engine) Close() error {
+	var errs []string
+	for _, n := range e.notifiers {
+		c, ok := n.(interface{ Close() error })
+		if !ok {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to notify: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Alerts returns the pending and firing alerts, including the silenced
+// ones.
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		c := *a
+		c.SilencedBy = e.silencedBy(a, now)
+		alerts = append(alerts, c)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].Subject.key() < alerts[j].Subject.key()
+	})
+
+	return alerts
+}
+
+// Silences returns the silences that have not ended yet, configured or not.
+func (e *Engine) Silences() []Silence {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	var silences []Silence
+	for _, s := range e.configured {
+		if now.Before(s.EndsAt) {
+			silences = append(silences, s)
+		}
+	}
+	for _, s := range e.silences {
+		if now.Before(s.EndsAt) {
+			silences = append(silences, s)
+		}
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		if !silences[i].StartsAt.Equal(silences[j].StartsAt) {
+			return silences[i].StartsAt.Before(silences[j].StartsAt)
+		}
+		return silences[i].ID < silences[j].ID
+	})
+
+	return silences
+}
+
+// AddSilence validates the silence, assigns it an ID and saves it together
+// with the state of the alerts.
+func (e *Engine) AddSilence(s Silence) (Silence, error) {
+	e.mu.Lock()
+	if err := s.validate(e.now()); err != nil {
+		e.mu.Unlock()
+		return Silence{}, err
+	}
+	s.ID = newSilenceID()
+	e.silences[s.ID] = s
+	e.mu.Unlock()
+
+	e.logger.Info("added silence", logging.Fields{
+		"stage":      "alert",
+		"silence":    s.ID,
+		"rule":       s.Rule,
+		"org":        s.Org,
+		"space":      s.Space,
+		"app":        s.App,
+		"starts_at":  s.StartsAt.Format(time.RFC3339),
+		"ends_at":    s.EndsAt.Format(time.RFC3339),
+		"created_by": s.CreatedBy,
+	})
+
+	return s, e.Save()
+}
+
+// ExpireSilence removes a silence that was added at runtime. Configured
+// silences can only be removed from the configuration.
+func (e *Engine) ExpireSilence(id string) error {
+	e.mu.Lock()
+	if _, ok := e.silences[id]; !ok {
+		e.mu.Unlock()
+		return ErrSilenceNotFound
+	}
+	delete(e.silences, id)
+	e.mu.Unlock()
+
+	e.logger.Info("expired silence", logging.Fields{
+		"stage":   "alert",
+		"silence": id,
+	})
+
+	return e.Save()
+}
+
+// silencedBy returns the IDs of the active silences matching the alert. It
+// must be called with the lock held.
+func (e *Engine) silencedBy(a *Alert, now time.Time) []string {
+	var ids []string
+	for _, s := range e.configured {
+		if s.Active(now) && s.matches(a) {
+			ids = append(ids, s.ID)
+		}
+	}
+	for _, s := range e.silences {
+		if s.Active(now) && s.matches(a) {
+			ids = append(ids, s.ID)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Save writes the pending and firing alerts and the silences added at
+// runtime that have not ended yet to the state file, if one is configured.
+// The file is replaced atomically.
+func (e *Engine) Save() error {
+	if e.stateFile == "" {
+		return nil
+	}
+
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+
+	st := state{Alerts: e.Alerts()}
+	e.mu.Lock()
+	now := e.now()
+	for id, s := range e.silences {
+		if !now.Before(s.EndsAt) {
+			delete(e.silences, id)
+			continue
+		}
+		st.Silences = append(st.Silences, s)
+	}
+	e.mu.Unlock()
+	sort.Slice(st.Silences, func(i, j int) bool { return st.Silences[i].ID < st.Silences[j].ID })
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(e.stateFile), filepath.Base(e.stateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), e.stateFile)
+}
+
+// state is the content of the state file.
+type state struct {
+	Alerts   []Alert   `json:"alerts"`
+	Silences []Silence `json:"silences,omitempty"`
+}
+
+// load restores the alerts of known rules and the silences from the state
+// file. A missing or unreadable file starts with no alerts.
+func (e *Engine) load(rules map[string]bool) {
+	data, err := ioutil.ReadFile(e.stateFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Warn("failed to read alert state, starting without alerts", logging.Fields{
+			"file":  e.stateFile,
+			"error": err,
+		})
+		return
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		e.logger.Warn("failed to parse alert state, starting without alerts", logging.Fields{
+			"file":  e.stateFile,
+			"error": err,
+		})
+		return
+	}
+
+	for i := range s.Alerts {
+		a := s.Alerts[i]
+		if !rules[a.Rule] {
+			continue
+		}
+		a.SilencedBy = nil
+		e.alerts[ruleKey(a.Rule, a.Subject)] = &a
+	}
+
+	now := e.now()
+	for _, s := range s.Silences {
+		if now.Before(s.EndsAt) {
+			e.silences[s.ID] = s
+		}
+	}
+
+	e.logger.Info("restored alert state", logging.Fields{
+		"file":     e.stateFile,
+		"alerts":   len(e.alerts),
+		"silences": len(e.silences),
+	})
+}
+
+// subjectCounts returns the ingress of every subject matching the rule and
+// its share of the total ingress of the foundation, by key.
+func subjectCounts(r Rule, points []point.Point) (map[string]float64, map[string]float64, map[string]Subject) {
+	all := point.Shares(points)
+
+	counts := make(map[string]float64)
+	shares := make(map[string]float64)
+	subjects := make(map[string]Subject)
+	for i, p := range points {
+		s := Subject{
+			Org:     p.Identity.Org,
+			Space:   p.Identity.Space,
+			App:     p.Identity.App,
+			AppGUID: p.Identity.AppGUID,
+			Index:   -1,
+		}
+		if r.Metric == InstanceMetric {
+			s.Index = p.Identity.Index
+		}
+		if !r.matches(s) {
+			continue
+		}
+
+		key := ruleKey(r.Name, s)
+		subjects[key] = s
+		counts[key] += p.Value
+		shares[key] += all[i]
+	}
+
+	return counts, shares, subjects
+}
+
+func ruleKey(rule string, s Subject) string {
+	return rule + "/" + s.key()
+}
+
+func hasKey(subjects map[string]Subject, key string) bool {
+	_, ok := subjects[key]
+	return ok
+}
+
+// EngineOption is a func that is used to configure optional settings on an
+// Engine.
+type EngineOption func(*Engine)
+
+// WithStateFile returns an EngineOption for configuring the file the state
+// of the alerts is kept in across restarts.
+func WithStateFile(file string) EngineOption {
+	return func(e *Engine) {
+		e.stateFile = file
+	}
+}
+
+// WithSilences returns an EngineOption for configuring silences. Silences
+// without an ID are numbered in order.
+func WithSilences(silences []Silence) EngineOption {
+	return func(e *Engine) {
+		e.configured = append(e.configured, silences...)
+	}
+}
+
+// WithClock returns an EngineOption for configuring the clock silences are
+// checked with.
+func WithClock(now func() time.Time) EngineOption {
+	return func(e *Engine) {
+		e.now = now
+	}
+}
+
+// WithNotifier returns an EngineOption for adding a Notifier that is told
+// about every alert that fires or resolves.
+func WithNotifier(n Notifier) EngineOption {
+	return func(e *Engine) {
+		e.notifiers = append(e.notifiers, n)
+	}
+}
+
+// WithLogger returns an EngineOption for configuring the logger used by the
+// Engine.
+func WithLogger(l *logging.Logger) EngineOption {
+	return func(e *Engine) {
+		e.logger = l
+	}
+}
@@ -0,0 +1,394 @@
+package alert_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Engine", func() {
+	var (
+		noisy = point.Identity{Org: "org", Space: "prod", App: "noisy", AppGUID: "n"}
+		quiet = point.Identity{Org: "org", Space: "prod", App: "quiet", AppGUID: "q"}
+	)
+
+	at := func(ts int64, identity point.Identity, index int, value float64) point.Point {
+		identity.Index = index
+		return point.Point{Name: point.Ingress, Timestamp: ts, Value: value, Identity: identity}
+	}
+
+	newEngine := func(rules []alert.Rule, opts ...alert.EngineOption) *alert.Engine {
+		e, err := alert.NewEngine(rules, append(opts, alert.WithLogger(logging.Discard()))...)
+		Expect(err).ToNot(HaveOccurred())
+		return e
+	}
+
+	statuses := func(alerts []alert.Alert) []alert.Status {
+		var s []alert.Status
+		for _, a := range alerts {
+			s = append(s, a.Status)
+		}
+		return s
+	}
+
+	It("fires once the count of an app has been above the threshold for the configured intervals", func() {
+		e := newEngine([]alert.Rule{{Name: "loud", Metric: alert.CountMetric, Threshold: 100, For: 2}})
+
+		Expect(e.Evaluate([]point.Point{at(60, noisy, 0, 60), at(60, noisy, 1, 60), at(60, quiet, 0, 10)})).To(BeEmpty())
+		Expect(e.Alerts()).To(HaveLen(1))
+		Expect(e.Alerts()[0].Status).To(Equal(alert.Pending))
+
+		fired := e.Evaluate([]point.Point{at(120, noisy, 0, 120)})
+		Expect(fired).To(Equal([]alert.Alert{{
+			Rule:      "loud",
+			Subject:   alert.Subject{Org: "org", Space: "prod", App: "noisy", AppGUID: "n", Index: -1},
+			Status:    alert.Firing,
+			Value:     120,
+			Threshold: 100,
+			Count:     120,
+			Share:     100,
+			Breaches:  2,
+			ActiveAt:  60,
+			FiredAt:   120,
+		}}))
+	})
+
+	It("forgets a pending alert that drops below the threshold", func() {
+		e := newEngine([]alert.Rule{{Name: "loud", Metric: alert.CountMetric, Threshold: 100, For: 2}})
+
+		e.Evaluate([]point.Point{at(60, noisy, 0, 150)})
+		e.Evaluate([]point.Point{at(120, noisy, 0, 50)})
+		Expect(e.Alerts()).To(BeEmpty())
+
+		Expect(e.Evaluate([]point.Point{at(180, noisy, 0, 150)})).To(BeEmpty())
+	})
+
+	It("only resolves once the value drops below the clear threshold", func() {
+		e := newEngine([]alert.Rule{{Name: "loud", Metric: alert.CountMetric, Threshold: 100, Clear: 50}})
+
+		Expect(statuses(e.Evaluate([]point.Point{at(60, noisy, 0, 150)}))).To(Equal([]alert.Status{alert.Firing}))
+		Expect(e.Evaluate([]point.Point{at(120, noisy, 0, 80)})).To(BeEmpty())
+		Expect(e.Evaluate([]point.Point{at(180, noisy, 0, 120)})).To(BeEmpty())
+
+		resolved := e.Evaluate([]point.Point{at(240, noisy, 0, 40)})
+		Expect(statuses(resolved)).To(Equal([]alert.Status{alert.Resolved}))
+		Expect(resolved[0].FiredAt).To(Equal(int64(60)))
+		Expect(resolved[0].ResolvedAt).To(Equal(int64(240)))
+		Expect(e.Alerts()).To(BeEmpty())
+	})
+
+	It("resolves an alert whose app is no longer reported", func() {
+		e := newEngine([]alert.Rule{{Name: "loud", Metric: alert.CountMetric, Threshold: 100}})
+
+		e.Evaluate([]point.Point{at(60, noisy, 0, 150)})
+		resolved := e.Evaluate([]point.Point{at(120, quiet, 0, 10)})
+
+		Expect(statuses(resolved)).To(Equal([]alert.Status{alert.Resolved}))
+		Expect(resolved[0].Value).To(BeZero())
+	})
+
+	It("compares the share of the total ingress", func() {
+		e := newEngine([]alert.Rule{{Name: "dominant", Metric: alert.ShareMetric, Threshold: 50}})
+
+		fired := e.Evaluate([]point.Point{at(60, noisy, 0, 30), at(60, noisy, 1, 40), at(60, quiet, 0, 30)})
+
+		Expect(fired).To(HaveLen(1))
+		Expect(fired[0].Subject.App).To(Equal("noisy"))
+		Expect(fired[0].Value).To(BeNumerically("~", 70, 1e-9))
+		Expect(fired[0].Count).To(Equal(70.0))
+		Expect(fired[0].Share).To(Equal(fired[0].Value))
+	})
+
+	It("evaluates every instance on its own", func() {
+		e := newEngine([]alert.Rule{{Name: "hot-instance", Metric: alert.InstanceMetric, Threshold: 50}})
+
+		fired := e.Evaluate([]point.Point{at(60, noisy, 0, 40), at(60, noisy, 1, 60)})
+
+		Expect(fired).To(HaveLen(1))
+		Expect(fired[0].Subject.Index).To(Equal(1))
+	})
+
+	It("compares the deviation of an app from its baseline", func() {
+		e := newEngine([]alert.Rule{{Name: "anomalous", Metric: alert.AnomalyMetric, Threshold: 3, Clear: 1}})
+
+		zscore := func(ts int64, identity point.Identity, value float64) point.Point {
+			identity.Index = -1
+			return point.Point{Name: point.ZScore, Timestamp: ts, Value: value, Identity: identity}
+		}
+
+		Expect(e.Evaluate([]point.Point{at(60, noisy, 0, 10), at(60, quiet, 0, 1000)})).To(BeEmpty())
+
+		fired := e.Evaluate([]point.Point{at(120, noisy, 0, 500), at(120, quiet, 0, 1000), zscore(120, noisy, 4.2), zscore(120, quiet, 0.1)})
+		Expect(fired).To(HaveLen(1))
+		Expect(fired[0].Subject.App).To(Equal("noisy"))
+		Expect(fired[0].Subject.Index).To(Equal(-1))
+		Expect(fired[0].Value).To(Equal(4.2))
+		Expect(fired[0].Count).To(Equal(500.0))
+
+		Expect(statuses(e.Evaluate([]point.Point{at(180, noisy, 0, 20), zscore(180, noisy, 0.5)}))).To(Equal([]alert.Status{alert.Resolved}))
+	})
+
+	It("only evaluates apps matching the patterns of the rule", func() {
+		e := newEngine([]alert.Rule{{Name: "loud", Space: "prod", App: "qu*", Metric: alert.CountMetric, Threshold: 10}})
+
+		fired := e.Evaluate([]point.Point{at(60, noisy, 0, 100), at(60, quiet, 0, 100)})
+
+		Expect(fired).To(HaveLen(1))
+		Expect(fired[0].Subject.App).To(Equal("quiet"))
+	})
+
+	It("ignores points other than ingress", func() {
+		e := newEngine([]alert.Rule{{Name: "loud", Metric: alert.CountMetric, Threshold: 10}})
+
+		p := at(60, noisy, 0, 100)
+		p.Name = "other"
+
+		Expect(e.Evaluate([]point.Point{p})).To(BeEmpty())
+	})
+
+	It("rejects invalid rules", func() {
+		for _, rules := range [][]alert.Rule{
+			{{Metric: alert.CountMetric, Threshold: 1}},
+			{{Name: "a", Metric: "bytes", Threshold: 1}},
+			{{Name: "a", Metric: alert.CountMetric, Threshold: 1, App: "["}},
+			{{Name: "a", Metric: alert.CountMetric, Threshold: 1, Clear: 2}},
+			{{Name: "a", Metric: alert.CountMetric, Threshold: 1}, {Name: "a", Metric: alert.CountMetric, Threshold: 2}},
+		} {
+			_, err := alert.NewEngine(rules, alert.WithLogger(logging.Discard()))
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	Context("with silences", func() {
+		var (
+			now      time.Time
+			notifier *spyNotifier
+			rules    = []alert.Rule{{Name: "loud", Metric: alert.CountMetric, Threshold: 100}}
+		)
+
+		clock := func() time.Time { return now }
+
+		BeforeEach(func() {
+			now = time.Unix(1000, 0)
+			notifier = &spyNotifier{}
+		})
+
+		maintenance := alert.Silence{
+			ID:       "maintenance",
+			Space:    "prod",
+			App:      "noi*",
+			StartsAt: time.Unix(900, 0),
+			EndsAt:   time.Unix(2000, 0),
+		}
+
+		It("holds back the notification of a silenced alert but still reports it", func() {
+			e := newEngine(rules,
+				alert.WithSilences([]alert.Silence{maintenance}),
+				alert.WithNotifier(notifier),
+				alert.WithClock(clock),
+			)
+
+			e.ObservePoints([]point.Point{at(60, noisy, 0, 150), at(60, quiet, 0, 150)})
+
+			Expect(notifier.alerts).To(HaveLen(1))
+			Expect(notifier.alerts[0].Subject.App).To(Equal("quiet"))
+
+			Expect(e.Alerts()).To(HaveLen(2))
+			Expect(e.Alerts()[0].Subject.App).To(Equal("noisy"))
+			Expect(e.Alerts()[0].SilencedBy).To(Equal([]string{"maintenance"}))
+			Expect(e.Alerts()[1].SilencedBy).To(BeEmpty())
+		})
+
+		It("does not notify about resolving an alert nobody was told about", func() {
+			e := newEngine(rules,
+				alert.WithSilences([]alert.Silence{maintenance}),
+				alert.WithNotifier(notifier),
+				alert.WithClock(clock),
+			)
+
+			e.ObservePoints([]point.Point{at(60, noisy, 0, 150)})
+			now = time.Unix(3000, 0)
+			e.ObservePoints([]point.Point{at(120, noisy, 0, 10)})
+
+			Expect(notifier.alerts).To(BeEmpty())
+		})
+
+		It("notifies about an alert still firing once its silence ended", func() {
+			e := newEngine(rules,
+				alert.WithSilences([]alert.Silence{maintenance}),
+				alert.WithNotifier(notifier),
+				alert.WithClock(clock),
+			)
+
+			e.ObservePoints([]point.Point{at(60, noisy, 0, 150)})
+			e.ObservePoints([]point.Point{at(120, noisy, 0, 150)})
+			Expect(notifier.alerts).To(BeEmpty())
+
+			now = time.Unix(2000, 0)
+			e.ObservePoints([]point.Point{at(180, noisy, 0, 150)})
+			Expect(notifier.alerts).To(HaveLen(1))
+			Expect(notifier.alerts[0].Status).To(Equal(alert.Firing))
+			Expect(notifier.alerts[0].FiredAt).To(Equal(int64(60)))
+
+			e.ObservePoints([]point.Point{at(240, noisy, 0, 10)})
+			Expect(statuses(notifier.alerts)).To(Equal([]alert.Status{alert.Firing, alert.Resolved}))
+		})
+
+		It("adds and expires silences at runtime", func() {
+			e := newEngine(rules, alert.WithNotifier(notifier), alert.WithClock(clock))
+
+			s, err := e.AddSilence(alert.Silence{App: "noisy", EndsAt: time.Unix(1600, 0), Comment: "load test"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.ID).ToNot(BeEmpty())
+			Expect(s.StartsAt).To(Equal(now))
+			Expect(e.Silences()).To(Equal([]alert.Silence{s}))
+
+			e.ObservePoints([]point.Point{at(60, noisy, 0, 150)})
+			Expect(notifier.alerts).To(BeEmpty())
+
+			Expect(e.ExpireSilence(s.ID)).To(Succeed())
+			Expect(e.Silences()).To(BeEmpty())
+			Expect(e.ExpireSilence(s.ID)).To(Equal(alert.ErrSilenceNotFound))
+
+			e.ObservePoints([]point.Point{at(120, noisy, 0, 150)})
+			Expect(notifier.alerts).To(HaveLen(1))
+		})
+
+		It("does not list silences that ended", func() {
+			e := newEngine(rules, alert.WithSilences([]alert.Silence{maintenance}), alert.WithClock(clock))
+			Expect(e.Silences()).To(HaveLen(1))
+
+			now = time.Unix(2000, 0)
+			Expect(e.Silences()).To(BeEmpty())
+		})
+
+		It("rejects invalid silences", func() {
+			e := newEngine(rules, alert.WithClock(clock))
+
+			for _, s := range []alert.Silence{
+				{App: "noisy"},
+				{App: "[", EndsAt: time.Unix(2000, 0)},
+				{StartsAt: time.Unix(2000, 0), EndsAt: time.Unix(1500, 0)},
+			} {
+				_, err := e.AddSilence(s)
+				Expect(err).To(HaveOccurred())
+			}
+
+			_, err := alert.NewEngine(rules, alert.WithSilences([]alert.Silence{{App: "noisy"}}), alert.WithLogger(logging.Discard()))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with a state file", func() {
+		var (
+			dir   string
+			file  string
+			rules = []alert.Rule{{Name: "loud", Metric: alert.CountMetric, Threshold: 100, For: 2}}
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "alert")
+			Expect(err).ToNot(HaveOccurred())
+			file = filepath.Join(dir, "state.json")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("keeps the alerts across restarts", func() {
+			e := newEngine(rules, alert.WithStateFile(file))
+			e.ObservePoints([]point.Point{at(60, noisy, 0, 150)})
+			e.ObservePoints([]point.Point{at(120, noisy, 0, 150)})
+			Expect(e.Alerts()[0].Status).To(Equal(alert.Firing))
+
+			restarted := newEngine(rules, alert.WithStateFile(file))
+			Expect(restarted.Alerts()).To(Equal(e.Alerts()))
+			Expect(restarted.Evaluate([]point.Point{at(180, noisy, 0, 150)})).To(BeEmpty())
+			Expect(statuses(restarted.Evaluate([]point.Point{at(240, noisy, 0, 0)}))).To(Equal([]alert.Status{alert.Resolved}))
+		})
+
+		It("keeps the silences added at runtime across restarts", func() {
+			e := newEngine(rules, alert.WithStateFile(file))
+			s, err := e.AddSilence(alert.Silence{App: "noisy", EndsAt: time.Now().Add(time.Hour)})
+			Expect(err).ToNot(HaveOccurred())
+
+			restarted := newEngine(rules, alert.WithStateFile(file))
+			Expect(restarted.Silences()).To(HaveLen(1))
+			Expect(restarted.Silences()[0].ID).To(Equal(s.ID))
+			Expect(restarted.Silences()[0].EndsAt.Equal(s.EndsAt)).To(BeTrue())
+		})
+
+		It("drops the alerts of rules that no longer exist", func() {
+			e := newEngine(rules, alert.WithStateFile(file))
+			e.ObservePoints([]point.Point{at(60, noisy, 0, 150)})
+
+			restarted := newEngine([]alert.Rule{{Name: "other", Metric: alert.CountMetric, Threshold: 1}}, alert.WithStateFile(file))
+			Expect(restarted.Alerts()).To(BeEmpty())
+		})
+
+		It("starts without alerts when the state file is corrupt", func() {
+			Expect(ioutil.WriteFile(file, []byte("{"), 0644)).To(Succeed())
+
+			e := newEngine(rules, alert.WithStateFile(file))
+			Expect(e.Alerts()).To(BeEmpty())
+		})
+	})
+
+	It("loads silences from a JSON file", func() {
+		f, err := ioutil.TempFile("", "silences")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(f.Name())
+
+		_, err = f.WriteString(`{"silences": [{"id": "migration", "org": "org", "starts_at": "2018-03-05T14:00:00Z", "ends_at": "2018-03-05T18:00:00Z", "comment": "db migration"}]}`)
+		Expect(err).ToNot(HaveOccurred())
+		f.Close()
+
+		silences, err := alert.LoadSilences(f.Name())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(silences).To(HaveLen(1))
+		Expect(silences[0].ID).To(Equal("migration"))
+		Expect(silences[0].Org).To(Equal("org"))
+		Expect(silences[0].StartsAt.Unix()).To(Equal(int64(1520258400)))
+		Expect(silences[0].EndsAt.Unix()).To(Equal(int64(1520272800)))
+		Expect(silences[0].Comment).To(Equal("db migration"))
+	})
+
+	It("loads rules from a JSON file", func() {
+		f, err := ioutil.TempFile("", "rules")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(f.Name())
+
+		_, err = f.WriteString(`{"rules": [{"name": "loud", "space": "prod", "metric": "share", "threshold": 30, "clear": 20, "for": 3}]}`)
+		Expect(err).ToNot(HaveOccurred())
+		f.Close()
+
+		rules, err := alert.LoadRules(f.Name())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(Equal([]alert.Rule{{
+			Name:      "loud",
+			Space:     "prod",
+			Metric:    alert.ShareMetric,
+			Threshold: 30,
+			Clear:     20,
+			For:       3,
+		}}))
+	})
+})
+
+type spyNotifier struct {
+	alerts []alert.Alert
+}
+
+func (s *spyNotifier) Notify(a alert.Alert) error {
+	s.alerts = append(s.alerts, a)
+	return nil
+}
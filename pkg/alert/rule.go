@@ -0,0 +1,129 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+)
+
+// Metric is the value of a subject a rule compares with its thresholds.
+type Metric string
+
+// Supported metrics.
+const (
+	// CountMetric is the ingress of an app summed over its instances.
+	CountMetric Metric = "count"
+	// ShareMetric is the ingress of an app as a percentage of the total
+	// ingress of the foundation.
+	ShareMetric Metric = "share"
+	// InstanceMetric is the ingress of a single app instance.
+	InstanceMetric Metric = "instance"
+	// AnomalyMetric is the deviation of the ingress of an app from its own
+	// baseline in standard deviations, as reported by z-score points. Apps
+	// without a z-score, e.g. while their baseline warms up, count as zero.
+	AnomalyMetric Metric = "anomaly"
+)
+
+// Rule describes when an app becomes a noisy neighbor.
+//
+// A rule fires once its metric has been at or above Threshold for For
+// consecutive intervals, and resolves once it drops below Clear. Keeping
+// Clear below Threshold stops an app hovering around the threshold from
+// firing and resolving on every interval.
+type Rule struct {
+	Name string `json:"name"`
+
+	// Org, Space and App are shell patterns as understood by path.Match the
+	// names of the subject have to match. Empty patterns match everything.
+	Org   string `json:"org,omitempty"`
+	Space string `json:"space,omitempty"`
+	App   string `json:"app,omitempty"`
+
+	Metric    Metric  `json:"metric"`
+	Threshold float64 `json:"threshold"`
+	// Clear defaults to Threshold.
+	Clear float64 `json:"clear,omitempty"`
+	// For defaults to 1, firing on the first interval above the threshold.
+	For int `json:"for,omitempty"`
+}
+
+// LoadRules reads the rules from a JSON file of the form
+// {"rules": [{"name": "...", ...}]}.
+func LoadRules(file string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var f struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules in %s: %s", file, err)
+	}
+
+	return f.Rules, nil
+}
+
+// LoadSilences reads the silences from a JSON file of the form
+// {"silences": [{"app": "...", "ends_at": "2018-03-05T18:00:00Z", ...}]}.
+func LoadSilences(file string) ([]Silence, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var f struct {
+		Silences []Silence `json:"silences"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse silences in %s: %s", file, err)
+	}
+
+	return f.Silences, nil
+}
+
+// validate checks the rule and fills in its defaults.
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("alert rule without a name")
+	}
+
+	switch r.Metric {
+	case CountMetric, ShareMetric, InstanceMetric, AnomalyMetric:
+	default:
+		return fmt.Errorf("alert rule %s has unknown metric %q", r.Name, r.Metric)
+	}
+
+	for _, pattern := range []string{r.Org, r.Space, r.App} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("alert rule %s has invalid pattern %q", r.Name, pattern)
+		}
+	}
+
+	if r.Clear == 0 {
+		r.Clear = r.Threshold
+	}
+	if r.Clear > r.Threshold {
+		return fmt.Errorf("alert rule %s clears at %v, above its threshold %v", r.Name, r.Clear, r.Threshold)
+	}
+	if r.For < 1 {
+		r.For = 1
+	}
+
+	return nil
+}
+
+func (r Rule) matches(s Subject) bool {
+	return match(r.Org, s.Org) && match(r.Space, s.Space) && match(r.App, s.App)
+}
+
+func match(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
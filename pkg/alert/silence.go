@@ -0,0 +1,77 @@
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+)
+
+// ErrSilenceNotFound is returned when expiring a silence that does not exist
+// or cannot be expired.
+var ErrSilenceNotFound = errors.New("silence not found")
+
+// Silence suppresses the notifications of the alerts of matching subjects
+// between StartsAt and EndsAt, e.g. during a load test or a planned
+// migration. Silenced alerts are still evaluated and reported as silenced.
+type Silence struct {
+	ID string `json:"id"`
+
+	// Rule, Org, Space and App are shell patterns as understood by
+	// path.Match the rule and the names of the subject have to match. Empty
+	// patterns match everything.
+	Rule  string `json:"rule,omitempty"`
+	Org   string `json:"org,omitempty"`
+	Space string `json:"space,omitempty"`
+	App   string `json:"app,omitempty"`
+
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
+// Active reports whether the silence applies at t.
+func (s Silence) Active(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+func (s Silence) matches(a *Alert) bool {
+	return match(s.Rule, a.Rule) &&
+		match(s.Org, a.Subject.Org) &&
+		match(s.Space, a.Subject.Space) &&
+		match(s.App, a.Subject.App)
+}
+
+// validate checks the silence. A silence without a start time starts at
+// now.
+func (s *Silence) validate(now time.Time) error {
+	for _, pattern := range []string{s.Rule, s.Org, s.Space, s.App} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("silence has invalid pattern %q", pattern)
+		}
+	}
+
+	if s.StartsAt.IsZero() {
+		s.StartsAt = now
+	}
+	if s.EndsAt.IsZero() {
+		return fmt.Errorf("silence without an end time")
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("silence ends at %s, before it starts", s.EndsAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func newSilenceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
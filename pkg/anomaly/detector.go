@@ -0,0 +1,351 @@
+// Package anomaly keeps a baseline of the ingress of every app and reports
+// how far the ingress of an interval deviates from it, so that apps whose
+// normal log volume differs by orders of magnitude can be judged by the
+// same threshold.
+package anomaly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// minStdDev is the smallest standard deviation z-scores are computed with.
+// Counts change by whole envelopes, so an app with a perfectly steady
+// ingress deviates by one standard deviation per envelope.
+const minStdDev = 1.0
+
+// Baseline is the exponentially weighted moving mean and variance of the
+// ingress of an app.
+type Baseline struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Samples  int     `json:"samples"`
+	// LastSeen is the last interval the baseline was updated on, as a unix
+	// timestamp.
+	LastSeen int64 `json:"last_seen"`
+}
+
+// ZScore returns the deviation of x from the baseline in standard
+// deviations.
+func (b *Baseline) ZScore(x float64) float64 {
+	return (x - b.Mean) / math.Max(math.Sqrt(b.Variance), minStdDev)
+}
+
+// Update adds x to the baseline, weighting it by alpha.
+func (b *Baseline) Update(x, alpha float64) {
+	if b.Samples == 0 {
+		b.Mean = x
+	} else {
+		diff := x - b.Mean
+		incr := alpha * diff
+		b.Mean += incr
+		b.Variance = (1 - alpha) * (b.Variance + diff*incr)
+	}
+	b.Samples++
+}
+
+// Detector adds a z-score point per app to the points of every interval,
+// comparing the ingress of the app, summed over its instances, with its
+// baseline before updating the baseline with it.
+//
+// Seasonal baselines are kept per hour of the week in UTC, so that an app
+// busy during office hours is compared with its usual office hours. No
+// z-score is reported until a baseline has seen the warmup number of
+// intervals, and baselines of apps that have not been reported for the
+// retention period are forgotten. Apps without ingress on an interval
+// leave their baseline untouched.
+type Detector struct {
+	alpha     float64
+	seasonal  bool
+	warmup    int
+	retention time.Duration
+	stateFile string
+	logger    *logging.Logger
+
+	mu        sync.Mutex
+	baselines map[string]*Baseline
+}
+
+// NewDetector returns a Detector, restoring the baselines from the state
+// file if one is configured.
+func NewDetector(opts ...DetectorOption) *Detector {
+	d := &Detector{
+		alpha:     0.05,
+		warmup:    30,
+		retention: 14 * 24 * time.Hour,
+		logger:    logging.Default(),
+		baselines: make(map[string]*Baseline),
+	}
+
+	for _, o := range opts {
+		o(d)
+	}
+
+	if d.stateFile != "" {
+		d.load()
+	}
+
+	return d
+}
+
+// app is the ingress of an app on an interval.
+type app struct {
+	identity  point.Identity
+	labels    map[string]string
+	timestamp int64
+	count     float64
+}
+
+// ProcessPoints returns the points with a z-score point for every app whose
+// baseline has warmed up, and saves the baselines.
+func (d *Detector) ProcessPoints(points []point.Point) []point.Point {
+	apps := make(map[string]*app)
+	var guids []string
+	for _, p := range points {
+		if p.Name != point.Ingress {
+			continue
+		}
+
+		a, ok := apps[p.Identity.AppGUID]
+		if !ok {
+			identity := p.Identity
+			identity.Index = -1
+			a = &app{identity: identity, labels: p.Labels, timestamp: p.Timestamp}
+			apps[p.Identity.AppGUID] = a
+			guids = append(guids, p.Identity.AppGUID)
+		}
+		a.count += p.Value
+	}
+	if len(apps) == 0 {
+		return points
+	}
+	sort.Strings(guids)
+
+	out := make([]point.Point, len(points), len(points)+len(apps))
+	copy(out, points)
+
+	d.mu.Lock()
+	var timestamp int64
+	for _, guid := range guids {
+		a := apps[guid]
+		timestamp = a.timestamp
+
+		key := d.key(guid, a.timestamp)
+		b, ok := d.baselines[key]
+		if !ok {
+			b = &Baseline{}
+			d.baselines[key] = b
+		}
+
+		if b.Samples >= d.warmup {
+			out = append(out, point.Point{
+				Name:      point.ZScore,
+				Timestamp: a.timestamp,
+				Value:     b.ZScore(a.count),
+				Identity:  a.identity,
+				Labels:    a.labels,
+			})
+		}
+
+		b.Update(a.count, d.alpha)
+		b.LastSeen = a.timestamp
+	}
+	d.prune(timestamp)
+	d.mu.Unlock()
+
+	if err := d.Save(); err != nil {
+		d.logger.Error("failed to save baselines", logging.Fields{
+			"stage": "anomaly",
+			"file":  d.stateFile,
+			"error": err,
+		})
+	}
+
+	return out
+}
+
+// Baseline returns a copy of the baseline the ingress of an app at
+// timestamp is compared with.
+func (d *Detector) Baseline(guid string, timestamp int64) (Baseline, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, ok := d.baselines[d.key(guid, timestamp)]
+	if !ok {
+		return Baseline{}, false
+	}
+
+	return *b, true
+}
+
+func (d *Detector) key(guid string, timestamp int64) string {
+	if !d.seasonal {
+		return guid
+	}
+
+	return guid + "/" + strconv.Itoa(hourOfWeek(timestamp))
+}
+
+// hourOfWeek returns the hour of the week of a unix timestamp in UTC,
+// starting on Sunday.
+func hourOfWeek(timestamp int64) int {
+	t := time.Unix(timestamp, 0).UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// prune forgets the baselines not updated within the retention period
+// before timestamp. It must be called with the lock held.
+func (d *Detector) prune(timestamp int64) {
+	oldest := timestamp - int64(d.retention/time.Second)
+	for key, b := range d.baselines {
+		if b.LastSeen < oldest {
+			delete(d.baselines, key)
+		}
+	}
+}
+
+// state is the content of the state file.
+type state struct {
+	Seasonal  bool                 `json:"seasonal"`
+	Baselines map[string]*Baseline `json:"baselines"`
+}
+
+// Save writes the baselines to the state file, if one is configured. The
+// file is replaced atomically.
+func (d *Detector) Save() error {
+	if d.stateFile == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	data, err := json.Marshal(state{Seasonal: d.seasonal, Baselines: d.baselines})
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(d.stateFile), filepath.Base(d.stateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), d.stateFile)
+}
+
+// load restores the baselines from the state file. A missing or unreadable
+// file, or one written with a different seasonality, starts without
+// baselines.
+func (d *Detector) load() {
+	data, err := ioutil.ReadFile(d.stateFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		d.logger.Warn("failed to read baselines, starting without baselines", logging.Fields{
+			"file":  d.stateFile,
+			"error": err,
+		})
+		return
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		d.logger.Warn("failed to parse baselines, starting without baselines", logging.Fields{
+			"file":  d.stateFile,
+			"error": err,
+		})
+		return
+	}
+	if s.Seasonal != d.seasonal {
+		d.logger.Warn("baselines were kept with a different seasonality, starting without baselines", logging.Fields{
+			"file":     d.stateFile,
+			"seasonal": d.seasonal,
+		})
+		return
+	}
+
+	for key, b := range s.Baselines {
+		if b != nil {
+			d.baselines[key] = b
+		}
+	}
+
+	d.logger.Info("restored baselines", logging.Fields{
+		"file":      d.stateFile,
+		"baselines": len(d.baselines),
+	})
+}
+
+// DetectorOption is a func that is used to configure optional settings on a
+// Detector.
+type DetectorOption func(*Detector)
+
+// WithAlpha returns a DetectorOption for configuring the weight of every
+// interval in the baseline, between 0 and 1. Smaller values adapt slower.
+// It defaults to 0.05.
+func WithAlpha(alpha float64) DetectorOption {
+	return func(d *Detector) {
+		d.alpha = alpha
+	}
+}
+
+// WithSeasonal returns a DetectorOption for keeping a baseline per hour of
+// the week.
+func WithSeasonal() DetectorOption {
+	return func(d *Detector) {
+		d.seasonal = true
+	}
+}
+
+// WithWarmup returns a DetectorOption for configuring the number of
+// intervals a baseline has to see before z-scores are reported. It defaults
+// to 30.
+func WithWarmup(n int) DetectorOption {
+	return func(d *Detector) {
+		d.warmup = n
+	}
+}
+
+// WithRetention returns a DetectorOption for configuring how long the
+// baseline of an app is kept while the app is not reported. It defaults to
+// two weeks.
+func WithRetention(d time.Duration) DetectorOption {
+	return func(det *Detector) {
+		det.retention = d
+	}
+}
+
+// WithStateFile returns a DetectorOption for configuring the file the
+// baselines are kept in across restarts.
+func WithStateFile(file string) DetectorOption {
+	return func(d *Detector) {
+		d.stateFile = file
+	}
+}
+
+// WithLogger returns a DetectorOption for configuring the logger used by the
+// Detector.
+func WithLogger(l *logging.Logger) DetectorOption {
+	return func(d *Detector) {
+		d.logger = l
+	}
+}
@@ -0,0 +1,144 @@
+package anomaly_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/anomaly"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Detector", func() {
+	var (
+		noisy = point.Identity{Org: "org", Space: "prod", App: "noisy", AppGUID: "n"}
+		quiet = point.Identity{Org: "org", Space: "prod", App: "quiet", AppGUID: "q"}
+	)
+
+	at := func(ts int64, identity point.Identity, index int, value float64) point.Point {
+		identity.Index = index
+		return point.Point{Name: point.Ingress, Timestamp: ts, Value: value, Identity: identity}
+	}
+
+	zscores := func(points []point.Point) map[string]float64 {
+		z := make(map[string]float64)
+		for _, p := range points {
+			if p.Name == point.ZScore {
+				z[p.Identity.App] = p.Value
+			}
+		}
+		return z
+	}
+
+	It("reports the deviation of the ingress of every app from its baseline", func() {
+		d := anomaly.NewDetector(anomaly.WithWarmup(3), anomaly.WithAlpha(0.5), anomaly.WithLogger(logging.Discard()))
+
+		for i, v := range []float64{100, 110, 90} {
+			ts := int64(60 * (i + 1))
+			out := d.ProcessPoints([]point.Point{at(ts, noisy, 0, v/2), at(ts, noisy, 1, v/2), at(ts, quiet, 0, 1)})
+			Expect(zscores(out)).To(BeEmpty())
+		}
+
+		b, ok := d.Baseline("n", 240)
+		Expect(ok).To(BeTrue())
+		Expect(b.Samples).To(Equal(3))
+
+		in := []point.Point{at(240, noisy, 0, 200), at(240, noisy, 1, 200), at(240, quiet, 0, 1)}
+		out := d.ProcessPoints(in)
+
+		Expect(out[:3]).To(Equal(in))
+		Expect(out).To(HaveLen(5))
+		Expect(out[3]).To(Equal(point.Point{
+			Name:      point.ZScore,
+			Timestamp: 240,
+			Value:     b.ZScore(400),
+			Identity:  point.Identity{Org: "org", Space: "prod", App: "noisy", AppGUID: "n", Index: -1},
+		}))
+		Expect(out[3].Value).To(BeNumerically(">", 3))
+		Expect(zscores(out)["quiet"]).To(BeZero())
+	})
+
+	It("keeps a baseline per hour of the week when seasonal", func() {
+		d := anomaly.NewDetector(anomaly.WithSeasonal(), anomaly.WithWarmup(1), anomaly.WithLogger(logging.Discard()))
+
+		monday9 := time.Date(2018, 3, 5, 9, 0, 0, 0, time.UTC).Unix()
+		d.ProcessPoints([]point.Point{at(monday9, noisy, 0, 1000)})
+
+		Expect(zscores(d.ProcessPoints([]point.Point{at(monday9+3600, noisy, 0, 10)}))).To(BeEmpty())
+		Expect(zscores(d.ProcessPoints([]point.Point{at(monday9+7*24*3600, noisy, 0, 1000)}))).To(HaveKeyWithValue("noisy", 0.0))
+	})
+
+	It("forgets the baselines of apps no longer reported", func() {
+		d := anomaly.NewDetector(anomaly.WithRetention(time.Hour), anomaly.WithLogger(logging.Discard()))
+
+		d.ProcessPoints([]point.Point{at(60, noisy, 0, 10)})
+		d.ProcessPoints([]point.Point{at(60+3600, quiet, 0, 10)})
+		_, ok := d.Baseline("n", 60)
+		Expect(ok).To(BeTrue())
+
+		d.ProcessPoints([]point.Point{at(120+3600, quiet, 0, 10)})
+		_, ok = d.Baseline("n", 60)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("does not divide by a vanishing variance", func() {
+		b := anomaly.Baseline{}
+		for i := 0; i < 10; i++ {
+			b.Update(50, 0.1)
+		}
+
+		Expect(b.ZScore(50)).To(BeZero())
+		Expect(b.ZScore(53)).To(Equal(3.0))
+	})
+
+	Context("with a state file", func() {
+		var (
+			dir  string
+			file string
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "anomaly")
+			Expect(err).ToNot(HaveOccurred())
+			file = filepath.Join(dir, "baselines.json")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("keeps the baselines across restarts", func() {
+			d := anomaly.NewDetector(anomaly.WithStateFile(file), anomaly.WithLogger(logging.Discard()))
+			d.ProcessPoints([]point.Point{at(60, noisy, 0, 10)})
+			d.ProcessPoints([]point.Point{at(120, noisy, 0, 20)})
+
+			restarted := anomaly.NewDetector(anomaly.WithStateFile(file), anomaly.WithLogger(logging.Discard()))
+			b, ok := restarted.Baseline("n", 180)
+			Expect(ok).To(BeTrue())
+			Expect(b.Samples).To(Equal(2))
+			Expect(b.LastSeen).To(Equal(int64(120)))
+		})
+
+		It("starts over when the seasonality changed", func() {
+			d := anomaly.NewDetector(anomaly.WithStateFile(file), anomaly.WithLogger(logging.Discard()))
+			d.ProcessPoints([]point.Point{at(60, noisy, 0, 10)})
+
+			restarted := anomaly.NewDetector(anomaly.WithStateFile(file), anomaly.WithSeasonal(), anomaly.WithLogger(logging.Discard()))
+			_, ok := restarted.Baseline("n", 60)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("starts over when the state file is corrupt", func() {
+			Expect(ioutil.WriteFile(file, []byte("{"), 0644)).To(Succeed())
+
+			d := anomaly.NewDetector(anomaly.WithStateFile(file), anomaly.WithLogger(logging.Discard()))
+			_, ok := d.Baseline("n", 60)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
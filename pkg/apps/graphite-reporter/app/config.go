@@ -5,40 +5,249 @@ import (
 	"time"
 
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/reporter"
 )
 
+// sinkKindNames are the supported sinks.
+var sinkKindNames = []string{"graphite", "statsd", "datadog", "otlp", "remote-write", "opentsdb", "elasticsearch", "file"}
+
 var (
 	uaaAddr              = kingpin.Flag("uaa-addr", "UAA address").Envar("UAA_ADDR").Required().String()
 	capiAddr             = kingpin.Flag("capi-addr", "Api endpoint address.").Envar("CAPI_ADDR").Required().String()
 	accumulatorAddr      = kingpin.Flag("accumulator-addr", "Api endpoint address.").Envar("ACCUMULATOR_ADDR").Required().String()
-	syslogServer         = kingpin.Flag("syslog-server", "Syslog server.").Envar("SYSLOG_ENDPOINT").String()
+	syslogServer         = kingpin.Flag("syslog-server", "Syslog server for the reporter's own logs, e.g. udp://host:514, tcp://host:514 or tls://host:6514.").Envar("SYSLOG_ENDPOINT").String()
 	clientID             = kingpin.Flag("client-id", "Client ID.").Envar("CLIENT_ID").Required().String()
 	clientSecret         = kingpin.Flag("client-secret", "Client secret.").Envar("CLIENT_SECRET").Required().String()
-	metricsHost          = kingpin.Flag("metrics-host", "Metrics Host.").Envar("METRICS_HOST").Required().String()
-	metricsPort          = kingpin.Flag("metrics-port", "Metrics Port.").Envar("METRICS_PORT").Required().Int()
+	sinkKinds            = kingpin.Flag("sink", "Where the points are sent (graphite, statsd, datadog, otlp, remote-write, opentsdb, elasticsearch, file). Repeat to send to several sinks concurrently.").Default("graphite").Envar("SINK").Enums(sinkKindNames...)
+	sinkQueueSize        = kingpin.Flag("sink-queue-size", "Number of batches queued per sink when sending to several sinks. The oldest batch is dropped when the queue is full.").Default("10").Envar("SINK_QUEUE_SIZE").Int()
+	sinkTimeout          = kingpin.Flag("sink-timeout", "Timeout of a single attempt to send a batch when sending to several sinks, and of every request to HTTP based sinks.").Default("30s").Envar("SINK_TIMEOUT").Duration()
+	sinkRetries          = kingpin.Flag("sink-retries", "Number of retries of a failed batch when sending to several sinks.").Default("2").Envar("SINK_RETRIES").Int()
+	sinkRetryBackoff     = kingpin.Flag("sink-retry-backoff", "Wait before the first retry of a failed batch, doubling with every retry.").Default("5s").Envar("SINK_RETRY_BACKOFF").Duration()
+	sinkIntervals        = kingpin.Flag("sink-interval", "Reporting interval of a sink, as sink=duration, e.g. datadog=10m. The sink gets one aggregate per interval instead of every report. It must be a multiple of the report interval, which must match the accumulator interval.").StringMap()
+	sinkAggregations     = kingpin.Flag("sink-aggregation", "Aggregation of the reports within the interval of a sink, as sink=sum|max|mean. Defaults to sum.").StringMap()
+	derivedSeries        = kingpin.Flag("derived-series", "Series derived from the ingress: rate (per second of every instance), share (percentage of the total ingress of the foundation of every instance and app) or delta (change of every instance from the previous interval). Repeat to derive several.").Envar("DERIVED_SERIES").Enums(point.Rate, point.Share, point.Delta)
+	anomalyDetection     = kingpin.Flag("anomaly-detection", "Keep a baseline of the ingress of every app and report its deviation as a z-score per app.").Default("false").Envar("ANOMALY_DETECTION").Bool()
+	anomalyAlpha         = kingpin.Flag("anomaly-alpha", "Weight of every interval in the baselines, between 0 and 1. Smaller values adapt slower.").Default("0.05").Envar("ANOMALY_ALPHA").Float64()
+	anomalySeasonal      = kingpin.Flag("anomaly-seasonal", "Keep a baseline per app and hour of the week.").Default("false").Envar("ANOMALY_SEASONAL").Bool()
+	anomalyWarmup        = kingpin.Flag("anomaly-warmup", "Number of intervals a baseline has to see before z-scores are reported.").Default("30").Envar("ANOMALY_WARMUP").Int()
+	anomalyStateFile     = kingpin.Flag("anomaly-state-file", "File the baselines are kept in across restarts.").Envar("ANOMALY_STATE_FILE").String()
+	scoreEnabled         = kingpin.Flag("score", "Score and rank the apps by how much they are likely to hurt their neighbours, combining their share of the total ingress, its persistence and its spread across instances.").Default("false").Envar("SCORE").Bool()
+	scoreWindow          = kingpin.Flag("score-window", "Number of intervals the persistence of the share of an app is measured over.").Default("10").Envar("SCORE_WINDOW").Int()
+	scoreNoisyShare      = kingpin.Flag("score-noisy-share", "Percentage of the total ingress from which an app counts as noisy on an interval.").Default("5").Envar("SCORE_NOISY_SHARE").Float64()
+	scoreTopK            = kingpin.Flag("score-top-k", "Number of apps in the ranking served by the admin endpoints.").Default("10").Envar("SCORE_TOP_K").Int()
+	alertRulesFile       = kingpin.Flag("alert-rules-file", "JSON file with the alert rules evaluated on every report.").Envar("ALERT_RULES_FILE").String()
+	alertStateFile       = kingpin.Flag("alert-state-file", "File the state of the alerts is kept in across restarts.").Envar("ALERT_STATE_FILE").String()
+	alertSilencesFile    = kingpin.Flag("alert-silences-file", "JSON file with silences holding back the notifications of matching alerts, e.g. during load tests.").Envar("ALERT_SILENCES_FILE").String()
+	namesFile            = kingpin.Flag("names-file", "JSON file the current org, space and app names of every app GUID and the renames observed are written to.").Envar("NAMES_FILE").String()
+	namesInterval        = kingpin.Flag("names-interval", "How often the names file is rewritten when no rename is observed.").Default("5m").Envar("NAMES_INTERVAL").Duration()
+	adminAddr            = kingpin.Flag("admin-addr", "Address the admin endpoints for the alert status, silences, ranking and app names listen on, e.g. 127.0.0.1:8080. Disabled by default.").Envar("ADMIN_ADDR").String()
+	adminTokenFile       = kingpin.Flag("admin-token-file", "File containing the bearer token required by the admin endpoints. Silences can only be changed at runtime with a token.").Envar("ADMIN_TOKEN_FILE").String()
+	alertWebhookURL      = kingpin.Flag("alert-webhook-url", "URL alerts are posted to as JSON.").Envar("ALERT_WEBHOOK_URL").String()
+	alertWebhookTemplate = kingpin.Flag("alert-webhook-template-file", "File with a Go template rendering the JSON body posted to the alert webhook.").Envar("ALERT_WEBHOOK_TEMPLATE_FILE").String()
+	alertSlackURLFile    = kingpin.Flag("alert-slack-webhook-url-file", "File containing the Slack compatible incoming webhook URL alerts are posted to.").Envar("ALERT_SLACK_WEBHOOK_URL_FILE").String()
+	alertSlackChannel    = kingpin.Flag("alert-slack-channel", "Channel overriding the one of the Slack incoming webhook.").Envar("ALERT_SLACK_CHANNEL").String()
+	alertPagerDutyKey    = kingpin.Flag("alert-pagerduty-routing-key-file", "File containing the PagerDuty Events v2 routing key.").Envar("ALERT_PAGERDUTY_ROUTING_KEY_FILE").String()
+	alertPagerDutyURL    = kingpin.Flag("alert-pagerduty-url", "PagerDuty Events v2 endpoint.").Default("https://events.pagerduty.com/v2/enqueue").Envar("ALERT_PAGERDUTY_URL").String()
+	alertPagerDutySev    = kingpin.Flag("alert-pagerduty-severity", "Severity of PagerDuty incidents (critical, error, warning, info).").Default("warning").Envar("ALERT_PAGERDUTY_SEVERITY").Enum("critical", "error", "warning", "info")
+	alertmanagerURLs     = kingpin.Flag("alert-alertmanager-url", "URL of a Prometheus Alertmanager alerts are posted to, e.g. http://alertmanager:9093. Repeat for every instance of a cluster.").Envar("ALERT_ALERTMANAGER_URL").Strings()
+	alertmanagerSeverity = kingpin.Flag("alert-alertmanager-severity", "Severity label of the alerts posted to Alertmanager.").Default("warning").Envar("ALERT_ALERTMANAGER_SEVERITY").String()
+	alertmanagerLabels   = kingpin.Flag("alert-alertmanager-label", "Label added to every alert posted to Alertmanager, as name=value.").StringMap()
+	alertmanagerResend   = kingpin.Flag("alert-alertmanager-resend-interval", "How often firing alerts are posted to Alertmanager again, so that they do not resolve.").Default("1m").Envar("ALERT_ALERTMANAGER_RESEND_INTERVAL").Duration()
+	alertNotifyRetries   = kingpin.Flag("alert-notify-retries", "Number of retries of a failed notification.").Default("3").Envar("ALERT_NOTIFY_RETRIES").Int()
+	alertNotifyLimit     = kingpin.Flag("alert-notify-rate-limit", "Maximum number of firing notifications per notifier and rate limit period, 0 for no limit. Resolved notifications are always sent.").Default("60").Envar("ALERT_NOTIFY_RATE_LIMIT").Int()
+	alertNotifyPeriod    = kingpin.Flag("alert-notify-rate-period", "Period of the notification rate limit.").Default("1h").Envar("ALERT_NOTIFY_RATE_PERIOD").Duration()
+	metricsHost          = kingpin.Flag("metrics-host", "Metrics Host.").Envar("METRICS_HOST").String()
+	metricsPort          = kingpin.Flag("metrics-port", "Metrics Port.").Envar("METRICS_PORT").Int()
 	graphitePrefix       = kingpin.Flag("graphite-prefix", "Graphite metrics prefix").Envar("GRAPHITE_PREFIX").Required().String()
+	graphiteSchema       = kingpin.Flag("graphite-schema", "Layout of dotted Graphite and StatsD names: v1 (<prefix>.<org>.<space>.<app>.<index>) or v2 (<prefix>.v2.<org>.<space>.<app>.instances.<index>.<kind>).").Default("v1").Envar("GRAPHITE_SCHEMA").Enum("v1", "v2")
+	graphiteDualWrite    = kingpin.Flag("graphite-dual-write", "Send every dotted Graphite and StatsD name under both the v1 and the v2 layout, while migrating.").Default("false").Envar("GRAPHITE_DUAL_WRITE").Bool()
+	graphiteNameTemplate = kingpin.Flag("graphite-name-template", "Go text/template rendering dotted Graphite and StatsD names instead of a schema, with .Prefix, .Foundation, .Kind, .Org, .OrgGUID, .Space, .SpaceGUID, .App, .AppGUID, .Index and .AppLevel, e.g. '{{.Foundation}}.{{.Org}}.{{.Space}}.{{.App}}{{if not .AppLevel}}.{{.Index}}{{end}}.{{.Kind}}'.").Envar("GRAPHITE_NAME_TEMPLATE").String()
+	graphiteGUIDKeys     = kingpin.Flag("graphite-guid-keys", "Key dotted Graphite and StatsD names on the app GUID instead of the org, space and app names, so that renames do not split the history. Use --names-file or the admin endpoints to map GUIDs to names.").Default("false").Envar("GRAPHITE_GUID_KEYS").Bool()
+	graphiteTagged       = kingpin.Flag("graphite-tagged", "Name Graphite metrics with tags for org, space, app and instance instead of dotted paths.").Default("false").Envar("GRAPHITE_TAGGED").Bool()
+	statsdAddr           = kingpin.Flag("statsd-addr", "StatsD agent address.").Default("127.0.0.1:8125").Envar("STATSD_ADDR").String()
+	statsdMTU            = kingpin.Flag("statsd-mtu", "Maximum size of a StatsD datagram.").Default("1432").Envar("STATSD_MTU").Int()
+	statsdTags           = kingpin.Flag("statsd-dogstatsd-tags", "Tag StatsD gauges with org, space and app using the DogStatsD extension.").Default("false").Envar("STATSD_DOGSTATSD_TAGS").Bool()
+	statsdMetricName     = kingpin.Flag("statsd-metric-name", "Name of the gauges when DogStatsD tags are enabled.").Default("noisy_neighbor.ingress").Envar("STATSD_METRIC_NAME").String()
+	datadogURL           = kingpin.Flag("datadog-url", "Datadog series endpoint, e.g. for the EU site or a local proxy.").Default("https://api.datadoghq.com/api/v1/series").Envar("DATADOG_URL").String()
+	datadogAPIKeyFile    = kingpin.Flag("datadog-api-key-file", "File containing the Datadog API key.").Envar("DATADOG_API_KEY_FILE").String()
+	datadogMetricName    = kingpin.Flag("datadog-metric-name", "Name of the Datadog series.").Default("application.ingress").Envar("DATADOG_METRIC_NAME").String()
+	datadogHost          = kingpin.Flag("datadog-host", "Host reported with every Datadog series.").Envar("DATADOG_HOST").String()
+	datadogBatchSize     = kingpin.Flag("datadog-batch-size", "Maximum number of series per Datadog request.").Default("1000").Envar("DATADOG_BATCH_SIZE").Int()
+	otlpURL              = kingpin.Flag("otlp-url", "OTLP/HTTP metrics endpoint.").Default("http://localhost:4318/v1/metrics").Envar("OTLP_URL").String()
+	otlpEncoding         = kingpin.Flag("otlp-encoding", "Body encoding of OTLP requests (json, protobuf).").Default("json").Envar("OTLP_ENCODING").Enum("json", "protobuf")
+	otlpKind             = kingpin.Flag("otlp-kind", "OTLP metric type of the ingress counts (gauge, delta-sum). delta-sum requires the sum aggregation for the otlp sink.").Default("gauge").Envar("OTLP_KIND").Enum("gauge", "delta-sum")
+	otlpMetricName       = kingpin.Flag("otlp-metric-name", "Name of the OTLP metric.").Default("cloudfoundry.app.ingress").Envar("OTLP_METRIC_NAME").String()
+	otlpHeaders          = kingpin.Flag("otlp-header", "Header sent with every OTLP request, as key=value.").StringMap()
+	remoteWriteURL       = kingpin.Flag("remote-write-url", "Prometheus remote write endpoint.").Default("http://localhost:9090/api/v1/write").Envar("REMOTE_WRITE_URL").String()
+	remoteWriteUsername  = kingpin.Flag("remote-write-username", "Username for basic auth against the remote write endpoint.").Envar("REMOTE_WRITE_USERNAME").String()
+	remoteWritePassword  = kingpin.Flag("remote-write-password-file", "File containing the password for basic auth against the remote write endpoint.").Envar("REMOTE_WRITE_PASSWORD_FILE").String()
+	remoteWriteToken     = kingpin.Flag("remote-write-bearer-token-file", "File containing a bearer token for the remote write endpoint.").Envar("REMOTE_WRITE_BEARER_TOKEN_FILE").String()
+	remoteWriteName      = kingpin.Flag("remote-write-metric-name", "Name of the remote write series.").Default("cf_app_ingress").Envar("REMOTE_WRITE_METRIC_NAME").String()
+	remoteWriteBatchSize = kingpin.Flag("remote-write-batch-size", "Maximum number of series per remote write request.").Default("2000").Envar("REMOTE_WRITE_BATCH_SIZE").Int()
+	remoteWriteLabels    = kingpin.Flag("remote-write-label", "Label added to every remote write series, as name=value.").StringMap()
+	openTSDBProtocol     = kingpin.Flag("opentsdb-protocol", "Protocol used to send data points to OpenTSDB (telnet, http).").Default("http").Envar("OPENTSDB_PROTOCOL").Enum("telnet", "http")
+	openTSDBAddr         = kingpin.Flag("opentsdb-addr", "OpenTSDB address for the telnet protocol.").Default("127.0.0.1:4242").Envar("OPENTSDB_ADDR").String()
+	openTSDBURL          = kingpin.Flag("opentsdb-url", "OpenTSDB put endpoint for the http protocol.").Default("http://127.0.0.1:4242/api/put").Envar("OPENTSDB_URL").String()
+	openTSDBMetricName   = kingpin.Flag("opentsdb-metric-name", "Name of the OpenTSDB metric.").Default("cf.app.ingress").Envar("OPENTSDB_METRIC_NAME").String()
+	openTSDBBatchSize    = kingpin.Flag("opentsdb-batch-size", "Maximum number of data points per OpenTSDB request.").Default("50").Envar("OPENTSDB_BATCH_SIZE").Int()
+	openTSDBTags         = kingpin.Flag("opentsdb-tag", "Tag added to every OpenTSDB data point, as key=value.").StringMap()
+	esURL                = kingpin.Flag("elasticsearch-url", "Elasticsearch or OpenSearch cluster address.").Default("http://localhost:9200").Envar("ELASTICSEARCH_URL").String()
+	esIndexPrefix        = kingpin.Flag("elasticsearch-index-prefix", "Prefix of the Elasticsearch indices, followed by the date of the documents.").Default("noisy-neighbor-").Envar("ELASTICSEARCH_INDEX_PREFIX").String()
+	esIndexDateFormat    = kingpin.Flag("elasticsearch-index-date-format", "Go time layout of the date suffix of the Elasticsearch indices.").Default("2006.01.02").Envar("ELASTICSEARCH_INDEX_DATE_FORMAT").String()
+	esUsername           = kingpin.Flag("elasticsearch-username", "Username for basic auth against Elasticsearch.").Envar("ELASTICSEARCH_USERNAME").String()
+	esPasswordFile       = kingpin.Flag("elasticsearch-password-file", "File containing the password for basic auth against Elasticsearch.").Envar("ELASTICSEARCH_PASSWORD_FILE").String()
+	esAPIKeyFile         = kingpin.Flag("elasticsearch-api-key-file", "File containing a base64 encoded Elasticsearch API key.").Envar("ELASTICSEARCH_API_KEY_FILE").String()
+	esBatchSize          = kingpin.Flag("elasticsearch-batch-size", "Maximum number of documents per bulk request.").Default("500").Envar("ELASTICSEARCH_BATCH_SIZE").Int()
+	filePath             = kingpin.Flag("file-path", "File the resolved points are written to, by the file sink or alongside any other sink.").Envar("FILE_PATH").String()
+	fileFormat           = kingpin.Flag("file-format", "Format of the file records (jsonl, csv).").Default("jsonl").Envar("FILE_FORMAT").Enum("jsonl", "csv")
+	fileMaxSize          = kingpin.Flag("file-max-size", "Size after which the file is rotated, e.g. 100MB. Zero disables size based rotation.").Default("100MB").Envar("FILE_MAX_SIZE").Bytes()
+	fileRotateInterval   = kingpin.Flag("file-rotate-interval", "How long the file is written to before it is rotated. Zero disables time based rotation.").Default("0s").Envar("FILE_ROTATE_INTERVAL").Duration()
+	fileCompress         = kingpin.Flag("file-compress", "Gzip rotated files.").Default("true").Envar("FILE_COMPRESS").Bool()
+	fileMaxBackups       = kingpin.Flag("file-max-backups", "Number of rotated files to keep. Zero keeps all of them.").Default("7").Envar("FILE_MAX_BACKUPS").Int()
+	foundation           = kingpin.Flag("foundation", "Name of the Cloud Foundry foundation being reported on, added as foundation label to every point.").Envar("FOUNDATION").String()
 	skipCertVerify       = kingpin.Flag("skip-cert-verify", "Please don't").Default("false").Envar("SKIP_CERT_VERIFY").Bool()
 	reportInterval       = kingpin.Flag("report-interval", "Report interval").Default("1m").Envar("REPORT_INTERVAL").Duration()
+	accumulatorInterval  = kingpin.Flag("accumulator-interval", "Width of the buckets the accumulator aggregates rates into, i.e. its polling interval.").Default("1m").Envar("ACCUMULATOR_INTERVAL").Duration()
+	queryLag             = kingpin.Flag("query-lag", "How far behind each tick the queried accumulator bucket is.").Default("2m").Envar("QUERY_LAG").Duration()
+	retryInterval        = kingpin.Flag("retry-interval", "How long to wait before requesting a bucket again that is not available yet.").Default("10s").Envar("RETRY_INTERVAL").Duration()
 	reportLimit          = kingpin.Flag("report-limit", "Report limit").Default("50").Envar("REPORT_LIMIT").Int()
 	appInfoCacheDuration = kingpin.Flag("cache-duration", "APP INFO CACHE DURATION").Default("150s").Envar("APP_INFO_CACHE_TTL").Duration()
+	logLevel             = kingpin.Flag("log-level", "Minimum level of log entries (debug, info, warn, error).").Default("info").Envar("LOG_LEVEL").Enum("debug", "info", "warn", "error")
+	once                 = kingpin.Flag("once", "Perform a single report cycle and exit, with a non-zero status on failure.").Default("false").Envar("ONCE").Bool()
+	dryRun               = kingpin.Flag("dry-run", "Print the payload to stdout instead of sending it.").Default("false").Envar("DRY_RUN").Bool()
+	logFormat            = kingpin.Flag("log-format", "Format of log entries (json, logfmt).").Default("json").Envar("LOG_FORMAT").Enum("json", "logfmt")
 )
 
 // Config stores configuration data for the accumulator.
 type Config struct {
-	UAAAddr         string
-	CAPIAddr        string
-	AccumulatorAddr string
-	ClientID        string
-	ClientSecret    string
-	GraphiteHost    string
-	GraphitePort    int
-	GraphitePrefix  string
-	SkipCertVerify  bool
-	ReportInterval  time.Duration
-	ReportLimit     int
+	UAAAddr           string
+	CAPIAddr          string
+	AccumulatorAddr   string
+	ClientID          string
+	ClientSecret      string
+	Sinks             []string
+	GraphiteHost      string
+	GraphitePort      int
+	GraphitePrefix    string
+	GraphiteTagged    bool
+	GraphiteSchema    encoding.GraphiteSchema
+	GraphiteDualWrite bool
+	GraphiteTemplate  *encoding.NameTemplate
+	GraphiteGUIDKeys  bool
+	Foundation        string
+	SyslogServer      string
+	SkipCertVerify    bool
+	ReportInterval    time.Duration
+	ReportLimit       int
+
+	AccumulatorInterval time.Duration
+	QueryLag            time.Duration
+	RetryInterval       time.Duration
 
 	AppInfoCacheTTL time.Duration
 
+	SinkQueueSize    int
+	SinkTimeout      time.Duration
+	SinkRetries      int
+	SinkRetryBackoff time.Duration
+	SinkIntervals    map[string]time.Duration
+	SinkAggregations map[string]reporter.Aggregation
+
+	DerivedSeries []string
+
+	AnomalyDetection bool
+	AnomalyAlpha     float64
+	AnomalySeasonal  bool
+	AnomalyWarmup    int
+	AnomalyStateFile string
+
+	Score           bool
+	ScoreWindow     int
+	ScoreNoisyShare float64
+	ScoreTopK       int
+
+	AlertRulesFile string
+	AlertStateFile string
+	SilencesFile   string
+
+	NamesFile     string
+	NamesInterval time.Duration
+
+	AdminAddr      string
+	AdminTokenFile string
+
+	AlertWebhookURL            string
+	AlertWebhookTemplateFile   string
+	AlertSlackURLFile          string
+	AlertSlackChannel          string
+	AlertPagerDutyKeyFile      string
+	AlertPagerDutyURL          string
+	AlertPagerDutySeverity     string
+	AlertmanagerURLs           []string
+	AlertmanagerSeverity       string
+	AlertmanagerLabels         map[string]string
+	AlertmanagerResendInterval time.Duration
+	AlertNotifyRetries         int
+	AlertNotifyRateLimit       int
+	AlertNotifyRatePeriod      time.Duration
+
+	StatsDAddr       string
+	StatsDMTU        int
+	StatsDTags       bool
+	StatsDMetricName string
+
+	DatadogURL        string
+	DatadogAPIKeyFile string
+	DatadogMetricName string
+	DatadogHost       string
+	DatadogBatchSize  int
+
+	OTLPURL        string
+	OTLPEncoding   string
+	OTLPKind       string
+	OTLPMetricName string
+	OTLPHeaders    map[string]string
+
+	RemoteWriteURL             string
+	RemoteWriteUsername        string
+	RemoteWritePasswordFile    string
+	RemoteWriteBearerTokenFile string
+	RemoteWriteMetricName      string
+	RemoteWriteBatchSize       int
+	RemoteWriteLabels          map[string]string
+
+	OpenTSDBProtocol   string
+	OpenTSDBAddr       string
+	OpenTSDBURL        string
+	OpenTSDBMetricName string
+	OpenTSDBBatchSize  int
+	OpenTSDBTags       map[string]string
+
+	ElasticsearchURL             string
+	ElasticsearchIndexPrefix     string
+	ElasticsearchIndexDateFormat string
+	ElasticsearchUsername        string
+	ElasticsearchPasswordFile    string
+	ElasticsearchAPIKeyFile      string
+	ElasticsearchBatchSize       int
+
+	FilePath           string
+	FileFormat         string
+	FileMaxSize        int64
+	FileRotateInterval time.Duration
+	FileCompress       bool
+	FileMaxBackups     int
+
+	Once   bool
+	DryRun bool
+
+	LogLevel  logging.Level
+	LogFormat logging.Format
+
 	TLSConfig *tls.Config
 }
 
@@ -48,22 +257,205 @@ func LoadConfig() Config {
 	kingpin.Parse()
 
 	cfg := Config{
-		UAAAddr:         *uaaAddr,
-		CAPIAddr:        *capiAddr,
-		AccumulatorAddr: *accumulatorAddr,
-		ClientID:        *clientID,
-		ClientSecret:    *clientSecret,
-		GraphiteHost:    *metricsHost,
-		GraphitePort:    *metricsPort,
-		GraphitePrefix:  *graphitePrefix,
-		SkipCertVerify:  *skipCertVerify,
-		ReportInterval:  *reportInterval,
-		ReportLimit:     *reportLimit,
+		UAAAddr:           *uaaAddr,
+		CAPIAddr:          *capiAddr,
+		AccumulatorAddr:   *accumulatorAddr,
+		ClientID:          *clientID,
+		ClientSecret:      *clientSecret,
+		Sinks:             *sinkKinds,
+		GraphiteHost:      *metricsHost,
+		GraphitePort:      *metricsPort,
+		GraphitePrefix:    *graphitePrefix,
+		GraphiteTagged:    *graphiteTagged,
+		GraphiteDualWrite: *graphiteDualWrite,
+		GraphiteGUIDKeys:  *graphiteGUIDKeys,
+		Foundation:        *foundation,
+		SyslogServer:      *syslogServer,
+		SkipCertVerify:    *skipCertVerify,
+		ReportInterval:    *reportInterval,
+		ReportLimit:       *reportLimit,
+
+		AccumulatorInterval: *accumulatorInterval,
+		QueryLag:            *queryLag,
+		RetryInterval:       *retryInterval,
 
 		AppInfoCacheTTL: *appInfoCacheDuration,
+
+		SinkQueueSize:    *sinkQueueSize,
+		SinkTimeout:      *sinkTimeout,
+		SinkRetries:      *sinkRetries,
+		SinkRetryBackoff: *sinkRetryBackoff,
+
+		DerivedSeries: *derivedSeries,
+
+		AnomalyDetection: *anomalyDetection,
+		AnomalyAlpha:     *anomalyAlpha,
+		AnomalySeasonal:  *anomalySeasonal,
+		AnomalyWarmup:    *anomalyWarmup,
+		AnomalyStateFile: *anomalyStateFile,
+
+		Score:           *scoreEnabled,
+		ScoreWindow:     *scoreWindow,
+		ScoreNoisyShare: *scoreNoisyShare,
+		ScoreTopK:       *scoreTopK,
+
+		AlertRulesFile: *alertRulesFile,
+		AlertStateFile: *alertStateFile,
+		SilencesFile:   *alertSilencesFile,
+
+		NamesFile:     *namesFile,
+		NamesInterval: *namesInterval,
+
+		AdminAddr:      *adminAddr,
+		AdminTokenFile: *adminTokenFile,
+
+		AlertWebhookURL:            *alertWebhookURL,
+		AlertWebhookTemplateFile:   *alertWebhookTemplate,
+		AlertSlackURLFile:          *alertSlackURLFile,
+		AlertSlackChannel:          *alertSlackChannel,
+		AlertPagerDutyKeyFile:      *alertPagerDutyKey,
+		AlertPagerDutyURL:          *alertPagerDutyURL,
+		AlertPagerDutySeverity:     *alertPagerDutySev,
+		AlertmanagerURLs:           *alertmanagerURLs,
+		AlertmanagerSeverity:       *alertmanagerSeverity,
+		AlertmanagerLabels:         *alertmanagerLabels,
+		AlertmanagerResendInterval: *alertmanagerResend,
+		AlertNotifyRetries:         *alertNotifyRetries,
+		AlertNotifyRateLimit:       *alertNotifyLimit,
+		AlertNotifyRatePeriod:      *alertNotifyPeriod,
+
+		StatsDAddr:       *statsdAddr,
+		StatsDMTU:        *statsdMTU,
+		StatsDTags:       *statsdTags,
+		StatsDMetricName: *statsdMetricName,
+
+		DatadogURL:        *datadogURL,
+		DatadogAPIKeyFile: *datadogAPIKeyFile,
+		DatadogMetricName: *datadogMetricName,
+		DatadogHost:       *datadogHost,
+		DatadogBatchSize:  *datadogBatchSize,
+
+		OTLPURL:        *otlpURL,
+		OTLPEncoding:   *otlpEncoding,
+		OTLPKind:       *otlpKind,
+		OTLPMetricName: *otlpMetricName,
+		OTLPHeaders:    *otlpHeaders,
+
+		RemoteWriteURL:             *remoteWriteURL,
+		RemoteWriteUsername:        *remoteWriteUsername,
+		RemoteWritePasswordFile:    *remoteWritePassword,
+		RemoteWriteBearerTokenFile: *remoteWriteToken,
+		RemoteWriteMetricName:      *remoteWriteName,
+		RemoteWriteBatchSize:       *remoteWriteBatchSize,
+		RemoteWriteLabels:          *remoteWriteLabels,
+
+		OpenTSDBProtocol:   *openTSDBProtocol,
+		OpenTSDBAddr:       *openTSDBAddr,
+		OpenTSDBURL:        *openTSDBURL,
+		OpenTSDBMetricName: *openTSDBMetricName,
+		OpenTSDBBatchSize:  *openTSDBBatchSize,
+		OpenTSDBTags:       *openTSDBTags,
+
+		ElasticsearchURL:             *esURL,
+		ElasticsearchIndexPrefix:     *esIndexPrefix,
+		ElasticsearchIndexDateFormat: *esIndexDateFormat,
+		ElasticsearchUsername:        *esUsername,
+		ElasticsearchPasswordFile:    *esPasswordFile,
+		ElasticsearchAPIKeyFile:      *esAPIKeyFile,
+		ElasticsearchBatchSize:       *esBatchSize,
+
+		FilePath:           *filePath,
+		FileFormat:         *fileFormat,
+		FileMaxSize:        int64(*fileMaxSize),
+		FileRotateInterval: *fileRotateInterval,
+		FileCompress:       *fileCompress,
+		FileMaxBackups:     *fileMaxBackups,
+
+		Once:   *once,
+		DryRun: *dryRun,
 	}
 
 	cfg.TLSConfig = &tls.Config{InsecureSkipVerify: cfg.SkipCertVerify}
 
+	// The value has been validated by kingpin.
+	cfg.GraphiteSchema, _ = encoding.ParseGraphiteSchema(*graphiteSchema)
+	if cfg.GraphiteTagged && (cfg.GraphiteSchema != encoding.GraphiteSchemaV1 || cfg.GraphiteDualWrite) {
+		kingpin.Fatalf("--graphite-schema and --graphite-dual-write only apply to dotted names, not with --graphite-tagged")
+	}
+	if cfg.GraphiteTagged && cfg.GraphiteGUIDKeys {
+		kingpin.Fatalf("--graphite-guid-keys only applies to dotted names, not with --graphite-tagged")
+	}
+	if *graphiteNameTemplate != "" {
+		if cfg.GraphiteTagged || cfg.GraphiteSchema != encoding.GraphiteSchemaV1 || cfg.GraphiteDualWrite || cfg.GraphiteGUIDKeys {
+			kingpin.Fatalf("--graphite-name-template cannot be combined with --graphite-tagged, --graphite-schema, --graphite-dual-write or --graphite-guid-keys, use .AppGUID in the template instead")
+		}
+
+		// The template only has to tell apart the kinds of points sent.
+		kinds := append([]string(nil), cfg.DerivedSeries...)
+		if cfg.AnomalyDetection {
+			kinds = append(kinds, point.ZScore)
+		}
+		if cfg.Score {
+			kinds = append(kinds, point.Score, point.Rank)
+		}
+
+		var err error
+		cfg.GraphiteTemplate, err = encoding.ParseNameTemplate(*graphiteNameTemplate, cfg.GraphitePrefix, cfg.Foundation, kinds...)
+		kingpin.FatalIfError(err, "invalid --graphite-name-template")
+	}
+
+	cfg.SinkIntervals = make(map[string]time.Duration)
+	for name, value := range *sinkIntervals {
+		checkSinkName("--sink-interval", name)
+
+		interval, err := time.ParseDuration(value)
+		kingpin.FatalIfError(err, "invalid --sink-interval for %s", name)
+		if interval <= 0 || interval%cfg.ReportInterval != 0 {
+			kingpin.Fatalf("--sink-interval for %s must be a multiple of the report interval %s, got %s", name, cfg.ReportInterval, interval)
+		}
+		if interval != cfg.ReportInterval && cfg.ReportInterval != cfg.AccumulatorInterval {
+			kingpin.Fatalf("--sink-interval requires the report interval %s to match the accumulator interval %s, since every report covers a single accumulator bucket", cfg.ReportInterval, cfg.AccumulatorInterval)
+		}
+		cfg.SinkIntervals[name] = interval
+	}
+
+	cfg.SinkAggregations = make(map[string]reporter.Aggregation)
+	for name, value := range *sinkAggregations {
+		checkSinkName("--sink-aggregation", name)
+
+		aggregation, err := reporter.ParseAggregation(value)
+		kingpin.FatalIfError(err, "invalid --sink-aggregation for %s", name)
+		cfg.SinkAggregations[name] = aggregation
+	}
+	if cfg.OTLPKind == "delta-sum" && cfg.SinkAggregations["otlp"] != reporter.SumAggregation {
+		kingpin.Fatalf("--otlp-kind=delta-sum requires the sum aggregation for the otlp sink")
+	}
+
+	if cfg.AnomalyAlpha <= 0 || cfg.AnomalyAlpha > 1 {
+		kingpin.Fatalf("--anomaly-alpha must be between 0 and 1, got %v", cfg.AnomalyAlpha)
+	}
+
+	if cfg.ScoreWindow < 1 {
+		kingpin.Fatalf("--score-window must be at least 1, got %d", cfg.ScoreWindow)
+	}
+	if cfg.ScoreNoisyShare < 0 || cfg.ScoreNoisyShare > 100 {
+		kingpin.Fatalf("--score-noisy-share must be between 0 and 100, got %v", cfg.ScoreNoisyShare)
+	}
+
+	// Both values have been validated by kingpin.
+	cfg.LogLevel, _ = logging.ParseLevel(*logLevel)
+	cfg.LogFormat, _ = logging.ParseFormat(*logFormat)
+
 	return cfg
 }
+
+// checkSinkName exits when name is not one of the supported sinks.
+func checkSinkName(flag, name string) {
+	for _, s := range sinkKindNames {
+		if s == name {
+			return
+		}
+	}
+
+	kingpin.Fatalf("%s names unknown sink %q, expected one of %v", flag, name, sinkKindNames)
+}
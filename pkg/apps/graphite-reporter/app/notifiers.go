@@ -0,0 +1,109 @@
+package app
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/notify"
+)
+
+// newNotifiers returns the configured alert notifiers, each delivering in
+// the background with its own retries and rate limit, and the Alertmanager
+// notifier if one is configured. In dry-run mode the notifications are
+// printed to stdout instead and no secrets are read.
+func newNotifiers(cfg Config, logger *logging.Logger) ([]alert.Notifier, *notify.Alertmanager) {
+	httpClient := sinkHTTPClient(cfg)
+
+	backends := make(map[string]alert.Notifier)
+	var names []string
+	add := func(name string, n alert.Notifier) {
+		backends[name] = n
+		names = append(names, name)
+	}
+
+	if cfg.AlertWebhookURL != "" {
+		tmpl := notify.DefaultWebhookTemplate
+		if cfg.AlertWebhookTemplateFile != "" {
+			b, err := ioutil.ReadFile(cfg.AlertWebhookTemplateFile)
+			if err != nil {
+				logger.Fatal("failed to read alert webhook template", logging.Fields{
+					"file":  cfg.AlertWebhookTemplateFile,
+					"error": err,
+				})
+			}
+			tmpl = string(b)
+		}
+
+		w, err := notify.NewWebhook(cfg.AlertWebhookURL, tmpl, notify.WithWebhookHTTPClient(httpClient))
+		if err != nil {
+			logger.Fatal("invalid alert webhook template", logging.Fields{
+				"file":  cfg.AlertWebhookTemplateFile,
+				"error": err,
+			})
+		}
+		add("webhook", w)
+	}
+
+	if cfg.AlertSlackURLFile != "" {
+		var url string
+		if !cfg.DryRun {
+			url = readSecret(cfg.AlertSlackURLFile, "slack webhook url", logger)
+		}
+		add("slack", notify.NewSlack(url,
+			notify.WithChannel(cfg.AlertSlackChannel),
+			notify.WithSlackHTTPClient(httpClient),
+		))
+	}
+
+	if cfg.AlertPagerDutyKeyFile != "" {
+		source := "noisy-neighbor-reporter"
+		if cfg.Foundation != "" {
+			source = cfg.Foundation
+		}
+
+		var key string
+		if !cfg.DryRun {
+			key = readSecret(cfg.AlertPagerDutyKeyFile, "pagerduty routing key", logger)
+		}
+		add("pagerduty", notify.NewPagerDuty(key,
+			notify.WithPagerDutyURL(cfg.AlertPagerDutyURL),
+			notify.WithSeverity(cfg.AlertPagerDutySeverity),
+			notify.WithSource(source),
+			notify.WithPagerDutyHTTPClient(httpClient),
+		))
+	}
+
+	var am *notify.Alertmanager
+	if len(cfg.AlertmanagerURLs) > 0 {
+		labels := make(map[string]string)
+		if cfg.Foundation != "" {
+			labels["foundation"] = cfg.Foundation
+		}
+		for k, v := range cfg.AlertmanagerLabels {
+			labels[k] = v
+		}
+
+		am = notify.NewAlertmanager(cfg.AlertmanagerURLs,
+			notify.WithAlertmanagerLabels(labels),
+			notify.WithAlertmanagerSeverity(cfg.AlertmanagerSeverity),
+			notify.WithRateBucketWidth(cfg.AccumulatorInterval),
+			notify.WithResendInterval(cfg.AlertmanagerResendInterval),
+			notify.WithAlertmanagerHTTPClient(httpClient),
+			notify.WithAlertmanagerLogger(logger),
+		)
+		add("alertmanager", am)
+	}
+
+	var notifiers []alert.Notifier
+	for _, name := range names {
+		notifiers = append(notifiers, notify.NewDispatcher(name, backends[name],
+			notify.WithRetries(cfg.AlertNotifyRetries, time.Second),
+			notify.WithRateLimit(cfg.AlertNotifyRateLimit, cfg.AlertNotifyRatePeriod),
+			notify.WithLogger(logger),
+		))
+	}
+
+	return notifiers, am
+}
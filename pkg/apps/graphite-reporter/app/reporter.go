@@ -1,21 +1,24 @@
 package app
 
 import (
-	"log"
 	"net/http"
+	"os"
 	"time"
 
-	"code.cloudfoundry.org/noisy-neighbor-nozzle/pkg/auth"
 	"code.cloudfoundry.org/noisy-neighbor-nozzle/pkg/collector"
 
 	nn_collector "code.cloudfoundry.org/noisy-neighbor-nozzle/pkg/collector"
 
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/admin"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/anomaly"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/auth"
 	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/builder"
-	graphite_builder "github.com/SpringerPE/noisy-neighbor-reporters/pkg/builder/graphite"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/names"
 
 	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/reporter"
-
-	graphite "github.com/marpaia/graphite-golang"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/score"
 )
 
 // Reporter is the constructor for the datadog reporter application.
@@ -26,6 +29,9 @@ type Reporter struct {
 // NewReporter configures and returns a new Reporter
 func NewReporter(cfg Config) *reporter.GraphiteReporter {
 
+	logger := newLogger(cfg)
+	logging.RedirectStdlib(logger, logging.InfoLevel)
+
 	client := &http.Client{
 		Timeout: 5 * time.Second,
 		Transport: &http.Transport{
@@ -33,39 +39,261 @@ func NewReporter(cfg Config) *reporter.GraphiteReporter {
 		},
 	}
 
-	a := auth.NewAuthenticator(cfg.ClientID, cfg.ClientSecret, cfg.UAAAddr,
+	a := auth.NewCachingAuthenticator(cfg.ClientID, cfg.ClientSecret, cfg.UAAAddr,
 		auth.WithHTTPClient(client),
+		auth.WithLogger(logger),
 	)
 
-	httpStore := builder.NewCFLightApiAppInfoStore(cfg.CAPIAddr, client)
+	// Requests to the accumulator are retried once with a fresh token when
+	// the cached one is rejected.
+	rateStatus := builder.NewRateStatusTransport(auth.NewTransport(&http.Transport{
+		TLSClientConfig: cfg.TLSConfig,
+	}, a))
+	accumulatorClient := &http.Client{
+		Timeout:   client.Timeout,
+		Transport: rateStatus,
+	}
+
+	httpStore := builder.NewCFLightApiAppInfoStore(cfg.CAPIAddr, client,
+		builder.WithLogger(logger),
+	)
 	cache := collector.NewCachedAppInfoStore(
 		httpStore,
 		collector.WithCacheTTL(cfg.AppInfoCacheTTL),
 	)
 
-	log.Printf("initializing collector with accumulator: %v", cfg.AccumulatorAddr)
+	logger.Info("initializing collector", logging.Fields{"accumulator": cfg.AccumulatorAddr})
 	c := nn_collector.New([]string{cfg.AccumulatorAddr}, a, "", cache,
 		collector.WithReportLimit(cfg.ReportLimit),
-		collector.WithHTTPClient(client),
+		collector.WithHTTPClient(accumulatorClient),
 	)
 
-	b := graphite_builder.NewGraphiteBuilder(c, cache, cfg.GraphitePrefix)
+	builderOpts := []builder.PointBuilderOption{
+		builder.WithDerivedSeries(cfg.DerivedSeries...),
+		builder.WithBucketWidth(cfg.AccumulatorInterval),
+		builder.WithInterval(cfg.ReportInterval),
+		builder.WithRateStatus(rateStatus),
+		builder.WithBuilderLogger(logger),
+	}
+	if cfg.Foundation != "" {
+		builderOpts = append(builderOpts, builder.WithLabels(map[string]string{"foundation": cfg.Foundation}))
+	}
+	b := builder.NewPointBuilder(c, cache, builderOpts...)
+
+	sinkClient := newSink(cfg, logger)
+
+	logger.Info("initializing graphite reporter", logging.Fields{"sinks": sinkNames(cfg)})
+
+	opts := []reporter.ReporterOption{
+		reporter.WithInterval(cfg.ReportInterval),
+		reporter.WithBucketWidth(cfg.AccumulatorInterval),
+		reporter.WithQueryLag(cfg.QueryLag),
+		reporter.WithRetryInterval(cfg.RetryInterval),
+		reporter.WithLogger(logger),
+	}
+	if cfg.AnomalyDetection {
+		opts = append(opts, reporter.WithProcessor(newAnomalyDetector(cfg, logger)))
+	}
+	var adminOpts []admin.HandlerOption
+	if cfg.Score {
+		scorer := newScorer(cfg, logger)
+		opts = append(opts, reporter.WithProcessor(scorer))
+		adminOpts = append(adminOpts, admin.WithRanking(scorer))
+	}
+
+	registry := newNameRegistry(cfg, logger)
+	opts = append(opts, reporter.WithObserver(registry))
+	adminOpts = append(adminOpts, admin.WithNames(registry))
+
+	var engine admin.AlertEngine
+	if cfg.AlertRulesFile != "" {
+		e := newAlertEngine(cfg, logger)
+		opts = append(opts, reporter.WithObserver(e))
+		engine = e
+	}
+	if cfg.AdminAddr != "" {
+		startAdmin(cfg, engine, adminOpts, logger)
+	}
+
+	r := reporter.NewReporter(b, sinkClient, opts...)
+
+	return r
+}
 
-	graphiteClient, err := graphite.NewGraphite(cfg.GraphiteHost, cfg.GraphitePort)
+// newAlertEngine returns the engine evaluating the configured alert rules.
+// In dry-run mode the alert state is not saved.
+func newAlertEngine(cfg Config, logger *logging.Logger) *alert.Engine {
+	rules, err := alert.LoadRules(cfg.AlertRulesFile)
 	if err != nil {
-		log.Fatalf("Error while connecting to graphite %s:%s: %s", cfg.GraphiteHost, cfg.GraphitePort, err)
+		logger.Fatal("failed to load alert rules", logging.Fields{
+			"file":  cfg.AlertRulesFile,
+			"error": err,
+		})
 	}
 
-	log.Printf("initializing graphite reporter")
+	for _, r := range rules {
+		if r.Metric == alert.AnomalyMetric && !cfg.AnomalyDetection {
+			logger.Fatal("alert rule on the anomaly metric requires --anomaly-detection", logging.Fields{
+				"file": cfg.AlertRulesFile,
+				"rule": r.Name,
+			})
+		}
+	}
 
-	r := reporter.NewReporter(b, graphiteClient,
-		reporter.WithInterval(cfg.ReportInterval),
+	opts := []alert.EngineOption{alert.WithLogger(logger)}
+	if cfg.SilencesFile != "" {
+		silences, err := alert.LoadSilences(cfg.SilencesFile)
+		if err != nil {
+			logger.Fatal("failed to load silences", logging.Fields{
+				"file":  cfg.SilencesFile,
+				"error": err,
+			})
+		}
+		opts = append(opts, alert.WithSilences(silences))
+	}
+	if cfg.AlertStateFile != "" && !cfg.DryRun {
+		opts = append(opts, alert.WithStateFile(cfg.AlertStateFile))
+	}
+	notifiers, am := newNotifiers(cfg, logger)
+	for _, n := range notifiers {
+		opts = append(opts, alert.WithNotifier(n))
+	}
+
+	engine, err := alert.NewEngine(rules, opts...)
+	if err != nil {
+		logger.Fatal("invalid alert rules or silences", logging.Fields{
+			"rules":    cfg.AlertRulesFile,
+			"silences": cfg.SilencesFile,
+			"error":    err,
+		})
+	}
+
+	// Alertmanager resolves the alerts that fired before a restart unless
+	// they are posted again.
+	if am != nil {
+		am.Restore(engine.Alerts())
+	}
+
+	logger.Info("initializing alert rules", logging.Fields{"rules": len(rules)})
+
+	return engine
+}
+
+// newAnomalyDetector returns the detector keeping the baselines of the apps.
+// In dry-run mode the baselines are not saved.
+func newAnomalyDetector(cfg Config, logger *logging.Logger) *anomaly.Detector {
+	opts := []anomaly.DetectorOption{
+		anomaly.WithAlpha(cfg.AnomalyAlpha),
+		anomaly.WithWarmup(cfg.AnomalyWarmup),
+		anomaly.WithLogger(logger),
+	}
+	if cfg.AnomalySeasonal {
+		opts = append(opts, anomaly.WithSeasonal())
+	}
+	if cfg.AnomalyStateFile != "" && !cfg.DryRun {
+		opts = append(opts, anomaly.WithStateFile(cfg.AnomalyStateFile))
+	}
+
+	logger.Info("initializing anomaly detection", logging.Fields{
+		"alpha":    cfg.AnomalyAlpha,
+		"seasonal": cfg.AnomalySeasonal,
+		"warmup":   cfg.AnomalyWarmup,
+	})
+
+	return anomaly.NewDetector(opts...)
+}
+
+// newNameRegistry returns the registry keeping the names of the app GUIDs
+// and detecting renames. In dry-run mode the names file is not written.
+func newNameRegistry(cfg Config, logger *logging.Logger) *names.Registry {
+	opts := []names.RegistryOption{
+		names.WithInterval(cfg.NamesInterval),
+		names.WithLogger(logger),
+	}
+	if cfg.NamesFile != "" && !cfg.DryRun {
+		opts = append(opts, names.WithFile(cfg.NamesFile))
+	}
+
+	return names.NewRegistry(opts...)
+}
+
+// newScorer returns the scorer ranking the apps by how much they are likely
+// to hurt their neighbours.
+func newScorer(cfg Config, logger *logging.Logger) *score.Scorer {
+	logger.Info("initializing noisy neighbour score", logging.Fields{
+		"window":      cfg.ScoreWindow,
+		"noisy_share": cfg.ScoreNoisyShare,
+		"top_k":       cfg.ScoreTopK,
+	})
+
+	return score.NewScorer(
+		score.WithWindow(cfg.ScoreWindow),
+		score.WithNoisyShare(cfg.ScoreNoisyShare),
+		score.WithTopK(cfg.ScoreTopK),
 	)
+}
 
-	return r
+// startAdmin serves the admin endpoints for the alert engine, which is nil
+// without alert rules, in the background.
+func startAdmin(cfg Config, engine admin.AlertEngine, opts []admin.HandlerOption, logger *logging.Logger) {
+	opts = append(opts, admin.WithLogger(logger))
+	if cfg.AdminTokenFile != "" {
+		opts = append(opts, admin.WithToken(readSecret(cfg.AdminTokenFile, "admin token", logger)))
+	} else if engine != nil {
+		logger.Warn("no --admin-token-file configured, silences cannot be changed at runtime")
+	}
+
+	server := &http.Server{
+		Addr:         cfg.AdminAddr,
+		Handler:      admin.NewHandler(engine, opts...),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	logger.Info("initializing admin endpoints", logging.Fields{"addr": cfg.AdminAddr})
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			logger.Error("admin endpoints stopped", logging.Fields{
+				"addr":  cfg.AdminAddr,
+				"error": err,
+			})
+		}
+	}()
+}
+
+// newLogger returns the logger for the reporter's own logs. They are sent to
+// the configured syslog server, falling back to stderr while it is
+// unreachable.
+func newLogger(cfg Config) *logging.Logger {
+	opts := []logging.Option{
+		logging.WithLevel(cfg.LogLevel),
+		logging.WithFormat(cfg.LogFormat),
+	}
+
+	if cfg.SyslogServer == "" {
+		return logging.New(os.Stderr, opts...)
+	}
+
+	w, err := logging.NewSyslogWriter(cfg.SyslogServer,
+		logging.WithAppName("graphite-reporter"),
+		logging.WithTLSConfig(cfg.TLSConfig),
+	)
+	if err != nil {
+		logging.New(os.Stderr, opts...).Fatal("invalid syslog server", logging.Fields{
+			"syslog_server": cfg.SyslogServer,
+			"error":         err,
+		})
+	}
+
+	return logging.New(w, opts...)
 }
 
 // Run starts the graphite reporter. This is a blocking method call.
 func (r *Reporter) Run() {
 	r.reporter.Run()
 }
+
+// RunOnce performs a single report cycle and returns whether it failed.
+func (r *Reporter) RunOnce() error {
+	return r.reporter.RunOnce()
+}
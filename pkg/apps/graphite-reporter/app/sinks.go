@@ -0,0 +1,421 @@
+package app
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	graphite_golang "github.com/marpaia/graphite-golang"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/dryrun"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/reporter"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/datadog"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/elasticsearch"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/file"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/graphite"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/opentsdb"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/otlp"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/remotewrite"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/statsd"
+)
+
+// newSink returns the client the points are sent with. A file configured
+// alongside other sinks is one more sink. Several sinks are sent to
+// concurrently, each with its own queue, timeout and retries. In dry-run
+// mode the payload is printed to stdout instead, one sink after the other.
+// Sinks with their own interval get aggregates of the reports within it.
+// The client of a sink whose attempt timed out is replaced rather than
+// waited for.
+func newSink(cfg Config, logger *logging.Logger) reporter.Client {
+	names := sinkNames(cfg)
+	if len(names) == 1 || cfg.DryRun {
+		var clients []sink.Client
+		for _, name := range names {
+			client := newSinkClient(name, cfg, logger)
+			if d := newDownsampler(name, cfg, logger); d != nil {
+				client = reporter.NewDownsamplingClient(client, d)
+			}
+			clients = append(clients, client)
+		}
+
+		if len(clients) == 1 {
+			return clients[0]
+		}
+		return sink.NewTee(clients...)
+	}
+
+	var sinks []reporter.Sink
+	for _, name := range names {
+		sinks = append(sinks, reporter.Sink{
+			Name:        name,
+			Client:      newSinkClient(name, cfg, logger),
+			NewClient:   sinkClientBuilder(name, cfg, logger),
+			QueueSize:   cfg.SinkQueueSize,
+			Timeout:     cfg.SinkTimeout,
+			Retries:     cfg.SinkRetries,
+			Backoff:     cfg.SinkRetryBackoff,
+			Downsampler: newDownsampler(name, cfg, logger),
+		})
+	}
+
+	return reporter.NewFanOut(sinks, reporter.WithFanOutLogger(logger))
+}
+
+// newDownsampler returns the Downsampler of a sink with its own interval, or
+// nil when the sink gets every report.
+func newDownsampler(name string, cfg Config, logger *logging.Logger) *reporter.Downsampler {
+	interval, ok := cfg.SinkIntervals[name]
+	if !ok || interval == cfg.ReportInterval {
+		return nil
+	}
+
+	return reporter.NewDownsampler(interval,
+		reporter.WithAggregation(cfg.SinkAggregations[name]),
+		reporter.WithBaseInterval(cfg.ReportInterval),
+		reporter.WithDownsamplerLogger(logger.With(logging.Fields{"sink": name})),
+	)
+}
+
+// sinkInterval returns the interval the points sent to a sink cover.
+func sinkInterval(name string, cfg Config) time.Duration {
+	if interval, ok := cfg.SinkIntervals[name]; ok {
+		return interval
+	}
+
+	return cfg.AccumulatorInterval
+}
+
+// sinkNames returns the configured sinks without duplicates, including the
+// file sink when a file path is set.
+func sinkNames(cfg Config) []string {
+	names := append([]string(nil), cfg.Sinks...)
+	if cfg.FilePath != "" {
+		names = append(names, "file")
+	}
+
+	var unique []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+
+	return unique
+}
+
+// fannedOut reports whether the sinks are sent to by a FanOut, which
+// retries failed batches itself.
+func fannedOut(cfg Config) bool {
+	return len(sinkNames(cfg)) > 1 && !cfg.DryRun
+}
+
+// sinkClientBuilder returns the func building a replacement for the client
+// of a sink whose attempt timed out. File clients are not replaced, since
+// the abandoned client would keep writing to the same file.
+func sinkClientBuilder(name string, cfg Config, logger *logging.Logger) func() reporter.Client {
+	switch name {
+	case "file":
+		return nil
+	case "statsd", "datadog", "otlp", "remote-write", "opentsdb", "elasticsearch":
+		return func() reporter.Client {
+			return newSinkClient(name, cfg, logger)
+		}
+	default:
+		// The replacement is connected on the next attempt, so that an
+		// unavailable Graphite does not stop the reporter.
+		return func() reporter.Client {
+			return graphite.NewClient(newGraphiteSender(cfg), cfg.GraphitePrefix, graphiteOptions(cfg, logger)...)
+		}
+	}
+}
+
+func newSinkClient(name string, cfg Config, logger *logging.Logger) reporter.Client {
+	switch name {
+	case "statsd":
+		return newStatsDClient(cfg, logger)
+	case "datadog":
+		return newDatadogClient(cfg, logger)
+	case "otlp":
+		return newOTLPClient(cfg, logger)
+	case "remote-write":
+		return newRemoteWriteClient(cfg, logger)
+	case "opentsdb":
+		return newOpenTSDBClient(cfg, logger)
+	case "elasticsearch":
+		return newElasticsearchClient(cfg, logger)
+	case "file":
+		return newFileClient(cfg, logger)
+	default:
+		return newGraphiteClient(cfg, logger)
+	}
+}
+
+func graphiteOptions(cfg Config, logger *logging.Logger) []graphite.ClientOption {
+	opts := []graphite.ClientOption{
+		graphite.WithSchema(cfg.GraphiteSchema),
+		graphite.WithLogger(logger),
+	}
+	if cfg.GraphiteTagged {
+		opts = append(opts, graphite.WithTaggedNames())
+	}
+	if cfg.GraphiteDualWrite {
+		opts = append(opts, graphite.WithDualWrite())
+	}
+	if cfg.GraphiteTemplate != nil {
+		opts = append(opts, graphite.WithNameTemplate(cfg.GraphiteTemplate))
+	}
+	if cfg.GraphiteGUIDKeys {
+		opts = append(opts, graphite.WithGUIDKeys())
+	}
+
+	return opts
+}
+
+// newGraphiteSender returns a Graphite connection that is not connected
+// yet.
+func newGraphiteSender(cfg Config) *graphite_golang.Graphite {
+	return &graphite_golang.Graphite{
+		Host:     cfg.GraphiteHost,
+		Port:     cfg.GraphitePort,
+		Protocol: "tcp",
+		Timeout:  cfg.SinkTimeout,
+	}
+}
+
+func newGraphiteClient(cfg Config, logger *logging.Logger) reporter.Client {
+	opts := graphiteOptions(cfg, logger)
+	if cfg.DryRun {
+		return graphite.NewClient(dryrun.NewGraphiteClient(os.Stdout), cfg.GraphitePrefix, opts...)
+	}
+
+	if cfg.GraphiteHost == "" || cfg.GraphitePort == 0 {
+		logger.Fatal("--metrics-host and --metrics-port are required for the graphite sink")
+	}
+
+	sender := newGraphiteSender(cfg)
+	if err := sender.Connect(); err != nil {
+		logger.Fatal("error while connecting to graphite", logging.Fields{
+			"host":  cfg.GraphiteHost,
+			"port":  cfg.GraphitePort,
+			"error": err,
+		})
+	}
+
+	return graphite.NewClient(sender, cfg.GraphitePrefix, opts...)
+}
+
+func newStatsDClient(cfg Config, logger *logging.Logger) reporter.Client {
+	opts := []statsd.ClientOption{
+		statsd.WithPrefix(cfg.GraphitePrefix),
+		statsd.WithSchema(cfg.GraphiteSchema),
+		statsd.WithMTU(cfg.StatsDMTU),
+		statsd.WithLogger(logger),
+	}
+	if cfg.GraphiteDualWrite {
+		opts = append(opts, statsd.WithDualWrite())
+	}
+	if cfg.GraphiteTemplate != nil {
+		opts = append(opts, statsd.WithNameTemplate(cfg.GraphiteTemplate))
+	}
+	if cfg.GraphiteGUIDKeys {
+		opts = append(opts, statsd.WithGUIDKeys())
+	}
+	if cfg.StatsDTags {
+		opts = append(opts, statsd.WithDogStatsDTags(cfg.StatsDMetricName))
+	}
+	if cfg.DryRun {
+		opts = append(opts, statsd.WithOutput(os.Stdout))
+	}
+
+	return statsd.NewClient(cfg.StatsDAddr, opts...)
+}
+
+func newDatadogClient(cfg Config, logger *logging.Logger) reporter.Client {
+	var apiKey string
+	if !cfg.DryRun {
+		if cfg.DatadogAPIKeyFile == "" {
+			logger.Fatal("--datadog-api-key-file is required for the datadog sink")
+		}
+
+		apiKey = readSecret(cfg.DatadogAPIKeyFile, "datadog api key", logger)
+	}
+
+	opts := []datadog.ClientOption{
+		datadog.WithHTTPClient(sinkHTTPClient(cfg)),
+		datadog.WithMetricName(cfg.DatadogMetricName),
+		datadog.WithHost(cfg.DatadogHost),
+		datadog.WithBatchSize(cfg.DatadogBatchSize),
+		datadog.WithLogger(logger),
+	}
+	if fannedOut(cfg) {
+		opts = append(opts, datadog.WithRetries(0, 0))
+	}
+
+	return datadog.NewClient(cfg.DatadogURL, apiKey, opts...)
+}
+
+func newOTLPClient(cfg Config, logger *logging.Logger) reporter.Client {
+	opts := []otlp.ClientOption{
+		otlp.WithHTTPClient(sinkHTTPClient(cfg)),
+		otlp.WithMetricName(cfg.OTLPMetricName),
+		otlp.WithBucketWidth(sinkInterval("otlp", cfg)),
+		otlp.WithHeaders(cfg.OTLPHeaders),
+		otlp.WithLogger(logger),
+	}
+	if cfg.OTLPEncoding == "protobuf" {
+		opts = append(opts, otlp.WithEncoding(otlp.ProtobufEncoding))
+	}
+	if cfg.OTLPKind == "delta-sum" {
+		opts = append(opts, otlp.WithKind(otlp.DeltaSumKind))
+	}
+	if cfg.Foundation != "" {
+		opts = append(opts, otlp.WithResourceAttribute("cloudfoundry.foundation", cfg.Foundation))
+	}
+
+	return otlp.NewClient(cfg.OTLPURL, opts...)
+}
+
+func newRemoteWriteClient(cfg Config, logger *logging.Logger) reporter.Client {
+	opts := []remotewrite.ClientOption{
+		remotewrite.WithHTTPClient(sinkHTTPClient(cfg)),
+		remotewrite.WithMetricName(cfg.RemoteWriteMetricName),
+		remotewrite.WithBatchSize(cfg.RemoteWriteBatchSize),
+		remotewrite.WithLabels(cfg.RemoteWriteLabels),
+		remotewrite.WithLogger(logger),
+	}
+	if fannedOut(cfg) {
+		opts = append(opts, remotewrite.WithRetries(0, 0, 0))
+	}
+	if cfg.DryRun {
+		return remotewrite.NewClient(cfg.RemoteWriteURL, opts...)
+	}
+
+	if cfg.RemoteWriteUsername != "" {
+		var password string
+		if cfg.RemoteWritePasswordFile != "" {
+			password = readSecret(cfg.RemoteWritePasswordFile, "remote write password", logger)
+		}
+		opts = append(opts, remotewrite.WithBasicAuth(cfg.RemoteWriteUsername, password))
+	}
+	if cfg.RemoteWriteBearerTokenFile != "" {
+		token := readSecret(cfg.RemoteWriteBearerTokenFile, "remote write bearer token", logger)
+		opts = append(opts, remotewrite.WithBearerToken(token))
+	}
+
+	return remotewrite.NewClient(cfg.RemoteWriteURL, opts...)
+}
+
+func newOpenTSDBClient(cfg Config, logger *logging.Logger) reporter.Client {
+	opts := []opentsdb.ClientOption{
+		opentsdb.WithMetricName(cfg.OpenTSDBMetricName),
+		opentsdb.WithTags(cfg.OpenTSDBTags),
+		opentsdb.WithLogger(logger),
+	}
+
+	if cfg.OpenTSDBProtocol == "telnet" {
+		if cfg.DryRun {
+			opts = append(opts, opentsdb.WithOutput(os.Stdout))
+		}
+		return opentsdb.NewTelnetClient(cfg.OpenTSDBAddr, opts...)
+	}
+
+	opts = append(opts,
+		opentsdb.WithHTTPClient(sinkHTTPClient(cfg)),
+		opentsdb.WithBatchSize(cfg.OpenTSDBBatchSize),
+	)
+
+	return opentsdb.NewHTTPClient(cfg.OpenTSDBURL, opts...)
+}
+
+func newElasticsearchClient(cfg Config, logger *logging.Logger) reporter.Client {
+	opts := []elasticsearch.ClientOption{
+		elasticsearch.WithHTTPClient(sinkHTTPClient(cfg)),
+		elasticsearch.WithIndexPrefix(cfg.ElasticsearchIndexPrefix),
+		elasticsearch.WithIndexDateFormat(cfg.ElasticsearchIndexDateFormat),
+		elasticsearch.WithFoundation(cfg.Foundation),
+		elasticsearch.WithBatchSize(cfg.ElasticsearchBatchSize),
+		elasticsearch.WithLogger(logger),
+	}
+	if cfg.DryRun {
+		return elasticsearch.NewClient(cfg.ElasticsearchURL, opts...)
+	}
+
+	if cfg.ElasticsearchUsername != "" {
+		var password string
+		if cfg.ElasticsearchPasswordFile != "" {
+			password = readSecret(cfg.ElasticsearchPasswordFile, "elasticsearch password", logger)
+		}
+		opts = append(opts, elasticsearch.WithBasicAuth(cfg.ElasticsearchUsername, password))
+	}
+	if cfg.ElasticsearchAPIKeyFile != "" {
+		key := readSecret(cfg.ElasticsearchAPIKeyFile, "elasticsearch api key", logger)
+		opts = append(opts, elasticsearch.WithAPIKey(key))
+	}
+
+	return elasticsearch.NewClient(cfg.ElasticsearchURL, opts...)
+}
+
+func newFileClient(cfg Config, logger *logging.Logger) reporter.Client {
+	if cfg.FilePath == "" && !cfg.DryRun {
+		logger.Fatal("--file-path is required for the file sink")
+	}
+
+	format := file.JSONLinesFormat
+	if cfg.FileFormat == "csv" {
+		format = file.CSVFormat
+	}
+
+	opts := []file.ClientOption{
+		file.WithFormat(format),
+		file.WithMaxSize(cfg.FileMaxSize),
+		file.WithRotateInterval(cfg.FileRotateInterval),
+		file.WithCompress(cfg.FileCompress),
+		file.WithMaxBackups(cfg.FileMaxBackups),
+		file.WithLogger(logger),
+	}
+	if cfg.DryRun {
+		opts = append(opts, file.WithOutput(os.Stdout))
+	}
+
+	return file.NewClient(cfg.FilePath, opts...)
+}
+
+// readSecret returns the trimmed content of a file holding a credential.
+func readSecret(file, what string, logger *logging.Logger) string {
+	secret, err := ioutil.ReadFile(file)
+	if err != nil {
+		logger.Fatal("failed to read "+what, logging.Fields{
+			"file":  file,
+			"error": err,
+		})
+	}
+
+	return strings.TrimSpace(string(secret))
+}
+
+// httpDoer is satisfied by the HTTP clients of all HTTP based sinks.
+type httpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// sinkHTTPClient returns the HTTP client used by HTTP based sinks, with
+// requests bounded by the sink timeout. In dry-run mode requests are printed
+// to stdout instead.
+func sinkHTTPClient(cfg Config) httpDoer {
+	if cfg.DryRun {
+		return dryrun.NewHTTPClient(os.Stdout)
+	}
+
+	return &http.Client{
+		Timeout: cfg.SinkTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: cfg.TLSConfig,
+		},
+	}
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+)
+
+// CachingAuthenticator requests client_credentials tokens from UAA and reuses
+// them until shortly before they expire. Once a token enters its refresh
+// window a new one is requested in the background while the current one
+// keeps being handed out.
+type CachingAuthenticator struct {
+	clientID      string
+	clientSecret  string
+	uaaAddr       string
+	httpClient    HTTPClient
+	refreshMargin time.Duration
+	expirySkew    time.Duration
+	logger        *logging.Logger
+
+	mu         sync.Mutex
+	token      string
+	expiresAt  time.Time
+	refreshing bool
+}
+
+// NewCachingAuthenticator returns an initialized CachingAuthenticator. By
+// default it uses http.DefaultClient, starts refreshing a token one minute
+// before it expires and stops handing it out five seconds before it expires.
+func NewCachingAuthenticator(id, secret, uaaAddr string, opts ...CachingAuthenticatorOption) *CachingAuthenticator {
+	a := &CachingAuthenticator{
+		clientID:      id,
+		clientSecret:  secret,
+		uaaAddr:       uaaAddr,
+		httpClient:    http.DefaultClient,
+		refreshMargin: time.Minute,
+		expirySkew:    5 * time.Second,
+		logger:        logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(a)
+	}
+
+	return a
+}
+
+// RefreshAuthToken satisfies the collector Authenticator interface. It
+// returns the cached token while it is valid and only blocks on UAA when
+// there is no usable token.
+func (a *CachingAuthenticator) RefreshAuthToken() (string, error) {
+	a.mu.Lock()
+	now := time.Now()
+
+	if a.token != "" && now.Before(a.expiresAt.Add(-a.expirySkew)) {
+		token := a.token
+		if !a.refreshing && !now.Before(a.expiresAt.Add(-a.refreshMargin)) {
+			a.refreshing = true
+			go a.refreshInBackground()
+		}
+		a.mu.Unlock()
+
+		return token, nil
+	}
+	a.mu.Unlock()
+
+	return a.refresh()
+}
+
+// Invalidate drops the cached token if it is still the given one, so that
+// the next call to RefreshAuthToken requests a new token from UAA. It is
+// used when a token is rejected before its advertised expiry.
+func (a *CachingAuthenticator) Invalidate(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == token {
+		a.token = ""
+		a.expiresAt = time.Time{}
+	}
+}
+
+func (a *CachingAuthenticator) refreshInBackground() {
+	defer func() {
+		a.mu.Lock()
+		a.refreshing = false
+		a.mu.Unlock()
+	}()
+
+	if _, err := a.refresh(); err != nil {
+		a.logger.Warn("failed to refresh auth token in the background", logging.Fields{
+			"stage": "auth",
+			"error": err,
+		})
+	}
+}
+
+func (a *CachingAuthenticator) refresh() (string, error) {
+	requestedAt := time.Now()
+
+	token, expiresIn, err := a.requestToken()
+	if err != nil {
+		return "", err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token = token
+	a.expiresAt = requestedAt.Add(expiresIn)
+
+	return token, nil
+}
+
+func (a *CachingAuthenticator) requestToken() (string, time.Duration, error) {
+	response, err := a.httpClient.PostForm(a.uaaAddr+"/oauth/token", url.Values{
+		"response_type": {"token"},
+		"grant_type":    {"client_credentials"},
+		"client_id":     {a.clientID},
+		"client_secret": {a.clientSecret},
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("expected 200 status code from /oauth/token, got %d", response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var oauthResponse tokenResponse
+	err = json.Unmarshal(body, &oauthResponse)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if oauthResponse.AccessToken == "" {
+		return "", 0, errors.New("no access_token on UAA oauth response")
+	}
+
+	return oauthResponse.AccessToken, time.Duration(oauthResponse.ExpiresIn) * time.Second, nil
+}
+
+// tokenResponse represents the relevant fields of a UAA /oauth/token
+// response. A missing expires_in results in a token that is never reused.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// HTTPClient is an interface that http.Client conforms to.
+type HTTPClient interface {
+	PostForm(string, url.Values) (*http.Response, error)
+}
+
+// CachingAuthenticatorOption is a type of function that can be passed into
+// NewCachingAuthenticator for optional configuration.
+type CachingAuthenticatorOption func(*CachingAuthenticator)
+
+// WithHTTPClient is a CachingAuthenticatorOption to configure the HTTPClient
+// to be used for requests to UAA.
+func WithHTTPClient(c HTTPClient) CachingAuthenticatorOption {
+	return func(a *CachingAuthenticator) {
+		a.httpClient = c
+	}
+}
+
+// WithRefreshMargin is a CachingAuthenticatorOption to configure how long
+// before its expiry a token is refreshed in the background.
+func WithRefreshMargin(d time.Duration) CachingAuthenticatorOption {
+	return func(a *CachingAuthenticator) {
+		a.refreshMargin = d
+	}
+}
+
+// WithExpirySkew is a CachingAuthenticatorOption to configure how long
+// before its expiry a token stops being handed out, to allow for clock skew
+// and request latency.
+func WithExpirySkew(d time.Duration) CachingAuthenticatorOption {
+	return func(a *CachingAuthenticator) {
+		a.expirySkew = d
+	}
+}
+
+// WithLogger is a CachingAuthenticatorOption to configure the logger used by
+// the CachingAuthenticator.
+func WithLogger(l *logging.Logger) CachingAuthenticatorOption {
+	return func(a *CachingAuthenticator) {
+		a.logger = l
+	}
+}
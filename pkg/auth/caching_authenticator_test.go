@@ -0,0 +1,155 @@
+package auth_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/auth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CachingAuthenticator", func() {
+	var uaa *fakeUAA
+
+	BeforeEach(func() {
+		uaa = &fakeUAA{expiresIn: 3600}
+		uaa.server = httptest.NewServer(uaa)
+	})
+
+	AfterEach(func() {
+		uaa.server.Close()
+	})
+
+	It("reuses a token until it expires", func() {
+		a := auth.NewCachingAuthenticator("id", "secret", uaa.server.URL)
+
+		first, err := a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+		second, err := a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(first).To(Equal("token-1"))
+		Expect(second).To(Equal("token-1"))
+		Expect(uaa.requestCount()).To(Equal(1))
+	})
+
+	It("requests a new token when expires_in is missing", func() {
+		uaa.expiresIn = 0
+		a := auth.NewCachingAuthenticator("id", "secret", uaa.server.URL)
+
+		_, err := a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+		token, err := a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(token).To(Equal("token-2"))
+		Expect(uaa.requestCount()).To(Equal(2))
+	})
+
+	It("refreshes a token in the background when it is about to expire", func() {
+		a := auth.NewCachingAuthenticator("id", "secret", uaa.server.URL,
+			auth.WithRefreshMargin(2*time.Hour),
+		)
+
+		_, err := a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+		token, err := a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(token).To(Equal("token-1"))
+		Eventually(uaa.requestCount).Should(Equal(2))
+		Eventually(func() (string, error) {
+			return a.RefreshAuthToken()
+		}).Should(Equal("token-2"))
+	})
+
+	It("requests a new token after it has been invalidated", func() {
+		a := auth.NewCachingAuthenticator("id", "secret", uaa.server.URL)
+
+		token, err := a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+		a.Invalidate(token)
+		token, err = a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(token).To(Equal("token-2"))
+	})
+
+	It("ignores invalidation of a token that has already been replaced", func() {
+		a := auth.NewCachingAuthenticator("id", "secret", uaa.server.URL)
+
+		_, err := a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+		a.Invalidate("some-old-token")
+		_, err = a.RefreshAuthToken()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(uaa.requestCount()).To(Equal(1))
+	})
+
+	It("returns an error when UAA does not respond with a 200", func() {
+		uaa.status = http.StatusUnauthorized
+		a := auth.NewCachingAuthenticator("id", "secret", uaa.server.URL)
+
+		_, err := a.RefreshAuthToken()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when the response has no access token", func() {
+		uaa.omitToken = true
+		a := auth.NewCachingAuthenticator("id", "secret", uaa.server.URL)
+
+		_, err := a.RefreshAuthToken()
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+type fakeUAA struct {
+	server    *httptest.Server
+	expiresIn int
+	status    int
+	omitToken bool
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (f *fakeUAA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	n := f.requests
+	f.mu.Unlock()
+
+	if r.URL.Path != "/oauth/token" || r.FormValue("grant_type") != "client_credentials" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+
+	if f.omitToken {
+		fmt.Fprint(w, `{"token_type": "bearer"}`)
+		return
+	}
+
+	if f.expiresIn == 0 {
+		fmt.Fprintf(w, `{"access_token": "token-%d"}`, n)
+		return
+	}
+
+	fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": %d}`, n, f.expiresIn)
+}
+
+func (f *fakeUAA) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// TokenSource provides auth tokens and allows rejected tokens to be
+// discarded.
+type TokenSource interface {
+	RefreshAuthToken() (string, error)
+	Invalidate(token string)
+}
+
+// Transport is an http.RoundTripper that retries a request once with a fresh
+// token when it is rejected with a 401. It is meant to be used for the
+// requests the collector issues against the accumulators.
+type Transport struct {
+	base   http.RoundTripper
+	tokens TokenSource
+}
+
+// NewTransport returns a Transport that sends requests using base and
+// obtains fresh tokens from tokens.
+func NewTransport(base http.RoundTripper, tokens TokenSource) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{
+		base:   base,
+		tokens: tokens,
+	}
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	token, ok := bearerToken(req)
+	if !ok || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	t.tokens.Invalidate(token)
+	fresh, err := t.tokens.RefreshAuthToken()
+	if err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", fmt.Sprintf("Bearer %s", fresh))
+
+	return t.base.RoundTrip(retry)
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	const prefix = "Bearer "
+
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(header, prefix), true
+}
@@ -0,0 +1,107 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/auth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	var (
+		accumulator *httptest.Server
+		tokens      *spyTokenSource
+		client      *http.Client
+	)
+
+	BeforeEach(func() {
+		accumulator = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer valid" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		tokens = &spyTokenSource{token: "valid"}
+		client = &http.Client{Transport: auth.NewTransport(nil, tokens)}
+	})
+
+	AfterEach(func() {
+		accumulator.Close()
+	})
+
+	It("does not retry successful requests", func() {
+		resp, err := client.Do(request(accumulator.URL, "valid"))
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(tokens.invalidated()).To(BeEmpty())
+	})
+
+	It("retries once with a fresh token when the request is unauthorized", func() {
+		resp, err := client.Do(request(accumulator.URL, "expired"))
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(tokens.invalidated()).To(ConsistOf("expired"))
+	})
+
+	It("returns the second unauthorized response without retrying again", func() {
+		tokens.token = "still-invalid"
+
+		resp, err := client.Do(request(accumulator.URL, "expired"))
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(tokens.invalidated()).To(ConsistOf("expired"))
+	})
+
+	It("does not retry requests without a bearer token", func() {
+		req, err := http.NewRequest(http.MethodGet, accumulator.URL, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := client.Do(req)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(tokens.invalidated()).To(BeEmpty())
+	})
+})
+
+func request(addr, token string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, addr+"/rates/1234", nil)
+	Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return req
+}
+
+type spyTokenSource struct {
+	mu           sync.Mutex
+	token        string
+	_invalidated []string
+}
+
+func (s *spyTokenSource) RefreshAuthToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.token, nil
+}
+
+func (s *spyTokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._invalidated = append(s._invalidated, token)
+}
+
+func (s *spyTokenSource) invalidated() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s._invalidated
+}
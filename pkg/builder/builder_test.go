@@ -1,49 +1,151 @@
 package builder_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
 	nn_collector "code.cloudfoundry.org/noisy-neighbor-nozzle/pkg/collector"
 	nn_store "code.cloudfoundry.org/noisy-neighbor-nozzle/pkg/store"
 
-	graphite_builder "github.com/SpringerPE/noisy-neighbor-reporters/pkg/builder/graphite"
-	graphite "github.com/marpaia/graphite-golang"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/builder"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-var _ = Describe("GraphiteBuilder", func() {
+var _ = Describe("PointBuilder", func() {
 
-	It("can build a set of graphite points from some appInfo metrics", func() {
+	It("can build a set of points from some appInfo metrics", func() {
 		fetcher := &fakeFetcher{}
 		store := &fakeStore{path: "happyPath"}
 
-		b := graphite_builder.NewGraphiteBuilder(fetcher, store, "test")
+		b := builder.NewPointBuilder(fetcher, store)
 		points, err := b.BuildPoints(1520259517)
 
 		Expect(err).ToNot(HaveOccurred())
 		Expect(points).To(HaveLen(2))
 
-		expectPointA := graphite.Metric{
-			Name:      "test.org1.space1.app1.0",
-			Value:     "2",
+		Expect(points).To(ContainElement(point.Point{
+			Name:      point.Ingress,
+			Timestamp: 1520259517,
+			Value:     2,
+			Total:     5,
+			Identity: point.Identity{
+				Org:     "org1",
+				Space:   "space1",
+				App:     "app1",
+				AppGUID: "a",
+				Index:   0,
+			},
+		}))
+		Expect(points).To(ContainElement(point.Point{
+			Name:      point.Ingress,
 			Timestamp: 1520259517,
+			Value:     3,
+			Total:     5,
+			Identity: point.Identity{
+				Org:     "org2",
+				Space:   "space2",
+				App:     "app2",
+				AppGUID: "b",
+				Index:   0,
+			},
+		}))
+	})
+
+	It("takes the instance index from the key", func() {
+		fetcher := &fakeFetcher{counts: map[string]uint64{"a": 2, "b/1": 3}}
+		store := &fakeStore{path: "happyPath"}
+
+		b := builder.NewPointBuilder(fetcher, store)
+		points, err := b.BuildPoints(1520259517)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values(points, point.Ingress)).To(Equal(map[string]float64{"a/0": 2, "b/1": 3}))
+	})
+
+	It("adds the configured labels to every point", func() {
+		fetcher := &fakeFetcher{}
+		store := &fakeStore{path: "happyPath"}
+
+		b := builder.NewPointBuilder(fetcher, store,
+			builder.WithLabels(map[string]string{"foundation": "eu"}),
+		)
+		points, err := b.BuildPoints(1520259517)
+
+		Expect(err).ToNot(HaveOccurred())
+		for _, p := range points {
+			Expect(p.Labels).To(Equal(map[string]string{"foundation": "eu"}))
 		}
+	})
 
-		expectPointB := graphite.Metric{
-			Name:      "test.org2.space2.app2.0",
-			Value:     "3",
-			Timestamp: 1520259517,
+	It("derives rates per second and shares of the foundation total", func() {
+		fetcher := &fakeFetcher{counts: map[string]uint64{"a": 2, "b/0": 1, "b/1": 3, "unknown/0": 4}}
+		store := &fakeStore{path: "happyPath"}
+
+		b := builder.NewPointBuilder(fetcher, store,
+			builder.WithDerivedSeries(point.Rate, point.Share),
+			builder.WithBucketWidth(10*time.Second),
+		)
+		points, err := b.BuildPoints(1520259517)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(point.Only(points, point.Ingress)).To(HaveLen(3))
+		Expect(values(points, point.Rate)).To(Equal(map[string]float64{"a/0": 0.2, "b/0": 0.1, "b/1": 0.3}))
+		shares := values(points, point.Share)
+		Expect(shares).To(HaveLen(5))
+		for key, share := range map[string]float64{
+			"a/0":  20,
+			"b/0":  10,
+			"b/1":  30,
+			"a/-1": 20,
+			"b/-1": 40,
+		} {
+			Expect(shares[key]).To(BeNumerically("~", share, 1e-9), key)
 		}
+		Expect(point.Only(points, point.Share)[3].Identity).To(Equal(point.Identity{
+			Org:     "org1",
+			Space:   "space1",
+			App:     "app1",
+			AppGUID: "a",
+			Index:   -1,
+		}))
+	})
 
-		Expect(points).To(ContainElement(expectPointA))
-		Expect(points).To(ContainElement(expectPointB))
+	It("derives deltas from the previous interval", func() {
+		fetcher := &fakeFetcher{}
+		store := &fakeStore{path: "happyPath"}
+
+		b := builder.NewPointBuilder(fetcher, store,
+			builder.WithDerivedSeries(point.Delta),
+			builder.WithInterval(time.Minute),
+		)
+		points, err := b.BuildPoints(1520259480)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values(points, point.Delta)).To(BeEmpty())
+
+		fetcher.counts = map[string]uint64{"a": 5, "b": 1, "b/2": 4}
+		points, err = b.BuildPoints(1520259540)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values(points, point.Delta)).To(Equal(map[string]float64{"a/0": 3, "b/0": -2, "b/2": 4}))
+
+		points, err = b.BuildPoints(1520259660)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values(points, point.Delta)).To(BeEmpty())
 	})
 
 	It("it excludes metrics with missing fields", func() {
 		fetcher := &fakeFetcher{}
 		store := &fakeStore{path: "missingInfo"}
 
-		b := graphite_builder.NewGraphiteBuilder(fetcher, store, "test")
+		b := builder.NewPointBuilder(fetcher, store)
 		points, err := b.BuildPoints(1520259517)
 
 		Expect(err).ToNot(HaveOccurred())
@@ -54,30 +156,150 @@ var _ = Describe("GraphiteBuilder", func() {
 		fetcher := &fakeFetcher{}
 		store := &fakeStore{path: "missingCacheInfo"}
 
-		b := graphite_builder.NewGraphiteBuilder(fetcher, store, "test")
+		b := builder.NewPointBuilder(fetcher, store)
 		points, err := b.BuildPoints(1520259517)
 
 		Expect(err).ToNot(HaveOccurred())
 		Expect(points).To(HaveLen(0))
 	})
+
+	It("logs a single aggregated warning for metrics which are not being cached", func() {
+		fetcher := &fakeFetcher{}
+		store := &fakeStore{path: "missingCacheInfo"}
+		buf := &bytes.Buffer{}
+
+		b := builder.NewPointBuilder(fetcher, store,
+			builder.WithBuilderLogger(logging.New(buf)),
+		)
+		_, err := b.BuildPoints(1520259517)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = b.BuildPoints(1520259577)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(strings.Count(buf.String(), "\n")).To(Equal(1))
+		Expect(buf.String()).To(ContainSubstring(`"distinct":2`))
+		Expect(buf.String()).To(ContainSubstring(`"occurrences":2`))
+	})
+
+	Describe("fetching from the accumulator", func() {
+		var status int
+
+		accumulator := func() *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/rates/1520259517"))
+				w.WriteHeader(status)
+			}))
+		}
+
+		collector := func(url string, transport http.RoundTripper) *nn_collector.Collector {
+			return nn_collector.New([]string{url}, fakeAuth{}, "", &fakeStore{path: "happyPath"},
+				nn_collector.WithHTTPClient(&http.Client{Transport: transport}),
+			)
+		}
+
+		It("reports a bucket the accumulator does not have yet as not available", func() {
+			status = http.StatusNotFound
+			server := accumulator()
+			defer server.Close()
+
+			rateStatus := builder.NewRateStatusTransport(http.DefaultTransport)
+			b := builder.NewPointBuilder(collector(server.URL, rateStatus), &fakeStore{path: "happyPath"},
+				builder.WithRateStatus(rateStatus),
+			)
+			_, err := b.BuildPoints(1520259517)
+
+			Expect(errors.Is(err, builder.ErrRateNotAvailable)).To(BeTrue())
+			Expect(rateStatus.NotFound(1520259517)).To(BeFalse())
+		})
+
+		It("recognizes the error of the collector without the status", func() {
+			status = http.StatusNotFound
+			server := accumulator()
+			defer server.Close()
+
+			b := builder.NewPointBuilder(collector(server.URL, http.DefaultTransport), &fakeStore{path: "happyPath"})
+			_, err := b.BuildPoints(1520259517)
+
+			Expect(errors.Is(err, builder.ErrRateNotAvailable)).To(BeTrue())
+		})
+
+		It("returns other failed requests unchanged", func() {
+			status = http.StatusInternalServerError
+			server := accumulator()
+			defer server.Close()
+
+			rateStatus := builder.NewRateStatusTransport(http.DefaultTransport)
+			b := builder.NewPointBuilder(collector(server.URL, rateStatus), &fakeStore{path: "happyPath"},
+				builder.WithRateStatus(rateStatus),
+			)
+			_, err := b.BuildPoints(1520259517)
+
+			Expect(err).To(MatchError(ContainSubstring("got 500")))
+			Expect(errors.Is(err, builder.ErrRateNotAvailable)).To(BeFalse())
+		})
+	})
+
+	It("reports a bucket with a different timestamp as not available", func() {
+		fetcher := &fakeFetcher{timestampOffset: -60}
+		store := &fakeStore{path: "happyPath"}
+
+		b := builder.NewPointBuilder(fetcher, store)
+		_, err := b.BuildPoints(1520259517)
+
+		Expect(errors.Is(err, builder.ErrRateNotAvailable)).To(BeTrue())
+	})
+
+	It("returns other fetch errors unchanged", func() {
+		fetcher := &fakeFetcher{err: errors.New("connection refused")}
+		store := &fakeStore{path: "happyPath"}
+
+		b := builder.NewPointBuilder(fetcher, store)
+		_, err := b.BuildPoints(1520259517)
+
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, builder.ErrRateNotAvailable)).To(BeFalse())
+	})
 })
 
+func values(points []point.Point, kind string) map[string]float64 {
+	v := make(map[string]float64)
+	for _, p := range point.Only(points, kind) {
+		v[fmt.Sprintf("%s/%d", p.Identity.AppGUID, p.Identity.Index)] = p.Value
+	}
+	return v
+}
+
 type fakeFetcher struct {
+	err             error
+	timestampOffset int64
+	counts          map[string]uint64
 }
 
 func (f *fakeFetcher) Rate(timestamp int64) (nn_store.Rate, error) {
+	if f.err != nil {
+		return nn_store.Rate{}, f.err
+	}
 
 	rate := nn_store.Rate{
-		timestamp,
-		map[string]uint64{
+		Timestamp: timestamp + f.timestampOffset,
+		Counts: map[string]uint64{
 			"a": 2,
 			"b": 3,
 		},
 	}
+	if f.counts != nil {
+		rate.Counts = f.counts
+	}
 
 	return rate, nil
 }
 
+type fakeAuth struct{}
+
+func (fakeAuth) RefreshAuthToken() (string, error) {
+	return "token", nil
+}
+
 type fakeStore struct {
 	path string
 }
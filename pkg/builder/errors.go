@@ -0,0 +1,8 @@
+package builder
+
+import "errors"
+
+// ErrRateNotAvailable is returned when the accumulators have not collected
+// the requested bucket yet. Requesting the same bucket again later may
+// succeed.
+var ErrRateNotAvailable = errors.New("rate is not available yet")
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 
 	nn_collector "code.cloudfoundry.org/noisy-neighbor-nozzle/pkg/collector"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
 )
 
 // AppGUID represets an application GUID.
@@ -23,14 +24,34 @@ type HTTPClient interface {
 type HTTPAppInfoStore struct {
 	apiAddr string
 	client  HTTPClient
+	logger  *logging.Logger
 }
 
 // NewCFLightApiAppInfoStore initializes an APIStore and sends all HTTP requests to
 // the API URL specified by apiAddr.
-func NewCFLightApiAppInfoStore(apiAddr string, client HTTPClient) nn_collector.AppInfoStore {
-	return &HTTPAppInfoStore{
+func NewCFLightApiAppInfoStore(apiAddr string, client HTTPClient, opts ...HTTPAppInfoStoreOption) nn_collector.AppInfoStore {
+	s := &HTTPAppInfoStore{
 		apiAddr: apiAddr,
 		client:  client,
+		logger:  logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+// HTTPAppInfoStoreOption is a func that is used to configure optional
+// settings on a HTTPAppInfoStore.
+type HTTPAppInfoStoreOption func(*HTTPAppInfoStore)
+
+// WithLogger returns a HTTPAppInfoStoreOption for configuring the logger used
+// by the HTTPAppInfoStore.
+func WithLogger(l *logging.Logger) HTTPAppInfoStoreOption {
+	return func(s *HTTPAppInfoStore) {
+		s.logger = l
 	}
 }
 
@@ -39,7 +60,10 @@ func NewCFLightApiAppInfoStore(apiAddr string, client HTTPClient) nn_collector.A
 func (s *HTTPAppInfoStore) Lookup(guids []string) (
 	map[nn_collector.AppGUID]nn_collector.AppInfo, error) {
 
-	log.Println("Looking up apps...")
+	s.logger.Debug("looking up apps", logging.Fields{
+		"stage": "lookup",
+		"guids": len(guids),
+	})
 	if len(guids) < 1 {
 		return nil, nil
 	}
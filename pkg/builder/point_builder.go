@@ -0,0 +1,330 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	nn_collector "code.cloudfoundry.org/noisy-neighbor-nozzle/pkg/collector"
+	nn_store "code.cloudfoundry.org/noisy-neighbor-nozzle/pkg/store"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Fetcher provides a way of gathering the rates from the nozzles
+type Fetcher interface {
+	Rate(timestamp int64) (nn_store.Rate, error)
+}
+
+// PointBuilder fetches the rates of an accumulator bucket and resolves the
+// app instances they belong to. The points it builds are independent of
+// the format they are sent in.
+//
+// Besides the ingress it can derive series that compare across bucket
+// widths and foundations: the rate per second of every instance, the share
+// of the total ingress of the foundation of every instance and app, as a
+// percentage (see point.Shares), and the delta of every instance from the
+// previous interval. Instances missing from the previous interval count as
+// zero; no deltas are derived for the first interval or after a missed one.
+type PointBuilder struct {
+	fetcher     Fetcher
+	store       nn_collector.AppInfoStore
+	rateStatus  RateStatus
+	labels      map[string]string
+	derived     map[string]bool
+	bucketWidth time.Duration
+	interval    time.Duration
+	logger      *logging.Logger
+	unresolved  *logging.Aggregator
+
+	previous       map[instance]float64
+	previousBucket int64
+}
+
+// NewPointBuilder initializes and returns a new PointBuilder.
+func NewPointBuilder(
+	fetcher Fetcher,
+	store nn_collector.AppInfoStore,
+	opts ...PointBuilderOption,
+) *PointBuilder {
+
+	b := &PointBuilder{
+		fetcher:     fetcher,
+		store:       store,
+		derived:     make(map[string]bool),
+		bucketWidth: time.Minute,
+		logger:      logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	if b.interval == 0 {
+		b.interval = b.bucketWidth
+	}
+
+	b.unresolved = logging.NewAggregator(
+		b.logger.With(logging.Fields{"stage": "build"}),
+		"failed to extract metric metadata from API lookup",
+		5*time.Minute,
+	)
+
+	return b
+}
+
+// BuildPoints returns one ingress point per app instance for the bucket
+// starting at timestamp, followed by the configured derived points. App
+// instances whose org, space and app names are not known are left out.
+func (b *PointBuilder) BuildPoints(timestamp int64) ([]point.Point, error) {
+	logger := b.logger.With(logging.Fields{"timestamp": timestamp})
+
+	rate, err := b.fetcher.Rate(timestamp)
+	if err != nil {
+		if b.notFound(timestamp, err) {
+			return nil, fmt.Errorf("bucket %d: %w", timestamp, ErrRateNotAvailable)
+		}
+		return nil, err
+	}
+	if rate.Timestamp != timestamp {
+		return nil, fmt.Errorf("bucket %d: accumulator returned bucket %d: %w",
+			timestamp, rate.Timestamp, ErrRateNotAvailable)
+	}
+	logger.Debug("fetched rates", logging.Fields{
+		"stage":  "fetch",
+		"counts": len(rate.Counts),
+	})
+
+	var total uint64
+	for _, v := range rate.Counts {
+		total += v
+	}
+
+	var guids []string
+	for guidIndex := range rate.Counts {
+		guids = append(guids, GUIDIndex(guidIndex).GUID())
+	}
+	// The underlying cached store does not return an error and instead simply
+	// returns the cache when an error occurs.
+	appInfo, err := b.store.Lookup(guids)
+	if err != nil {
+		logger.Warn("failed to collect app metadata from API lookup", logging.Fields{
+			"stage": "lookup",
+			"error": err,
+		})
+	}
+
+	var points []point.Point
+	for guidIndex, value := range rate.Counts {
+		gi := GUIDIndex(guidIndex)
+
+		info, ok := appInfo[nn_collector.AppGUID(gi.GUID())]
+		if !ok || !checkOrgSpaceAppNameIsNotEmpty(info) {
+			logger.Debug("failed to extract metric metadata from API lookup", logging.Fields{
+				"stage":    "build",
+				"app_guid": gi.GUID(),
+				"index":    gi.Index(),
+			})
+			b.unresolved.Observe(gi.GUID())
+			continue
+		}
+
+		index, err := strconv.Atoi(gi.Index())
+		if err != nil {
+			logger.Debug("skipping rate with invalid instance index", logging.Fields{
+				"stage":    "build",
+				"app_guid": gi.GUID(),
+				"index":    gi.Index(),
+			})
+			continue
+		}
+
+		points = append(points, point.Point{
+			Name:      point.Ingress,
+			Timestamp: rate.Timestamp,
+			Value:     float64(value),
+			Identity: point.Identity{
+				Org:     info.Org,
+				Space:   info.Space,
+				App:     info.Name,
+				AppGUID: gi.GUID(),
+				Index:   index,
+			},
+			Labels: b.labels,
+			Total:  float64(total),
+		})
+	}
+	b.unresolved.Flush()
+
+	points = append(points, b.derive(points, rate)...)
+
+	return points, nil
+}
+
+// derive returns the configured derived points of the ingress points built
+// from rate, and remembers its counts for the deltas of the next interval.
+func (b *PointBuilder) derive(points []point.Point, rate nn_store.Rate) []point.Point {
+	var previous map[instance]float64
+	if b.previous != nil && b.previousBucket == rate.Timestamp-int64(b.interval/time.Second) {
+		previous = b.previous
+	}
+	if b.derived[point.Delta] {
+		b.previous = make(map[instance]float64, len(points))
+		b.previousBucket = rate.Timestamp
+		for _, p := range points {
+			b.previous[instance{p.Identity.AppGUID, p.Identity.Index}] = p.Value
+		}
+	}
+
+	var shares []float64
+	if b.derived[point.Share] {
+		shares = point.Shares(points)
+	}
+
+	var derived []point.Point
+	apps := make(map[string]*point.Point)
+	for i, p := range points {
+		if b.derived[point.Rate] {
+			derived = append(derived, derivedPoint(p, point.Rate, p.Value/b.bucketWidth.Seconds()))
+		}
+		if b.derived[point.Share] {
+			derived = append(derived, derivedPoint(p, point.Share, shares[i]))
+
+			app, ok := apps[p.Identity.AppGUID]
+			if !ok {
+				a := derivedPoint(p, point.Share, 0)
+				a.Identity.Index = -1
+				app = &a
+				apps[p.Identity.AppGUID] = app
+			}
+			app.Value += shares[i]
+		}
+		if b.derived[point.Delta] && previous != nil {
+			last := previous[instance{p.Identity.AppGUID, p.Identity.Index}]
+			derived = append(derived, derivedPoint(p, point.Delta, p.Value-last))
+		}
+	}
+
+	guids := make([]string, 0, len(apps))
+	for guid := range apps {
+		guids = append(guids, guid)
+	}
+	sort.Strings(guids)
+	for _, guid := range guids {
+		derived = append(derived, *apps[guid])
+	}
+
+	return derived
+}
+
+// notFound reports whether the accumulators responded with a 404 to the
+// request for the bucket, which they do for buckets they do not have.
+// Without a RateStatus only the error message of the collector tells.
+func (b *PointBuilder) notFound(timestamp int64, err error) bool {
+	if b.rateStatus != nil {
+		return b.rateStatus.NotFound(timestamp)
+	}
+
+	return strings.Contains(err.Error(), "got 404")
+}
+
+// instance identifies an app instance across intervals.
+type instance struct {
+	guid  string
+	index int
+}
+
+func derivedPoint(p point.Point, kind string, value float64) point.Point {
+	p.Name = kind
+	p.Value = value
+	p.Total = 0
+	return p
+}
+
+// PointBuilderOption is a func that is used to configure optional settings
+// on a PointBuilder.
+type PointBuilderOption func(*PointBuilder)
+
+// WithLabels returns a PointBuilderOption for configuring labels added to
+// every point, e.g. the foundation.
+func WithLabels(labels map[string]string) PointBuilderOption {
+	return func(b *PointBuilder) {
+		b.labels = labels
+	}
+}
+
+// WithDerivedSeries returns a PointBuilderOption for configuring the kinds of
+// points derived from the ingress: point.Rate, point.Share and point.Delta.
+func WithDerivedSeries(kinds ...string) PointBuilderOption {
+	return func(b *PointBuilder) {
+		for _, k := range kinds {
+			b.derived[k] = true
+		}
+	}
+}
+
+// WithBucketWidth returns a PointBuilderOption for configuring the width of
+// the accumulator buckets rates per second are derived with. It defaults to
+// a minute.
+func WithBucketWidth(d time.Duration) PointBuilderOption {
+	return func(b *PointBuilder) {
+		b.bucketWidth = d
+	}
+}
+
+// WithInterval returns a PointBuilderOption for configuring the interval
+// between the buckets points are built for, i.e. the report interval. Deltas
+// are only derived from the bucket one interval earlier. It defaults to the
+// bucket width.
+func WithInterval(d time.Duration) PointBuilderOption {
+	return func(b *PointBuilder) {
+		b.interval = d
+	}
+}
+
+// WithRateStatus returns a PointBuilderOption for configuring how a bucket
+// the accumulators do not have is told apart from other failed fetches,
+// e.g. a RateStatusTransport of the collector's HTTP client.
+func WithRateStatus(s RateStatus) PointBuilderOption {
+	return func(b *PointBuilder) {
+		b.rateStatus = s
+	}
+}
+
+// WithBuilderLogger returns a PointBuilderOption for configuring the logger
+// used by the PointBuilder.
+func WithBuilderLogger(l *logging.Logger) PointBuilderOption {
+	return func(b *PointBuilder) {
+		b.logger = l
+	}
+}
+
+func checkOrgSpaceAppNameIsNotEmpty(orgSpaceAppName nn_collector.AppInfo) bool {
+
+	if orgSpaceAppName.Name != "" && orgSpaceAppName.Space != "" && orgSpaceAppName.Org != "" {
+		return true
+	}
+
+	return false
+}
+
+// GUIDIndex is a concatentation of GUID and instance index in the format
+// some-guid/some-index, e.g., 7b8228a0-cf40-42d8-a7bb-b287a88198a3/0
+type GUIDIndex string
+
+// GUID returns the GUID of the GUIDIndex
+func (g GUIDIndex) GUID() string {
+	return strings.Split(string(g), "/")[0]
+}
+
+// Index returns the Index of the GUIDIndex
+func (g GUIDIndex) Index() string {
+	parts := strings.Split(string(g), "/")
+	if len(parts) < 2 {
+		return "0"
+	}
+	return parts[1]
+}
@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+)
+
+// RateStatus tells whether the accumulators responded that they do not
+// have a bucket.
+type RateStatus interface {
+	NotFound(timestamp int64) bool
+}
+
+// RateStatusTransport is an http.RoundTripper for the HTTP client of the
+// collector that records the buckets the accumulators responded to with a
+// 404, since the collector only reports the status code in its error
+// message.
+type RateStatusTransport struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	notFound map[int64]bool
+}
+
+// NewRateStatusTransport returns a RateStatusTransport sending requests
+// with next.
+func NewRateStatusTransport(next http.RoundTripper) *RateStatusTransport {
+	return &RateStatusTransport{
+		next:     next,
+		notFound: make(map[int64]bool),
+	}
+}
+
+// RoundTrip sends the request and records a 404 response to a request for
+// the rates of a bucket.
+func (t *RateStatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		return resp, err
+	}
+
+	dir, file := path.Split(req.URL.Path)
+	if path.Base(dir) != "rates" {
+		return resp, err
+	}
+	timestamp, pErr := strconv.ParseInt(file, 10, 64)
+	if pErr != nil {
+		return resp, err
+	}
+
+	t.mu.Lock()
+	t.notFound[timestamp] = true
+	t.mu.Unlock()
+
+	return resp, err
+}
+
+// NotFound reports whether an accumulator responded with a 404 to the last
+// request for the rates of the bucket, and forgets it.
+func (t *RateStatusTransport) NotFound(timestamp int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	notFound := t.notFound[timestamp]
+	delete(t.notFound, timestamp)
+
+	return notFound
+}
@@ -0,0 +1,43 @@
+package dryrun
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	graphite "github.com/marpaia/graphite-golang"
+)
+
+// GraphiteClient satisfies the reporter GraphiteClient interface by writing
+// the exact carbon plaintext lines that would be sent to Graphite to an
+// io.Writer instead.
+type GraphiteClient struct {
+	out io.Writer
+}
+
+// NewGraphiteClient returns a GraphiteClient writing to out.
+func NewGraphiteClient(out io.Writer) *GraphiteClient {
+	return &GraphiteClient{out: out}
+}
+
+// SendMetrics writes one carbon line per metric.
+func (c *GraphiteClient) SendMetrics(metrics []graphite.Metric) error {
+	w := bufio.NewWriter(c.out)
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "%s %s %d\n", m.Name, m.Value, m.Timestamp); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// Connect is a no-op.
+func (c *GraphiteClient) Connect() error {
+	return nil
+}
+
+// Disconnect is a no-op.
+func (c *GraphiteClient) Disconnect() error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package dryrun_test
+
+import (
+	"bytes"
+
+	graphite "github.com/marpaia/graphite-golang"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/dryrun"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GraphiteClient", func() {
+	It("writes carbon plaintext lines", func() {
+		buf := &bytes.Buffer{}
+		client := dryrun.NewGraphiteClient(buf)
+
+		Expect(client.Connect()).To(Succeed())
+		err := client.SendMetrics([]graphite.Metric{
+			{Name: "test.org1.space1.app1.0", Value: "2", Timestamp: 1520259517},
+			{Name: "test.org2.space2.app2.1", Value: "3", Timestamp: 1520259517},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.Disconnect()).To(Succeed())
+
+		Expect(buf.String()).To(Equal(
+			"test.org1.space1.app1.0 2 1520259517\n" +
+				"test.org2.space2.app2.1 3 1520259517\n",
+		))
+	})
+})
@@ -0,0 +1,80 @@
+package dryrun
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/protobuf"
+	"github.com/golang/snappy"
+)
+
+// HTTPClient satisfies the HTTPClient interfaces of the HTTP based sinks by
+// writing the method, URL and body of every request to an io.Writer instead
+// of sending it. Gzipped and snappy compressed bodies are decompressed, and
+// protobuf bodies are written as text (see protobuf.Format). Every request
+// is answered with an empty 200 response.
+type HTTPClient struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewHTTPClient returns an HTTPClient writing to out.
+func NewHTTPClient(out io.Writer) *HTTPClient {
+	return &HTTPClient{out: out}
+}
+
+// Do writes the request to the output.
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var r io.Reader = req.Body
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			r = gz
+		}
+
+		var err error
+		body, err = ioutil.ReadAll(r)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if req.Header.Get("Content-Encoding") == "snappy" {
+			body, err = snappy.Decode(nil, body)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if req.Header.Get("Content-Type") == "application/x-protobuf" {
+			text, err := protobuf.Format(body)
+			if err != nil {
+				return nil, err
+			}
+			body = []byte(text)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := fmt.Fprintf(c.out, "%s %s\n%s\n", req.Method, req.URL, strings.TrimRight(string(body), "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
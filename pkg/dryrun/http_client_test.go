@@ -0,0 +1,81 @@
+package dryrun_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/dryrun"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/remotewrite"
+	"github.com/golang/snappy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPClient", func() {
+	It("writes requests instead of sending them", func() {
+		buf := &bytes.Buffer{}
+		client := dryrun.NewHTTPClient(buf)
+
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/api", bytes.NewBufferString(`{"series":[]}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		Expect(buf.String()).To(Equal("POST https://example.com/api\n{\"series\":[]}\n"))
+	})
+
+	It("decompresses gzipped bodies", func() {
+		buf := &bytes.Buffer{}
+		client := dryrun.NewHTTPClient(buf)
+
+		body := &bytes.Buffer{}
+		gz := gzip.NewWriter(body)
+		_, err := gz.Write([]byte("payload"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gz.Close()).To(Succeed())
+
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/api", body)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Content-Encoding", "gzip")
+
+		_, err = client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(buf.String()).To(Equal("POST https://example.com/api\npayload\n"))
+	})
+
+	It("writes snappy compressed protobuf bodies as text", func() {
+		buf := &bytes.Buffer{}
+		client := dryrun.NewHTTPClient(buf)
+
+		series := remotewrite.WriteRequest{Timeseries: []remotewrite.TimeSeries{{
+			Labels:  []remotewrite.Label{{Name: "__name__", Value: "ingress"}},
+			Samples: []remotewrite.Sample{{Value: 2.5, Timestamp: 1520259480000}},
+		}}}
+		body := snappy.Encode(nil, series.MarshalProto())
+
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/write", bytes.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+
+		_, err = client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(buf.String()).To(Equal(`POST https://example.com/write
+1 {
+  1 {
+    1: "__name__"
+    2: "ingress"
+  }
+  2 {
+    1: 2.5
+    2: 1520259480000
+  }
+}
+`))
+	})
+})
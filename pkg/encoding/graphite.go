@@ -0,0 +1,212 @@
+package encoding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	graphite "github.com/marpaia/graphite-golang"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// GraphiteSchema is the layout of dotted Graphite paths.
+type GraphiteSchema int
+
+// Graphite path layouts.
+const (
+	// GraphiteSchemaV1 is the legacy layout, <prefix>.<org>.<space>.<app>.<index>.
+	GraphiteSchemaV1 GraphiteSchema = iota
+	// GraphiteSchemaV2 is the versioned layout with explicit leaf names,
+	// <prefix>.v2.<org>.<space>.<app>.instances.<index>.<kind>.
+	GraphiteSchemaV2
+)
+
+// ParseGraphiteSchema returns the GraphiteSchema named s.
+func ParseGraphiteSchema(s string) (GraphiteSchema, error) {
+	switch s {
+	case "v1", "":
+		return GraphiteSchemaV1, nil
+	case "v2":
+		return GraphiteSchemaV2, nil
+	default:
+		return GraphiteSchemaV1, fmt.Errorf("unknown graphite schema %q", s)
+	}
+}
+
+// String returns the name of the schema.
+func (s GraphiteSchema) String() string {
+	if s == GraphiteSchemaV2 {
+		return "v2"
+	}
+	return "v1"
+}
+
+// GraphiteEncoder renders points as Graphite metrics.
+//
+// By default names are dotted paths, <prefix>.<org>.<space>.<app>.<index>,
+// as they have always been sent. Points of other kinds than ingress are
+// sent below a sibling of the prefix, <prefix>_<kind>.<org>.<space>.<app>,
+// followed by the index for instance points, so that wildcards over the
+// ingress paths do not match them.
+//
+// The v2 schema ends every path in the kind of the point instead, so that
+// any series can be added without colliding with instance indexes:
+// <prefix>.v2.<org>.<space>.<app>.instances.<index>.<kind> for instances
+// and <prefix>.v2.<org>.<space>.<app>.<kind> for apps. With DualWrite every
+// point is sent under both layouts, for migrating dashboards and alerts.
+//
+// With GUIDKeys dotted paths are keyed on the app GUID instead of the org,
+// space and app names, <prefix>.<app_guid>.<index> and
+// <prefix>.v2.<app_guid>.instances.<index>.<kind>, so that renaming or
+// moving an app does not split its history. The app GUID is unique on its
+// own; the org and space GUIDs are not known to every app metadata source.
+//
+// A Template replaces the layout of dotted paths altogether. Points it fails
+// to render are dropped rather than sent under another layout.
+//
+// Tagged names use the Graphite 1.1 tag syntax instead,
+// <prefix>.ingress[.<kind>];org=<org>;space=<space>;..., which keeps the
+// identity queryable without relying on the position in the path. The
+// schema does not apply to them.
+type GraphiteEncoder struct {
+	Prefix    string
+	Tagged    bool
+	Schema    GraphiteSchema
+	DualWrite bool
+	GUIDKeys  bool
+	Template  *NameTemplate
+}
+
+// Metrics returns the Graphite metrics for points, one per name of every
+// point. Points without a name are left out, the error tells how many.
+func (e GraphiteEncoder) Metrics(points []point.Point) ([]graphite.Metric, error) {
+	var dropped int
+	var firstErr error
+	metrics := make([]graphite.Metric, 0, len(points))
+	for _, p := range points {
+		names, err := e.Names(p)
+		if err != nil {
+			if dropped == 0 {
+				firstErr = err
+			}
+			dropped++
+			continue
+		}
+
+		value := FormatValue(p.Value)
+		for _, name := range names {
+			metrics = append(metrics, graphite.Metric{
+				Name:      name,
+				Value:     value,
+				Timestamp: p.Timestamp,
+			})
+		}
+	}
+
+	if dropped > 0 {
+		return metrics, fmt.Errorf("dropped %d of %d points without a name: %s", dropped, len(points), firstErr)
+	}
+
+	return metrics, nil
+}
+
+// Names returns the Graphite names p is sent under: its name, followed by
+// its name in the other schema when dual writing dotted paths.
+func (e GraphiteEncoder) Names(p point.Point) ([]string, error) {
+	if e.Tagged || e.Template != nil || !e.DualWrite {
+		name, err := e.Name(p)
+		if err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+
+	return []string{e.v1Name(p), e.v2Name(p)}, nil
+}
+
+// Name returns the Graphite name of p. It only fails when the template
+// cannot render p.
+func (e GraphiteEncoder) Name(p point.Point) (string, error) {
+	switch {
+	case e.Tagged:
+		return e.taggedName(p), nil
+	case e.Template != nil:
+		return e.Template.Execute(p)
+	case e.Schema == GraphiteSchemaV2:
+		return e.v2Name(p), nil
+	default:
+		return e.v1Name(p), nil
+	}
+}
+
+func (e GraphiteEncoder) v1Name(p point.Point) string {
+	name := MetricName(e.Prefix, "_", p.Name) + "." + e.appPath(p)
+	if p.Identity.AppLevel() {
+		return name
+	}
+
+	return name + "." + strconv.Itoa(p.Identity.Index)
+}
+
+func (e GraphiteEncoder) v2Name(p point.Point) string {
+	kind := p.Name
+	if kind == "" {
+		kind = point.Ingress
+	}
+
+	name := e.Prefix + ".v2." + e.appPath(p)
+	if !p.Identity.AppLevel() {
+		name += ".instances." + strconv.Itoa(p.Identity.Index)
+	}
+
+	return name + "." + kind
+}
+
+// appPath returns the segments of a dotted path identifying the app of p.
+func (e GraphiteEncoder) appPath(p point.Point) string {
+	if e.GUIDKeys {
+		return p.Identity.AppGUID
+	}
+
+	return p.Identity.Org + "." + p.Identity.Space + "." + p.Identity.App
+}
+
+func (e GraphiteEncoder) taggedName(p point.Point) string {
+	var b strings.Builder
+	b.WriteString(MetricName(e.Prefix+"."+point.Ingress, ".", p.Name))
+
+	tag := func(k, v string) {
+		if v == "" {
+			return
+		}
+		b.WriteByte(';')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(sanitizeGraphiteTag(v))
+	}
+	tag("org", p.Identity.Org)
+	tag("org_guid", p.Identity.OrgGUID)
+	tag("space", p.Identity.Space)
+	tag("space_guid", p.Identity.SpaceGUID)
+	tag("app", p.Identity.App)
+	tag("app_guid", p.Identity.AppGUID)
+	if !p.Identity.AppLevel() {
+		tag("instance", strconv.Itoa(p.Identity.Index))
+	}
+	for _, k := range SortedKeys(p.Labels) {
+		tag(k, p.Labels[k])
+	}
+
+	return b.String()
+}
+
+// sanitizeGraphiteTag replaces the characters that may not appear in
+// Graphite tag values.
+var sanitizeGraphiteTag = strings.NewReplacer(";", "_", "~", "_", " ", "_", "\n", "_").Replace
+
+// FormatValue renders a value without a fraction when it is integral, as
+// counts have always been sent.
+func FormatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,15 @@
+package encoding
+
+import "sort"
+
+// SortedKeys returns the keys of labels in lexical order, so that encoded
+// output is stable.
+func SortedKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,15 @@
+package encoding
+
+import "github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+
+// MetricName returns the name points of a kind are sent as by a sink that
+// sends ingress points as ingress. The other kinds are derived from the
+// ingress and named by appending the kind with sep, e.g. cf_app_ingress
+// and cf_app_ingress_zscore.
+func MetricName(ingress, sep, kind string) string {
+	if kind == point.Ingress || kind == "" {
+		return ingress
+	}
+
+	return ingress + sep + kind
+}
@@ -0,0 +1,145 @@
+package encoding
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// NameData is what a name template is executed with.
+type NameData struct {
+	Prefix     string
+	Foundation string
+	// Kind is the kind of the point, e.g. ingress or zscore.
+	Kind string
+	Org  string
+	// OrgGUID and SpaceGUID are empty when the app metadata source does not
+	// provide them.
+	OrgGUID   string
+	Space     string
+	SpaceGUID string
+	App       string
+	AppGUID   string
+	// Index is the instance index, -1 for points describing the app as a
+	// whole, for which AppLevel is true.
+	Index    int
+	AppLevel bool
+}
+
+// NameTemplate renders metric names from a text/template, e.g.
+//
+//	{{.Foundation}}.{{.Org}}.{{.Space}}.{{.App}}{{if not .AppLevel}}.{{.Index}}{{end}}.{{.Kind}}
+//
+// Besides the builtin functions the template can use lower, and replace
+// for replacing all occurrences of a string.
+type NameTemplate struct {
+	tmpl       *template.Template
+	prefix     string
+	foundation string
+}
+
+var nameFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"replace": func(s, old, new string) string {
+		return strings.Replace(s, old, new, -1)
+	},
+}
+
+// ParseNameTemplate parses text and validates it against sample points
+// with the prefix and foundation, so that a bad template fails at startup
+// rather than on the first report. The template has to render dotted paths
+// without empty segments that tell apart instances and apps, and the
+// ingress from the other kinds of points that are sent, if any.
+func ParseNameTemplate(text, prefix, foundation string, kinds ...string) (*NameTemplate, error) {
+	tmpl, err := template.New("name").Funcs(nameFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	t := &NameTemplate{tmpl: tmpl, prefix: prefix, foundation: foundation}
+
+	web := point.Identity{
+		Org: "sample-org", OrgGUID: "sample-org-guid",
+		Space: "sample-space", SpaceGUID: "sample-space-guid",
+		App: "sample-web", AppGUID: "sample-web-guid",
+	}
+	worker := web
+	worker.App, worker.AppGUID = "sample-worker", "sample-worker-guid"
+
+	samples := []point.Point{{Name: point.Ingress, Identity: web}}
+	for _, kind := range kinds {
+		switch kind {
+		case point.Rate, point.Delta:
+			samples = append(samples, point.Point{Name: kind, Identity: web})
+		case point.Share:
+			samples = append(samples,
+				point.Point{Name: kind, Identity: web},
+				point.Point{Name: kind, Identity: withIndex(web, -1)},
+			)
+		default:
+			samples = append(samples, point.Point{Name: kind, Identity: withIndex(web, -1)})
+		}
+	}
+	samples = append(samples,
+		point.Point{Name: point.Ingress, Identity: withIndex(web, 1)},
+		point.Point{Name: point.Ingress, Identity: worker},
+	)
+	seen := make(map[string]int)
+	for i, p := range samples {
+		name, err := t.Execute(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, segment := range strings.Split(name, ".") {
+			if strings.TrimSpace(segment) == "" {
+				return nil, fmt.Errorf("template renders %q with an empty path segment", name)
+			}
+		}
+		if j, ok := seen[name]; ok {
+			return nil, fmt.Errorf("template renders %q for both the %s and the %s sample point",
+				name, describeSample(samples[j]), describeSample(p))
+		}
+		seen[name] = i
+	}
+
+	return t, nil
+}
+
+// Execute renders the name of p.
+func (t *NameTemplate) Execute(p point.Point) (string, error) {
+	kind := p.Name
+	if kind == "" {
+		kind = point.Ingress
+	}
+
+	var b bytes.Buffer
+	err := t.tmpl.Execute(&b, NameData{
+		Prefix:     t.prefix,
+		Foundation: t.foundation,
+		Kind:       kind,
+		Org:        p.Identity.Org,
+		OrgGUID:    p.Identity.OrgGUID,
+		Space:      p.Identity.Space,
+		SpaceGUID:  p.Identity.SpaceGUID,
+		App:        p.Identity.App,
+		AppGUID:    p.Identity.AppGUID,
+		Index:      p.Identity.Index,
+		AppLevel:   p.Identity.AppLevel(),
+	})
+
+	return b.String(), err
+}
+
+func withIndex(i point.Identity, index int) point.Identity {
+	i.Index = index
+	return i
+}
+
+func describeSample(p point.Point) string {
+	if p.Identity.AppLevel() {
+		return fmt.Sprintf("%s of app %s", p.Name, p.Identity.App)
+	}
+	return fmt.Sprintf("%s of instance %d of app %s", p.Name, p.Identity.Index, p.Identity.App)
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxSampleKeys is the maximum number of distinct keys listed in an
+// aggregated warning.
+const maxSampleKeys = 10
+
+// Aggregator collapses a repetitive condition, e.g. an app GUID that cannot
+// be resolved on every tick, into a single warning per window. Each warning
+// reports how often the condition occurred, for how many distinct keys, and
+// a sample of those keys.
+type Aggregator struct {
+	logger *Logger
+	msg    string
+	window time.Duration
+
+	mu          sync.Mutex
+	occurrences int
+	keys        map[string]int
+	lastFlushed time.Time
+}
+
+// NewAggregator returns an Aggregator that logs msg as a warning on logger at
+// most once per window.
+func NewAggregator(logger *Logger, msg string, window time.Duration) *Aggregator {
+	return &Aggregator{
+		logger: logger,
+		msg:    msg,
+		window: window,
+		keys:   make(map[string]int),
+	}
+}
+
+// Observe records one occurrence of the condition for each of the given
+// keys.
+func (a *Aggregator) Observe(keys ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, k := range keys {
+		a.occurrences++
+		a.keys[k]++
+	}
+}
+
+// Flush logs the occurrences observed so far if the window has elapsed since
+// the last warning. Nothing is logged when there were no occurrences.
+func (a *Aggregator) Flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if a.occurrences == 0 || now.Sub(a.lastFlushed) < a.window {
+		return
+	}
+
+	sample := make([]string, 0, len(a.keys))
+	for k := range a.keys {
+		sample = append(sample, k)
+	}
+	sort.Slice(sample, func(i, j int) bool {
+		if a.keys[sample[i]] != a.keys[sample[j]] {
+			return a.keys[sample[i]] > a.keys[sample[j]]
+		}
+		return sample[i] < sample[j]
+	})
+	if len(sample) > maxSampleKeys {
+		sample = sample[:maxSampleKeys]
+	}
+
+	fields := Fields{
+		"occurrences": a.occurrences,
+		"distinct":    len(a.keys),
+		"sample":      sample,
+	}
+	if !a.lastFlushed.IsZero() {
+		fields["since"] = a.lastFlushed.UTC().Format(time.RFC3339)
+	}
+	a.logger.Warn(a.msg, fields)
+
+	a.occurrences = 0
+	a.keys = make(map[string]int)
+	a.lastFlushed = now
+}
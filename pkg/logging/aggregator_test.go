@@ -0,0 +1,62 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Aggregator", func() {
+	var (
+		buf    *bytes.Buffer
+		logger *logging.Logger
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		logger = logging.New(buf)
+	})
+
+	It("logs a single warning summarising all occurrences", func() {
+		a := logging.NewAggregator(logger, "unresolved app GUIDs", time.Hour)
+
+		a.Observe("a", "b")
+		a.Observe("a")
+		a.Flush()
+
+		entries := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Expect(entries).To(HaveLen(1))
+
+		var entry map[string]interface{}
+		Expect(json.Unmarshal([]byte(entries[0]), &entry)).To(Succeed())
+		Expect(entry).To(HaveKeyWithValue("level", "warn"))
+		Expect(entry).To(HaveKeyWithValue("msg", "unresolved app GUIDs"))
+		Expect(entry).To(HaveKeyWithValue("occurrences", BeNumerically("==", 3)))
+		Expect(entry).To(HaveKeyWithValue("distinct", BeNumerically("==", 2)))
+		Expect(entry).To(HaveKeyWithValue("sample", Equal([]interface{}{"a", "b"})))
+	})
+
+	It("logs at most once per window", func() {
+		a := logging.NewAggregator(logger, "unresolved app GUIDs", time.Hour)
+
+		a.Observe("a")
+		a.Flush()
+		a.Observe("a")
+		a.Flush()
+
+		Expect(strings.Count(buf.String(), "\n")).To(Equal(1))
+	})
+
+	It("does not log without occurrences", func() {
+		a := logging.NewAggregator(logger, "unresolved app GUIDs", 0)
+
+		a.Flush()
+
+		Expect(buf.String()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,298 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+// Supported log levels, from the most to the least verbose.
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+// String implements the Stringer interface.
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// ParseLevel returns the Level with the given name.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	default:
+		return InfoLevel, fmt.Errorf("unknown log level %q", s)
+	}
+}
+
+// Format is the encoding used for log entries.
+type Format int
+
+// Supported log formats.
+const (
+	JSONFormat Format = iota
+	LogfmtFormat
+)
+
+// ParseFormat returns the Format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "json":
+		return JSONFormat, nil
+	case "logfmt":
+		return LogfmtFormat, nil
+	default:
+		return JSONFormat, fmt.Errorf("unknown log format %q", s)
+	}
+}
+
+// Fields holds the structured context attached to a log entry.
+type Fields map[string]interface{}
+
+// Logger writes leveled, structured log entries to an io.Writer. Loggers
+// derived with With share the writer and the lock of their parent.
+type Logger struct {
+	out    io.Writer
+	level  Level
+	format Format
+	fields Fields
+	mu     *sync.Mutex
+}
+
+// New returns a Logger writing to out. By default it logs entries of
+// InfoLevel and above as JSON.
+func New(out io.Writer, opts ...Option) *Logger {
+	l := &Logger{
+		out:    out,
+		level:  InfoLevel,
+		format: JSONFormat,
+		fields: Fields{},
+		mu:     &sync.Mutex{},
+	}
+
+	for _, o := range opts {
+		o(l)
+	}
+
+	return l
+}
+
+var defaultLogger = New(os.Stderr)
+
+// Default returns the Logger used by components that have not been
+// configured with one. It writes JSON entries of InfoLevel and above to
+// stderr.
+func Default() *Logger {
+	return defaultLogger
+}
+
+// Discard returns a Logger that drops every entry.
+func Discard() *Logger {
+	return New(ioutil.Discard, WithLevel(ErrorLevel+1))
+}
+
+// With returns a Logger that adds the given fields to every entry, on top of
+// the fields of l.
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	child := *l
+	child.fields = merged
+
+	return &child
+}
+
+// Enabled reports whether entries of the given level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug logs msg at DebugLevel.
+func (l *Logger) Debug(msg string, fields ...Fields) {
+	l.log(DebugLevel, msg, fields)
+}
+
+// Info logs msg at InfoLevel.
+func (l *Logger) Info(msg string, fields ...Fields) {
+	l.log(InfoLevel, msg, fields)
+}
+
+// Warn logs msg at WarnLevel.
+func (l *Logger) Warn(msg string, fields ...Fields) {
+	l.log(WarnLevel, msg, fields)
+}
+
+// Error logs msg at ErrorLevel.
+func (l *Logger) Error(msg string, fields ...Fields) {
+	l.log(ErrorLevel, msg, fields)
+}
+
+// Fatal logs msg at ErrorLevel and exits the process.
+func (l *Logger) Fatal(msg string, fields ...Fields) {
+	l.log(ErrorLevel, msg, fields)
+	os.Exit(1)
+}
+
+func (l *Logger) log(level Level, msg string, extra []Fields) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	fields := make(Fields, len(l.fields)+4)
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	for _, f := range extra {
+		for k, v := range f {
+			fields[k] = v
+		}
+	}
+
+	line := l.encode(time.Now(), level, msg, fields)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lw, ok := l.out.(LevelWriter); ok {
+		_, _ = lw.WriteLevel(level, line)
+		return
+	}
+	_, _ = l.out.Write(line)
+}
+
+func (l *Logger) encode(t time.Time, level Level, msg string, fields Fields) []byte {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k == "time" || k == "level" || k == "msg" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := &bytes.Buffer{}
+	switch l.format {
+	case LogfmtFormat:
+		writeLogfmtPair(buf, "time", t.UTC().Format(time.RFC3339Nano))
+		writeLogfmtPair(buf, "level", level.String())
+		writeLogfmtPair(buf, "msg", msg)
+		for _, k := range keys {
+			writeLogfmtPair(buf, k, fields[k])
+		}
+	default:
+		buf.WriteByte('{')
+		writeJSONPair(buf, "time", t.UTC().Format(time.RFC3339Nano))
+		writeJSONPair(buf, "level", level.String())
+		writeJSONPair(buf, "msg", msg)
+		for _, k := range keys {
+			writeJSONPair(buf, k, fields[k])
+		}
+		buf.Truncate(buf.Len() - 1)
+		buf.WriteByte('}')
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes()
+}
+
+func writeJSONPair(buf *bytes.Buffer, key string, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+	buf.WriteByte(',')
+}
+
+func writeLogfmtPair(buf *bytes.Buffer, key string, value interface{}) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		s = fmt.Sprintf("%q", s)
+	}
+
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	buf.WriteString(s)
+}
+
+// LevelWriter is implemented by outputs that need the level of each entry,
+// such as syslog. Each call receives one complete, newline terminated entry.
+type LevelWriter interface {
+	WriteLevel(Level, []byte) (int, error)
+}
+
+// Option is a func that is used to configure optional settings on a Logger.
+type Option func(*Logger)
+
+// WithLevel returns an Option for configuring the minimum level of entries
+// that are written.
+func WithLevel(level Level) Option {
+	return func(l *Logger) {
+		l.level = level
+	}
+}
+
+// WithFormat returns an Option for configuring the encoding of entries.
+func WithFormat(format Format) Option {
+	return func(l *Logger) {
+		l.format = format
+	}
+}
+
+// WithFields returns an Option for configuring fields added to every entry.
+func WithFields(fields Fields) Option {
+	return func(l *Logger) {
+		for k, v := range fields {
+			l.fields[k] = v
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logger", func() {
+	var buf *bytes.Buffer
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+	})
+
+	It("writes entries as JSON with their fields", func() {
+		logger := logging.New(buf)
+
+		logger.With(logging.Fields{"stage": "fetch"}).Info("fetched rates", logging.Fields{
+			"counts": 2,
+			"error":  errors.New("boom"),
+		})
+
+		var entry map[string]interface{}
+		Expect(json.Unmarshal(buf.Bytes(), &entry)).To(Succeed())
+		Expect(entry).To(HaveKeyWithValue("level", "info"))
+		Expect(entry).To(HaveKeyWithValue("msg", "fetched rates"))
+		Expect(entry).To(HaveKeyWithValue("stage", "fetch"))
+		Expect(entry).To(HaveKeyWithValue("counts", BeNumerically("==", 2)))
+		Expect(entry).To(HaveKeyWithValue("error", "boom"))
+		Expect(entry).To(HaveKey("time"))
+	})
+
+	It("writes entries as logfmt", func() {
+		logger := logging.New(buf, logging.WithFormat(logging.LogfmtFormat))
+
+		logger.Warn("lookup failed", logging.Fields{"app_guid": "a", "reason": "not found"})
+
+		Expect(buf.String()).To(MatchRegexp(`^time=\S+ level=warn msg="lookup failed" app_guid=a reason="not found"\n$`))
+	})
+
+	It("drops entries below the configured level", func() {
+		logger := logging.New(buf, logging.WithLevel(logging.WarnLevel))
+
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Error("error")
+
+		Expect(buf.String()).ToNot(ContainSubstring(`"msg":"debug"`))
+		Expect(buf.String()).ToNot(ContainSubstring(`"msg":"info"`))
+		Expect(buf.String()).To(ContainSubstring(`"msg":"error"`))
+	})
+
+	It("does not modify the parent when fields are added", func() {
+		logger := logging.New(buf)
+		logger.With(logging.Fields{"tick": 1})
+
+		logger.Info("parent")
+
+		Expect(buf.String()).ToNot(ContainSubstring("tick"))
+	})
+
+	It("passes the level to outputs that need it", func() {
+		out := &spyLevelWriter{}
+		logger := logging.New(out)
+
+		logger.Error("error")
+
+		Expect(out.levels).To(Equal([]logging.Level{logging.ErrorLevel}))
+	})
+
+	It("redirects the standard library logger", func() {
+		defer log.SetOutput(os.Stderr)
+		logging.RedirectStdlib(logging.New(buf), logging.InfoLevel)
+
+		log.Printf("call to HTTP store failed: %s", "boom")
+
+		Expect(buf.String()).To(ContainSubstring(`"msg":"call to HTTP store failed: boom"`))
+		Expect(buf.String()).To(ContainSubstring(`"source":"stdlib"`))
+	})
+
+	It("parses levels and formats", func() {
+		level, err := logging.ParseLevel("WARN")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(level).To(Equal(logging.WarnLevel))
+
+		format, err := logging.ParseFormat("logfmt")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal(logging.LogfmtFormat))
+
+		_, err = logging.ParseLevel("verbose")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+type spyLevelWriter struct {
+	levels []logging.Level
+}
+
+func (s *spyLevelWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (s *spyLevelWriter) WriteLevel(level logging.Level, p []byte) (int, error) {
+	s.levels = append(s.levels, level)
+	return len(p), nil
+}
@@ -0,0 +1,30 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+)
+
+// RedirectStdlib routes the output of the standard library logger, which is
+// still used by vendored packages, through l at the given level.
+func RedirectStdlib(l *Logger, level Level) {
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(&stdlibWriter{logger: l, level: level})
+}
+
+type stdlibWriter struct {
+	logger *Logger
+	level  Level
+}
+
+func (w *stdlibWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		w.logger.log(w.level, string(line), []Fields{{"source": "stdlib"}})
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,278 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog facilities that can be used for the reporter's own logs.
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+)
+
+// SyslogWriter sends log entries as RFC 5424 messages to a syslog server over
+// UDP, TCP or TLS. TCP and TLS messages are framed with octet counting as
+// described in RFC 6587 and RFC 5425. Whenever the server cannot be reached
+// entries are written to a fallback writer instead, and reconnection is
+// attempted at most once per retry interval.
+type SyslogWriter struct {
+	network   string
+	addr      string
+	tlsConfig *tls.Config
+
+	facility      int
+	hostname      string
+	appName       string
+	procID        string
+	fallback      io.Writer
+	timeout       time.Duration
+	retryInterval time.Duration
+
+	mu          sync.Mutex
+	conn        net.Conn
+	lastAttempt time.Time
+}
+
+// NewSyslogWriter returns a SyslogWriter for the given endpoint. The
+// endpoint is a URL with a udp, tcp or tls scheme, e.g. tcp://host:514. An
+// endpoint without scheme is treated as UDP. The connection is established
+// lazily, so an unreachable server does not cause an error here.
+func NewSyslogWriter(endpoint string, opts ...SyslogOption) (*SyslogWriter, error) {
+	network, addr, err := parseSyslogEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	w := &SyslogWriter{
+		network:       network,
+		addr:          addr,
+		tlsConfig:     &tls.Config{},
+		facility:      FacilityUser,
+		hostname:      hostname,
+		appName:       "-",
+		procID:        fmt.Sprintf("%d", os.Getpid()),
+		fallback:      os.Stderr,
+		timeout:       5 * time.Second,
+		retryInterval: 30 * time.Second,
+	}
+
+	for _, o := range opts {
+		o(w)
+	}
+
+	return w, nil
+}
+
+// Write satisfies the io.Writer interface. Entries written without a level
+// are sent with the informational severity.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(InfoLevel, p)
+}
+
+// WriteLevel satisfies the LevelWriter interface.
+func (w *SyslogWriter) WriteLevel(level Level, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	msg := w.format(time.Now(), level, p)
+
+	if err := w.send(msg); err != nil {
+		if w.conn != nil {
+			w.conn.Close()
+			w.conn = nil
+		}
+		return w.fallback.Write(p)
+	}
+
+	return len(p), nil
+}
+
+// Close closes the connection to the syslog server.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
+
+func (w *SyslogWriter) send(msg []byte) error {
+	if w.conn == nil {
+		if time.Since(w.lastAttempt) < w.retryInterval {
+			return fmt.Errorf("syslog server %s is unavailable", w.addr)
+		}
+		w.lastAttempt = time.Now()
+
+		conn, err := w.dial()
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+
+	if w.network != "udp" {
+		msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	}
+
+	_ = w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	_, err := w.conn.Write(msg)
+
+	return err
+}
+
+func (w *SyslogWriter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: w.timeout}
+
+	if w.network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", w.addr, w.tlsConfig)
+	}
+
+	return dialer.Dial(w.network, w.addr)
+}
+
+// format renders an RFC 5424 message without structured data. The message id
+// is left empty as entries are already structured by the Logger.
+func (w *SyslogWriter) format(t time.Time, level Level, p []byte) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "<%d>1 %s %s %s %s - - ",
+		w.facility*8+severity(level),
+		t.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(w.hostname, 255),
+		headerField(w.appName, 48),
+		headerField(w.procID, 128),
+	)
+	buf.Write(bytes.TrimRight(p, "\n"))
+
+	return buf.Bytes()
+}
+
+// severity maps a Level to its syslog severity.
+func severity(level Level) int {
+	switch level {
+	case DebugLevel:
+		return 7
+	case InfoLevel:
+		return 6
+	case WarnLevel:
+		return 4
+	default:
+		return 3
+	}
+}
+
+// headerField restricts a header field to printable US-ASCII of at most n
+// characters, as required by RFC 5424.
+func headerField(s string, n int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+
+	if s == "" {
+		return "-"
+	}
+	if len(s) > n {
+		return s[:n]
+	}
+
+	return s
+}
+
+func parseSyslogEndpoint(endpoint string) (string, string, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "udp://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+	case "syslog":
+		u.Scheme = "udp"
+	default:
+		return "", "", fmt.Errorf("unsupported syslog scheme %q", u.Scheme)
+	}
+
+	if u.Port() == "" {
+		return "", "", fmt.Errorf("syslog endpoint %q has no port", endpoint)
+	}
+
+	return u.Scheme, u.Host, nil
+}
+
+// SyslogOption is a func that is used to configure optional settings on a
+// SyslogWriter.
+type SyslogOption func(*SyslogWriter)
+
+// WithAppName returns a SyslogOption for configuring the APP-NAME header
+// field.
+func WithAppName(name string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.appName = name
+	}
+}
+
+// WithHostname returns a SyslogOption for configuring the HOSTNAME header
+// field. It defaults to the hostname reported by the kernel.
+func WithHostname(hostname string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.hostname = hostname
+	}
+}
+
+// WithFacility returns a SyslogOption for configuring the syslog facility.
+func WithFacility(facility int) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.facility = facility
+	}
+}
+
+// WithTLSConfig returns a SyslogOption for configuring the TLS settings used
+// for tls endpoints.
+func WithTLSConfig(c *tls.Config) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.tlsConfig = c
+	}
+}
+
+// WithFallback returns a SyslogOption for configuring where entries are
+// written while the syslog server is unreachable. It defaults to stderr.
+func WithFallback(fallback io.Writer) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.fallback = fallback
+	}
+}
+
+// WithRetryInterval returns a SyslogOption for configuring how often
+// reconnecting to an unreachable syslog server is attempted.
+func WithRetryInterval(d time.Duration) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.retryInterval = d
+	}
+}
@@ -0,0 +1,158 @@
+package logging_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyslogWriter", func() {
+	It("sends one RFC 5424 message per datagram over UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		w, err := logging.NewSyslogWriter("udp://"+conn.LocalAddr().String(),
+			logging.WithAppName("graphite-reporter"),
+			logging.WithHostname("reporter-host"),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer w.Close()
+
+		logging.New(w).Warn("failed to post to graphite")
+
+		buf := make([]byte, 2048)
+		Expect(conn.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		n, _, err := conn.ReadFrom(buf)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(string(buf[:n])).To(MatchRegexp(
+			`^<12>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}Z reporter-host graphite-reporter \d+ - - \{.*"msg":"failed to post to graphite"\}$`,
+		))
+	})
+
+	It("frames messages with octet counting over TCP", func() {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer lis.Close()
+		messages := acceptFramed(lis)
+
+		w, err := logging.NewSyslogWriter("tcp://" + lis.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		defer w.Close()
+
+		logger := logging.New(w, logging.WithLevel(logging.DebugLevel))
+		logger.Debug("first")
+		logger.Error("second")
+
+		Eventually(messages).Should(Receive(MatchRegexp(`^<15>1 .*"msg":"first"\}$`)))
+		Eventually(messages).Should(Receive(MatchRegexp(`^<11>1 .*"msg":"second"\}$`)))
+	})
+
+	It("sends messages over TLS", func() {
+		lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{selfSignedCert()},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer lis.Close()
+		messages := acceptFramed(lis)
+
+		w, err := logging.NewSyslogWriter("tls://"+lis.Addr().String(),
+			logging.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer w.Close()
+
+		logging.New(w).Info("over tls")
+
+		Eventually(messages).Should(Receive(MatchRegexp(`^<14>1 .*"msg":"over tls"\}$`)))
+	})
+
+	It("falls back when the syslog server is unreachable", func() {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		addr := lis.Addr().String()
+		lis.Close()
+
+		fallback := &bytes.Buffer{}
+		w, err := logging.NewSyslogWriter("tcp://"+addr, logging.WithFallback(fallback))
+		Expect(err).ToNot(HaveOccurred())
+
+		logging.New(w).Info("nobody is listening")
+
+		Expect(fallback.String()).To(ContainSubstring(`"msg":"nobody is listening"`))
+	})
+
+	It("rejects unsupported endpoints", func() {
+		_, err := logging.NewSyslogWriter("http://host:514")
+		Expect(err).To(HaveOccurred())
+
+		_, err = logging.NewSyslogWriter("tcp://host")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+func acceptFramed(lis net.Listener) <-chan string {
+	messages := make(chan string, 10)
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(length[:len(length)-1])
+			if err != nil {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			messages <- string(msg)
+		}
+	}()
+
+	return messages
+}
+
+func selfSignedCert() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
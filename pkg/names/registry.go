@@ -0,0 +1,307 @@
+// Package names keeps the current org, space and app names of every app
+// GUID, so that series keyed on GUIDs can be mapped back to names, and
+// detects renames between lookups.
+package names
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Names are the names of an app when it was last reported.
+type Names struct {
+	Org   string `json:"org"`
+	Space string `json:"space"`
+	App   string `json:"app"`
+	// LastSeen is the last interval the app was reported on, as a unix
+	// timestamp.
+	LastSeen int64 `json:"last_seen"`
+}
+
+// RenameStats counts the renames observed, by what was renamed. A rename of
+// an org or space is counted once per report it is observed on, however
+// many of its apps are reported. Without org and space GUIDs an app moved to
+// another space or org cannot be told apart from a rename and is counted as
+// one.
+type RenameStats struct {
+	Orgs   int64 `json:"orgs"`
+	Spaces int64 `json:"spaces"`
+	Apps   int64 `json:"apps"`
+}
+
+// Mapping is the content of the mapping file and endpoint.
+type Mapping struct {
+	Apps    map[string]Names `json:"apps"`
+	Renames RenameStats      `json:"renames"`
+}
+
+// Registry observes the points of every report, keeping the names of every
+// app GUID. It writes the mapping to a file, if one is configured, when a
+// rename is observed and otherwise at most once per interval. Apps that
+// have not been reported for the retention period are forgotten.
+type Registry struct {
+	file      string
+	interval  time.Duration
+	retention time.Duration
+	logger    *logging.Logger
+
+	mu        sync.Mutex
+	apps      map[string]Names
+	stats     RenameStats
+	lastWrite time.Time
+}
+
+// NewRegistry returns a Registry, restoring the mapping from the file if
+// one is configured so that renames during a restart are detected.
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{
+		interval:  5 * time.Minute,
+		retention: 30 * 24 * time.Hour,
+		logger:    logging.Default(),
+		apps:      make(map[string]Names),
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+	r.logger = r.logger.With(logging.Fields{"stage": "names"})
+
+	if r.file != "" {
+		r.load()
+	}
+
+	return r
+}
+
+// rename is a change of a name observed on a report. The app GUID is only
+// set for renames of apps.
+type rename struct {
+	field, guid, from, to string
+}
+
+// ObservePoints records the names of the apps of the points and logs and
+// counts the renames since they were last reported.
+func (r *Registry) ObservePoints(points []point.Point) {
+	r.mu.Lock()
+
+	var timestamp int64
+	seen := make(map[rename]bool)
+	for _, p := range points {
+		guid := p.Identity.AppGUID
+		if guid == "" || p.Identity.App == "" {
+			continue
+		}
+		timestamp = p.Timestamp
+
+		current := Names{
+			Org:      p.Identity.Org,
+			Space:    p.Identity.Space,
+			App:      p.Identity.App,
+			LastSeen: p.Timestamp,
+		}
+		previous, ok := r.apps[guid]
+		r.apps[guid] = current
+		if !ok {
+			continue
+		}
+
+		if previous.Org != current.Org {
+			r.observe(seen, rename{"org", "", previous.Org, current.Org}, &r.stats.Orgs)
+		}
+		if previous.Space != current.Space {
+			r.observe(seen, rename{"space", "", previous.Org + "/" + previous.Space, current.Org + "/" + current.Space}, &r.stats.Spaces)
+		}
+		if previous.App != current.App {
+			r.observe(seen, rename{"app", guid, previous.App, current.App}, &r.stats.Apps)
+		}
+	}
+	r.prune(timestamp)
+
+	write := r.file != "" && (len(seen) > 0 || time.Since(r.lastWrite) >= r.interval)
+	r.mu.Unlock()
+
+	if write {
+		r.save()
+	}
+}
+
+// observe logs and counts a rename the first time it is seen on a report.
+// Renames of an org or space are seen once for every app in them. It must
+// be called with the lock held.
+func (r *Registry) observe(seen map[rename]bool, rn rename, counter *int64) {
+	if seen[rn] {
+		return
+	}
+	seen[rn] = true
+	*counter++
+
+	fields := logging.Fields{
+		"renamed": rn.field,
+		"from":    rn.from,
+		"to":      rn.to,
+	}
+	if rn.guid != "" {
+		fields["app_guid"] = rn.guid
+	}
+	r.logger.Info("rename observed", fields)
+}
+
+// prune forgets the apps not reported within the retention period before
+// timestamp. It must be called with the lock held.
+func (r *Registry) prune(timestamp int64) {
+	oldest := timestamp - int64(r.retention/time.Second)
+	for guid, n := range r.apps {
+		if n.LastSeen < oldest {
+			delete(r.apps, guid)
+		}
+	}
+}
+
+// Mapping returns a copy of the names of every app GUID and the renames
+// observed.
+func (r *Registry) Mapping() Mapping {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := Mapping{Apps: make(map[string]Names, len(r.apps)), Renames: r.stats}
+	for guid, n := range r.apps {
+		m.Apps[guid] = n
+	}
+
+	return m
+}
+
+// Stats returns the renames observed.
+func (r *Registry) Stats() RenameStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stats
+}
+
+// Close writes the mapping to the file, if one is configured.
+func (r *Registry) Close() error {
+	if r.file == "" {
+		return nil
+	}
+
+	return r.Save()
+}
+
+func (r *Registry) save() {
+	if err := r.Save(); err != nil {
+		r.logger.Error("failed to save names", logging.Fields{
+			"file":  r.file,
+			"error": err,
+		})
+	}
+}
+
+// Save writes the mapping to the file. The file is replaced atomically.
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(r.Mapping(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(r.file), filepath.Base(r.file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), r.file); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.lastWrite = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// load restores the mapping from the file. A missing or unreadable file
+// starts without names.
+func (r *Registry) load() {
+	data, err := ioutil.ReadFile(r.file)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		r.logger.Warn("failed to read names, starting without names", logging.Fields{
+			"file":  r.file,
+			"error": err,
+		})
+		return
+	}
+
+	var m Mapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		r.logger.Warn("failed to parse names, starting without names", logging.Fields{
+			"file":  r.file,
+			"error": err,
+		})
+		return
+	}
+
+	for guid, n := range m.Apps {
+		r.apps[guid] = n
+	}
+	r.stats = m.Renames
+
+	r.logger.Info("restored names", logging.Fields{
+		"file": r.file,
+		"apps": len(r.apps),
+	})
+}
+
+// RegistryOption is a func that is used to configure optional settings on a
+// Registry.
+type RegistryOption func(*Registry)
+
+// WithFile returns a RegistryOption for configuring the JSON file the
+// mapping is written to and restored from.
+func WithFile(file string) RegistryOption {
+	return func(r *Registry) {
+		r.file = file
+	}
+}
+
+// WithInterval returns a RegistryOption for configuring how often the
+// mapping file is rewritten without renames. It defaults to five minutes.
+func WithInterval(d time.Duration) RegistryOption {
+	return func(r *Registry) {
+		r.interval = d
+	}
+}
+
+// WithRetention returns a RegistryOption for configuring how long the names
+// of an app are kept while the app is not reported. It defaults to 30 days.
+func WithRetention(d time.Duration) RegistryOption {
+	return func(r *Registry) {
+		r.retention = d
+	}
+}
+
+// WithLogger returns a RegistryOption for configuring the logger used by the
+// Registry.
+func WithLogger(l *logging.Logger) RegistryOption {
+	return func(r *Registry) {
+		r.logger = l
+	}
+}
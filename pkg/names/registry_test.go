@@ -0,0 +1,123 @@
+package names_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/names"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	at := func(ts int64, org, space, app, guid string, index int) point.Point {
+		return point.Point{
+			Name:      point.Ingress,
+			Timestamp: ts,
+			Value:     1,
+			Identity:  point.Identity{Org: org, Space: space, App: app, AppGUID: guid, Index: index},
+		}
+	}
+
+	It("keeps the current names of every app GUID", func() {
+		r := names.NewRegistry(names.WithLogger(logging.Discard()))
+
+		r.ObservePoints([]point.Point{
+			at(60, "org", "prod", "web", "w", 0),
+			at(60, "org", "prod", "web", "w", 1),
+			at(60, "org", "prod", "worker", "k", 0),
+		})
+
+		Expect(r.Mapping()).To(Equal(names.Mapping{
+			Apps: map[string]names.Names{
+				"w": {Org: "org", Space: "prod", App: "web", LastSeen: 60},
+				"k": {Org: "org", Space: "prod", App: "worker", LastSeen: 60},
+			},
+		}))
+	})
+
+	It("logs and counts renames once per report", func() {
+		buf := &bytes.Buffer{}
+		r := names.NewRegistry(names.WithLogger(logging.New(buf)))
+
+		r.ObservePoints([]point.Point{
+			at(60, "org", "prod", "web", "w", 0),
+			at(60, "org", "prod", "worker", "k", 0),
+			at(60, "org", "dev", "api", "a", 0),
+		})
+		r.ObservePoints([]point.Point{
+			at(120, "acme", "production", "web", "w", 0),
+			at(120, "acme", "production", "web", "w", 1),
+			at(120, "acme", "production", "worker", "k", 0),
+			at(120, "acme", "dev", "api-v2", "a", 0),
+		})
+
+		Expect(r.Stats()).To(Equal(names.RenameStats{Orgs: 1, Spaces: 1, Apps: 1}))
+		Expect(r.Mapping().Apps["a"]).To(Equal(names.Names{Org: "acme", Space: "dev", App: "api-v2", LastSeen: 120}))
+
+		Expect(strings.Count(buf.String(), "rename observed")).To(Equal(3))
+		Expect(buf.String()).To(ContainSubstring(`"from":"org/prod"`))
+		Expect(buf.String()).To(ContainSubstring(`"app_guid":"a"`))
+	})
+
+	It("forgets apps not reported within the retention period", func() {
+		r := names.NewRegistry(names.WithRetention(0), names.WithLogger(logging.Discard()))
+
+		r.ObservePoints([]point.Point{at(60, "org", "prod", "web", "w", 0)})
+		r.ObservePoints([]point.Point{at(120, "org", "prod", "worker", "k", 0)})
+
+		Expect(r.Mapping().Apps).To(HaveLen(1))
+		Expect(r.Mapping().Apps).To(HaveKey("k"))
+	})
+
+	Context("with a file", func() {
+		var file string
+
+		BeforeEach(func() {
+			dir, err := ioutil.TempDir("", "names")
+			Expect(err).ToNot(HaveOccurred())
+			file = filepath.Join(dir, "names.json")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(filepath.Dir(file))
+		})
+
+		It("writes the mapping and detects renames across restarts", func() {
+			r := names.NewRegistry(names.WithFile(file), names.WithLogger(logging.Discard()))
+			r.ObservePoints([]point.Point{at(60, "org", "prod", "web", "w", 0)})
+
+			data, err := ioutil.ReadFile(file)
+			Expect(err).ToNot(HaveOccurred())
+
+			var m names.Mapping
+			Expect(json.Unmarshal(data, &m)).To(Succeed())
+			Expect(m.Apps).To(HaveKeyWithValue("w", names.Names{Org: "org", Space: "prod", App: "web", LastSeen: 60}))
+
+			r = names.NewRegistry(names.WithFile(file), names.WithLogger(logging.Discard()))
+			r.ObservePoints([]point.Point{at(120, "org", "prod", "frontend", "w", 0)})
+			Expect(r.Stats().Apps).To(Equal(int64(1)))
+		})
+
+		It("rewrites the mapping at most once per interval without renames", func() {
+			r := names.NewRegistry(names.WithFile(file), names.WithLogger(logging.Discard()))
+			r.ObservePoints([]point.Point{at(60, "org", "prod", "web", "w", 0)})
+			r.ObservePoints([]point.Point{at(120, "org", "prod", "worker", "k", 0)})
+
+			data, err := ioutil.ReadFile(file)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).ToNot(ContainSubstring("worker"))
+
+			Expect(r.Close()).To(Succeed())
+			data, err = ioutil.ReadFile(file)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("worker"))
+		})
+	})
+})
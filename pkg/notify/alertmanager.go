@@ -0,0 +1,294 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+)
+
+// Alertmanager posts alerts to the v2 API of Prometheus Alertmanager, to
+// every instance of a cluster like Prometheus does.
+//
+// Alertmanager resolves alerts that have not been posted again before their
+// end time, so the firing alerts are posted again on every resend interval
+// with an end time a few intervals ahead. Should the reporter stop, the
+// alerts resolve on their own.
+type Alertmanager struct {
+	urls           []string
+	labels         map[string]string
+	severity       string
+	bucketWidth    time.Duration
+	resendInterval time.Duration
+	now            func() time.Time
+	httpClient     HTTPClient
+	logger         *logging.Logger
+
+	mu     sync.Mutex
+	firing map[string]alert.Alert
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// AlertmanagerAlert is an alert as accepted by POST /api/v2/alerts.
+type AlertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    string            `json:"startsAt,omitempty"`
+	EndsAt      string            `json:"endsAt,omitempty"`
+}
+
+// NewAlertmanager returns an Alertmanager posting to the Alertmanagers at
+// urls, e.g. http://alertmanager:9093, and starts resending firing alerts.
+func NewAlertmanager(urls []string, opts ...AlertmanagerOption) *Alertmanager {
+	am := &Alertmanager{
+		severity:       "warning",
+		bucketWidth:    time.Minute,
+		resendInterval: time.Minute,
+		now:            time.Now,
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+		logger:         logging.Default(),
+		firing:         make(map[string]alert.Alert),
+		done:           make(chan struct{}),
+	}
+
+	for _, u := range urls {
+		am.urls = append(am.urls, strings.TrimSuffix(u, "/")+"/api/v2/alerts")
+	}
+
+	for _, o := range opts {
+		o(am)
+	}
+	am.logger = am.logger.With(logging.Fields{"notifier": "alertmanager"})
+
+	if am.resendInterval > 0 {
+		am.wg.Add(1)
+		go am.resend()
+	}
+
+	return am
+}
+
+// Notify posts an alert that fired or resolved and keeps track of the
+// firing alerts to resend them.
+func (am *Alertmanager) Notify(a alert.Alert) error {
+	am.mu.Lock()
+	if a.Status == alert.Firing {
+		am.firing[DedupKey(a)] = a
+	} else {
+		delete(am.firing, DedupKey(a))
+	}
+	am.mu.Unlock()
+
+	return am.post([]alert.Alert{a})
+}
+
+// Restore adds the firing alerts to the ones that are resent, e.g. after
+// they have been restored from the state of the alert engine on startup.
+// Alerts whose notification was suppressed by a silence are left out.
+func (am *Alertmanager) Restore(alerts []alert.Alert) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	for _, a := range alerts {
+		if a.Status == alert.Firing && !a.Suppressed {
+			am.firing[DedupKey(a)] = a
+		}
+	}
+}
+
+// Close stops resending the firing alerts.
+func (am *Alertmanager) Close() error {
+	close(am.done)
+	am.wg.Wait()
+
+	return nil
+}
+
+func (am *Alertmanager) resend() {
+	defer am.wg.Done()
+
+	t := time.NewTicker(am.resendInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			alerts := am.active()
+			if len(alerts) == 0 {
+				continue
+			}
+
+			if err := am.post(alerts); err != nil {
+				am.logger.Warn("failed to resend firing alerts", logging.Fields{
+					"stage":  "notify",
+					"alerts": len(alerts),
+					"error":  err,
+				})
+			}
+		case <-am.done:
+			return
+		}
+	}
+}
+
+func (am *Alertmanager) active() []alert.Alert {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	keys := make([]string, 0, len(am.firing))
+	for key := range am.firing {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	alerts := make([]alert.Alert, 0, len(keys))
+	for _, key := range keys {
+		alerts = append(alerts, am.firing[key])
+	}
+
+	return alerts
+}
+
+// post sends the alerts to every Alertmanager. It fails if any of them did
+// not accept them.
+func (am *Alertmanager) post(alerts []alert.Alert) error {
+	payload := make([]AlertmanagerAlert, 0, len(alerts))
+	for _, a := range alerts {
+		payload = append(payload, am.alert(a))
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, url := range am.urls {
+		if err := postJSON(am.httpClient, url, body); err != nil {
+			if len(am.urls) == 1 {
+				return err
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s", url, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to post alerts: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func (am *Alertmanager) alert(a alert.Alert) AlertmanagerAlert {
+	labels := map[string]string{
+		"alertname": a.Rule,
+		"org":       a.Subject.Org,
+		"space":     a.Subject.Space,
+		"app":       a.Subject.App,
+		"severity":  am.severity,
+	}
+	if a.Subject.Index >= 0 {
+		labels["instance"] = strconv.Itoa(a.Subject.Index)
+	}
+	for k, v := range am.labels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
+
+	rate := a.Count / am.bucketWidth.Seconds()
+
+	out := AlertmanagerAlert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":   Summary(a),
+			"app_guid":  a.Subject.AppGUID,
+			"value":     encoding.FormatValue(a.Value),
+			"threshold": encoding.FormatValue(a.Threshold),
+			"rate":      strconv.FormatFloat(rate, 'f', 2, 64) + "/s",
+			"share":     strconv.FormatFloat(a.Share, 'f', 2, 64) + "%",
+		},
+		StartsAt: time.Unix(a.ActiveAt, 0).UTC().Format(time.RFC3339),
+	}
+
+	if a.Status == alert.Resolved {
+		out.EndsAt = time.Unix(a.ResolvedAt, 0).UTC().Format(time.RFC3339)
+	} else if am.resendInterval > 0 {
+		out.EndsAt = am.now().Add(4 * am.resendInterval).UTC().Format(time.RFC3339)
+	}
+
+	return out
+}
+
+// AlertmanagerOption is a func that is used to configure optional settings
+// on an Alertmanager.
+type AlertmanagerOption func(*Alertmanager)
+
+// WithAlertmanagerLabels returns an AlertmanagerOption for adding labels to
+// every alert, e.g. the foundation. They do not override the labels
+// describing the alert.
+func WithAlertmanagerLabels(labels map[string]string) AlertmanagerOption {
+	return func(am *Alertmanager) {
+		am.labels = labels
+	}
+}
+
+// WithAlertmanagerSeverity returns an AlertmanagerOption for configuring the
+// severity label of the alerts. It defaults to warning.
+func WithAlertmanagerSeverity(severity string) AlertmanagerOption {
+	return func(am *Alertmanager) {
+		am.severity = severity
+	}
+}
+
+// WithRateBucketWidth returns an AlertmanagerOption for configuring the
+// interval the ingress of an alert was counted over, used for annotating
+// the alert with its rate per second. It defaults to a minute.
+func WithRateBucketWidth(d time.Duration) AlertmanagerOption {
+	return func(am *Alertmanager) {
+		am.bucketWidth = d
+	}
+}
+
+// WithResendInterval returns an AlertmanagerOption for configuring how often
+// the firing alerts are posted again. Zero disables resending and posts
+// firing alerts without an end time.
+func WithResendInterval(d time.Duration) AlertmanagerOption {
+	return func(am *Alertmanager) {
+		am.resendInterval = d
+	}
+}
+
+// WithAlertmanagerClock returns an AlertmanagerOption for configuring the
+// clock the end time of firing alerts is computed with.
+func WithAlertmanagerClock(now func() time.Time) AlertmanagerOption {
+	return func(am *Alertmanager) {
+		am.now = now
+	}
+}
+
+// WithAlertmanagerHTTPClient returns an AlertmanagerOption for configuring
+// the HTTPClient alerts are posted with.
+func WithAlertmanagerHTTPClient(c HTTPClient) AlertmanagerOption {
+	return func(am *Alertmanager) {
+		am.httpClient = c
+	}
+}
+
+// WithAlertmanagerLogger returns an AlertmanagerOption for configuring the
+// logger used by the Alertmanager.
+func WithAlertmanagerLogger(l *logging.Logger) AlertmanagerOption {
+	return func(am *Alertmanager) {
+		am.logger = l
+	}
+}
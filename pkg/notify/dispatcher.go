@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+)
+
+// Dispatcher delivers the notifications of a single backend in the
+// background, so that a slow backend does not delay reporting. Failed
+// notifications are retried with a doubling backoff, unless the backend
+// rejected them, and at most the configured number of firing notifications
+// is sent per rate limit period. Firing notifications over the limit are
+// dropped; resolved ones are always sent, so that the backend does not keep
+// an incident open.
+type Dispatcher struct {
+	name       string
+	notifier   alert.Notifier
+	queue      chan alert.Alert
+	maxRetries int
+	backoff    time.Duration
+	limit      int
+	period     time.Duration
+	now        func() time.Time
+	logger     *logging.Logger
+
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	sent   []time.Time
+	failed int
+	errs   []string
+}
+
+// NewDispatcher returns a Dispatcher delivering to notifier and starts its
+// worker. The name identifies the backend in logs.
+func NewDispatcher(name string, notifier alert.Notifier, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		name:       name,
+		notifier:   notifier,
+		maxRetries: 3,
+		backoff:    time.Second,
+		now:        time.Now,
+		logger:     logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(d)
+	}
+	d.logger = d.logger.With(logging.Fields{"notifier": name})
+	d.queue = make(chan alert.Alert, 100)
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for a := range d.queue {
+			d.deliver(a)
+		}
+	}()
+
+	return d
+}
+
+// Notify queues the notification. It fails when the queue is full.
+func (d *Dispatcher) Notify(a alert.Alert) error {
+	select {
+	case d.queue <- a:
+		return nil
+	default:
+		return fmt.Errorf("notification queue of %s is full", d.name)
+	}
+}
+
+// Close stops accepting notifications, waits until the queued ones have been
+// handled, closes the notifier if it can be closed and returns an error if
+// any of the notifications failed.
+func (d *Dispatcher) Close() error {
+	close(d.queue)
+	d.wg.Wait()
+
+	if c, ok := d.notifier.(interface{ Close() error }); ok {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("%s: %s", d.name, err)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.failed > 0 {
+		return fmt.Errorf("%s: %d notifications failed: %s", d.name, d.failed, d.errs[len(d.errs)-1])
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) deliver(a alert.Alert) {
+	fields := logging.Fields{
+		"stage":   "notify",
+		"rule":    a.Rule,
+		"subject": Subject(a),
+		"status":  a.Status,
+	}
+
+	if a.Status == alert.Firing && !d.allow() {
+		d.logger.Warn("notification rate limit exceeded, dropping notification", fields)
+		return
+	}
+
+	backoff := d.backoff
+	for attempt := 0; ; attempt++ {
+		err := d.notifier.Notify(a)
+		if err == nil {
+			d.logger.Debug("sent notification", fields)
+			return
+		}
+
+		fields["attempt"] = attempt + 1
+		fields["error"] = err
+		if !temporary(err) || attempt >= d.maxRetries {
+			d.mu.Lock()
+			d.failed++
+			d.errs = append(d.errs, err.Error())
+			d.mu.Unlock()
+
+			d.logger.Error("failed to send notification", fields)
+			return
+		}
+
+		fields["backoff"] = backoff.String()
+		d.logger.Warn("failed to send notification, retrying", fields)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// allow reports whether another firing notification may be sent within the
+// rate limit and records it if so.
+func (d *Dispatcher) allow() bool {
+	if d.limit <= 0 {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	recent := d.sent[:0]
+	for _, t := range d.sent {
+		if now.Sub(t) < d.period {
+			recent = append(recent, t)
+		}
+	}
+	d.sent = recent
+
+	if len(d.sent) >= d.limit {
+		return false
+	}
+	d.sent = append(d.sent, now)
+
+	return true
+}
+
+// temporary reports whether a failed notification is worth retrying. Only
+// responses rejecting the notification are not.
+func temporary(err error) bool {
+	if s, ok := err.(*StatusError); ok {
+		return s.Temporary()
+	}
+
+	return true
+}
+
+// DispatcherOption is a func that is used to configure optional settings on
+// a Dispatcher.
+type DispatcherOption func(*Dispatcher)
+
+// WithRetries returns a DispatcherOption for configuring how often a failed
+// notification is retried and the wait before the first retry.
+func WithRetries(n int, backoff time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.maxRetries = n
+		d.backoff = backoff
+	}
+}
+
+// WithRateLimit returns a DispatcherOption for sending at most limit firing
+// notifications per period. It is unlimited by default.
+func WithRateLimit(limit int, period time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.limit = limit
+		d.period = period
+	}
+}
+
+// WithClock returns a DispatcherOption for configuring the clock the rate
+// limit is measured with.
+func WithClock(now func() time.Time) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.now = now
+	}
+}
+
+// WithLogger returns a DispatcherOption for configuring the logger used by
+// the Dispatcher.
+func WithLogger(l *logging.Logger) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.logger = l
+	}
+}
@@ -0,0 +1,79 @@
+// Package notify sends alerts that fired or resolved to webhooks, Slack,
+// PagerDuty and Prometheus Alertmanager.
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+)
+
+// HTTPClient is the interface used for sending notifications.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// StatusError is returned when a notification is answered with an
+// unsuccessful status code.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("expected successful status code, got %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether sending the notification again may succeed,
+// i.e. for server errors and rate limiting.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// postJSON posts body to url and fails with a StatusError on a non 2xx
+// response.
+func postJSON(c HTTPClient, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "noisy-neighbor-reporter")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
+
+// Subject returns a human readable name of what an alert is about, e.g.
+// org/space/app or org/space/app/1 for an instance.
+func Subject(a alert.Alert) string {
+	name := a.Subject.Org + "/" + a.Subject.Space + "/" + a.Subject.App
+	if a.Subject.Index >= 0 {
+		name += "/" + strconv.Itoa(a.Subject.Index)
+	}
+
+	return name
+}
+
+// Summary returns a one line description of an alert.
+func Summary(a alert.Alert) string {
+	return fmt.Sprintf("%s %s for %s: value %s, threshold %s",
+		a.Rule, a.Status, Subject(a), encoding.FormatValue(a.Value), encoding.FormatValue(a.Threshold))
+}
@@ -0,0 +1,411 @@
+package notify_test
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/notify"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	firing = alert.Alert{
+		Rule:      "loud",
+		Subject:   alert.Subject{Org: "org", Space: "prod", App: "noisy", AppGUID: "n", Index: -1},
+		Status:    alert.Firing,
+		Value:     150,
+		Threshold: 100,
+		Count:     9000,
+		Share:     25,
+		Breaches:  2,
+		ActiveAt:  1520259460,
+		FiredAt:   1520259520,
+	}
+	resolved = func() alert.Alert {
+		a := firing
+		a.Status = alert.Resolved
+		a.Value = 40
+		a.ResolvedAt = 1520259580
+		return a
+	}()
+)
+
+var _ = Describe("Webhook", func() {
+	It("posts the default template", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		w, err := notify.NewWebhook(server.URL, notify.DefaultWebhookTemplate)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(w.Notify(firing)).To(Succeed())
+		Expect(server.requests()).To(HaveLen(1))
+		Expect(server.requests()[0]).To(MatchJSON(`{
+			"summary": "loud firing for org/prod/noisy: value 150, threshold 100",
+			"alert": {
+				"rule": "loud",
+				"subject": {"org": "org", "space": "prod", "app": "noisy", "app_guid": "n", "index": -1},
+				"status": "firing",
+				"value": 150,
+				"threshold": 100,
+				"count": 9000,
+				"share": 25,
+				"breaches": 2,
+				"active_at": 1520259460,
+				"fired_at": 1520259520
+			}
+		}`))
+	})
+
+	It("posts a custom template", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		w, err := notify.NewWebhook(server.URL, `{"text": {{ json .Summary }}, "app": {{ json .Alert.Subject.App }}, "who": {{ json .Subject }}}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(w.Notify(resolved)).To(Succeed())
+		Expect(server.requests()[0]).To(MatchJSON(`{
+			"text": "loud resolved for org/prod/noisy: value 40, threshold 100",
+			"app": "noisy",
+			"who": "org/prod/noisy"
+		}`))
+	})
+
+	It("rejects invalid templates", func() {
+		_, err := notify.NewWebhook("http://localhost", `{{ .Alert`)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns a status error for unsuccessful responses", func() {
+		server := newRecorder(http.StatusBadRequest)
+		defer server.Close()
+
+		w, err := notify.NewWebhook(server.URL, notify.DefaultWebhookTemplate)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = w.Notify(firing)
+		Expect(err).To(BeAssignableToTypeOf(&notify.StatusError{}))
+		Expect(err.(*notify.StatusError).Temporary()).To(BeFalse())
+	})
+})
+
+var _ = Describe("Slack", func() {
+	It("posts a red message for a firing alert", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		s := notify.NewSlack(server.URL, notify.WithChannel("#alerts"))
+
+		Expect(s.Notify(firing)).To(Succeed())
+		Expect(server.requests()[0]).To(MatchJSON(`{
+			"text": "loud firing for org/prod/noisy: value 150, threshold 100",
+			"channel": "#alerts",
+			"username": "noisy-neighbor",
+			"attachments": [{
+				"fallback": "loud firing for org/prod/noisy: value 150, threshold 100",
+				"color": "danger",
+				"title": "loud firing",
+				"fields": [
+					{"title": "Org", "value": "org", "short": true},
+					{"title": "Space", "value": "prod", "short": true},
+					{"title": "App", "value": "noisy", "short": true},
+					{"title": "Value", "value": "150", "short": true},
+					{"title": "Threshold", "value": "100", "short": true}
+				],
+				"ts": 1520259520
+			}]
+		}`))
+	})
+
+	It("posts a green message for a resolved alert on an instance", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		a := resolved
+		a.Subject.Index = 2
+
+		Expect(notify.NewSlack(server.URL).Notify(a)).To(Succeed())
+		Expect(server.requests()[0]).To(ContainSubstring(`"color":"good"`))
+		Expect(server.requests()[0]).To(ContainSubstring(`{"title":"Instance","value":"2","short":true}`))
+		Expect(server.requests()[0]).To(ContainSubstring(`"ts":1520259580`))
+	})
+})
+
+var _ = Describe("PagerDuty", func() {
+	It("triggers an incident for a firing alert", func() {
+		server := newRecorder(http.StatusAccepted)
+		defer server.Close()
+
+		p := notify.NewPagerDuty("routing-key",
+			notify.WithPagerDutyURL(server.URL),
+			notify.WithSeverity("error"),
+			notify.WithSource("eu-1"),
+		)
+
+		Expect(p.Notify(firing)).To(Succeed())
+		Expect(server.requests()[0]).To(MatchJSON(`{
+			"routing_key": "routing-key",
+			"event_action": "trigger",
+			"dedup_key": "noisy-neighbor/loud/n",
+			"payload": {
+				"summary": "loud firing for org/prod/noisy: value 150, threshold 100",
+				"source": "eu-1",
+				"severity": "error",
+				"timestamp": "2018-03-05T14:18:40Z",
+				"component": "noisy",
+				"group": "org/prod",
+				"class": "loud",
+				"custom_details": {
+					"org": "org",
+					"space": "prod",
+					"app": "noisy",
+					"app_guid": "n",
+					"value": 150,
+					"threshold": 100,
+					"active_at": "2018-03-05T14:17:40Z"
+				}
+			}
+		}`))
+	})
+
+	It("resolves the incident with the same dedup key", func() {
+		server := newRecorder(http.StatusAccepted)
+		defer server.Close()
+
+		p := notify.NewPagerDuty("routing-key", notify.WithPagerDutyURL(server.URL))
+
+		Expect(p.Notify(resolved)).To(Succeed())
+		Expect(server.requests()[0]).To(MatchJSON(`{
+			"routing_key": "routing-key",
+			"event_action": "resolve",
+			"dedup_key": "noisy-neighbor/loud/n"
+		}`))
+	})
+
+	It("includes the instance in the dedup key", func() {
+		a := firing
+		a.Subject.Index = 3
+
+		Expect(notify.DedupKey(a)).To(Equal("noisy-neighbor/loud/n/3"))
+	})
+})
+
+var _ = Describe("Alertmanager", func() {
+	now := func() time.Time { return time.Unix(1520259600, 0) }
+
+	It("posts a firing alert with labels and annotations", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		am := notify.NewAlertmanager([]string{server.URL + "/"},
+			notify.WithAlertmanagerLabels(map[string]string{"foundation": "eu-1", "app": "ignored"}),
+			notify.WithAlertmanagerSeverity("critical"),
+			notify.WithRateBucketWidth(time.Minute),
+			notify.WithAlertmanagerClock(now),
+			notify.WithAlertmanagerLogger(logging.Discard()),
+		)
+		defer am.Close()
+
+		Expect(am.Notify(firing)).To(Succeed())
+		Expect(server.requests()).To(HaveLen(1))
+		Expect(server.paths()).To(Equal([]string{"/api/v2/alerts"}))
+		Expect(server.requests()[0]).To(MatchJSON(`[{
+			"labels": {
+				"alertname": "loud",
+				"org": "org",
+				"space": "prod",
+				"app": "noisy",
+				"severity": "critical",
+				"foundation": "eu-1"
+			},
+			"annotations": {
+				"summary": "loud firing for org/prod/noisy: value 150, threshold 100",
+				"app_guid": "n",
+				"value": "150",
+				"threshold": "100",
+				"rate": "150.00/s",
+				"share": "25.00%"
+			},
+			"startsAt": "2018-03-05T14:17:40Z",
+			"endsAt": "2018-03-05T14:24:00Z"
+		}]`))
+	})
+
+	It("posts a resolved alert with its end time", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		am := notify.NewAlertmanager([]string{server.URL}, notify.WithAlertmanagerLogger(logging.Discard()))
+		defer am.Close()
+
+		a := resolved
+		a.Subject.Index = 1
+
+		Expect(am.Notify(a)).To(Succeed())
+		Expect(server.requests()[0]).To(ContainSubstring(`"instance":"1"`))
+		Expect(server.requests()[0]).To(ContainSubstring(`"endsAt":"2018-03-05T14:19:40Z"`))
+	})
+
+	It("resends firing alerts until they resolve", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		am := notify.NewAlertmanager([]string{server.URL},
+			notify.WithResendInterval(10*time.Millisecond),
+			notify.WithAlertmanagerLogger(logging.Discard()),
+		)
+		defer am.Close()
+
+		Expect(am.Notify(firing)).To(Succeed())
+		Eventually(func() int { return len(server.requests()) }).Should(BeNumerically(">=", 3))
+
+		Expect(am.Notify(resolved)).To(Succeed())
+		sent := len(server.requests())
+		Consistently(func() int { return len(server.requests()) }).Should(Equal(sent))
+	})
+
+	It("resends restored alerts", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		am := notify.NewAlertmanager([]string{server.URL},
+			notify.WithResendInterval(10*time.Millisecond),
+			notify.WithAlertmanagerLogger(logging.Discard()),
+		)
+		defer am.Close()
+
+		pending := firing
+		pending.Rule = "pending"
+		pending.Status = alert.Pending
+		suppressed := firing
+		suppressed.Rule = "silenced"
+		suppressed.Suppressed = true
+		am.Restore([]alert.Alert{firing, pending, suppressed})
+
+		Eventually(server.requests).ShouldNot(BeEmpty())
+		Expect(server.requests()[0]).To(ContainSubstring(`"alertname":"loud"`))
+		Expect(server.requests()[0]).ToNot(ContainSubstring(`"alertname":"pending"`))
+		Expect(server.requests()[0]).ToNot(ContainSubstring(`"alertname":"silenced"`))
+	})
+
+	It("posts to every Alertmanager of a cluster", func() {
+		up, down := newRecorder(), newRecorder(http.StatusServiceUnavailable)
+		defer up.Close()
+		defer down.Close()
+
+		am := notify.NewAlertmanager([]string{up.URL, down.URL}, notify.WithAlertmanagerLogger(logging.Discard()))
+		defer am.Close()
+
+		Expect(am.Notify(firing)).To(MatchError(ContainSubstring(down.URL)))
+		Expect(up.requests()).To(HaveLen(1))
+		Expect(down.requests()).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("Dispatcher", func() {
+	It("delivers notifications in the background", func() {
+		server := newRecorder()
+		defer server.Close()
+
+		d := notify.NewDispatcher("slack", notify.NewSlack(server.URL), notify.WithLogger(logging.Discard()))
+
+		Expect(d.Notify(firing)).To(Succeed())
+		Expect(d.Notify(resolved)).To(Succeed())
+		Expect(d.Close()).To(Succeed())
+
+		Expect(server.requests()).To(HaveLen(2))
+		Expect(server.requests()[1]).To(ContainSubstring("resolved"))
+	})
+
+	It("retries server errors", func() {
+		server := newRecorder(http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK)
+		defer server.Close()
+
+		d := notify.NewDispatcher("slack", notify.NewSlack(server.URL),
+			notify.WithRetries(3, time.Millisecond),
+			notify.WithLogger(logging.Discard()),
+		)
+
+		Expect(d.Notify(firing)).To(Succeed())
+		Expect(d.Close()).To(Succeed())
+		Expect(server.requests()).To(HaveLen(3))
+	})
+
+	It("does not retry rejected notifications", func() {
+		server := newRecorder(http.StatusBadRequest)
+		defer server.Close()
+
+		d := notify.NewDispatcher("slack", notify.NewSlack(server.URL),
+			notify.WithRetries(3, time.Millisecond),
+			notify.WithLogger(logging.Discard()),
+		)
+
+		Expect(d.Notify(firing)).To(Succeed())
+		Expect(d.Close()).To(MatchError(ContainSubstring("slack: 1 notifications failed")))
+		Expect(server.requests()).To(HaveLen(1))
+	})
+
+	It("gives up after the configured retries", func() {
+		n := &fakeNotifier{err: errors.New("connection refused")}
+		d := notify.NewDispatcher("webhook", n,
+			notify.WithRetries(2, time.Millisecond),
+			notify.WithLogger(logging.Discard()),
+		)
+
+		Expect(d.Notify(firing)).To(Succeed())
+		Expect(d.Close()).To(MatchError(ContainSubstring("connection refused")))
+		Expect(n.calls()).To(Equal(3))
+	})
+
+	It("drops firing notifications over the rate limit", func() {
+		now := int64(1520259520)
+		n := &fakeNotifier{}
+		d := notify.NewDispatcher("pagerduty", n,
+			notify.WithRateLimit(2, time.Hour),
+			notify.WithClock(func() time.Time { return time.Unix(atomic.LoadInt64(&now), 0) }),
+			notify.WithLogger(logging.Discard()),
+		)
+
+		for i := 0; i < 3; i++ {
+			Expect(d.Notify(firing)).To(Succeed())
+		}
+		Eventually(n.calls).Should(Equal(2))
+
+		Consistently(n.calls).Should(Equal(2))
+		Expect(d.Notify(resolved)).To(Succeed())
+		Eventually(n.calls).Should(Equal(3))
+
+		atomic.AddInt64(&now, 3600)
+		Expect(d.Notify(firing)).To(Succeed())
+		Expect(d.Close()).To(Succeed())
+		Expect(n.calls()).To(Equal(4))
+	})
+})
+
+type fakeNotifier struct {
+	mu     sync.Mutex
+	err    error
+	_calls int
+}
+
+func (f *fakeNotifier) Notify(alert.Alert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f._calls++
+	return f.err
+}
+
+func (f *fakeNotifier) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f._calls
+}
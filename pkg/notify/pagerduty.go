@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+)
+
+// DefaultPagerDutyURL is the PagerDuty Events API v2 endpoint.
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty triggers and resolves PagerDuty incidents with the Events API
+// v2. The dedup key is derived from the rule and the subject, so that an
+// alert resolves the incident it triggered and an alert firing again while
+// the incident is open does not open another one.
+type PagerDuty struct {
+	url        string
+	routingKey string
+	severity   string
+	source     string
+	httpClient HTTPClient
+}
+
+// PagerDutyEvent is the body of an Events API v2 request.
+type PagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *PagerDutyPayload `json:"payload,omitempty"`
+}
+
+// PagerDutyPayload describes a triggered incident.
+type PagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	Group         string                 `json:"group,omitempty"`
+	Class         string                 `json:"class,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// NewPagerDuty returns a PagerDuty sending events with the integration's
+// routing key.
+func NewPagerDuty(routingKey string, opts ...PagerDutyOption) *PagerDuty {
+	p := &PagerDuty{
+		url:        DefaultPagerDutyURL,
+		routingKey: routingKey,
+		severity:   "warning",
+		source:     "noisy-neighbor-reporter",
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, o := range opts {
+		o(p)
+	}
+
+	return p
+}
+
+// Notify triggers an incident for a firing alert and resolves it for a
+// resolved one.
+func (p *PagerDuty) Notify(a alert.Alert) error {
+	body, err := json.Marshal(p.event(a))
+	if err != nil {
+		return err
+	}
+
+	return postJSON(p.httpClient, p.url, body)
+}
+
+func (p *PagerDuty) event(a alert.Alert) PagerDutyEvent {
+	e := PagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    DedupKey(a),
+	}
+
+	if a.Status == alert.Resolved {
+		e.EventAction = "resolve"
+		return e
+	}
+
+	e.Payload = &PagerDutyPayload{
+		Summary:   Summary(a),
+		Source:    p.source,
+		Severity:  p.severity,
+		Timestamp: time.Unix(a.FiredAt, 0).UTC().Format(time.RFC3339),
+		Component: a.Subject.App,
+		Group:     a.Subject.Org + "/" + a.Subject.Space,
+		Class:     a.Rule,
+		CustomDetails: map[string]interface{}{
+			"org":       a.Subject.Org,
+			"space":     a.Subject.Space,
+			"app":       a.Subject.App,
+			"app_guid":  a.Subject.AppGUID,
+			"value":     a.Value,
+			"threshold": a.Threshold,
+			"active_at": time.Unix(a.ActiveAt, 0).UTC().Format(time.RFC3339),
+		},
+	}
+	if a.Subject.Index >= 0 {
+		e.Payload.CustomDetails["instance"] = a.Subject.Index
+	}
+
+	return e
+}
+
+// DedupKey returns the key identifying the incident of an alert.
+func DedupKey(a alert.Alert) string {
+	key := "noisy-neighbor/" + a.Rule + "/" + a.Subject.AppGUID
+	if a.Subject.Index >= 0 {
+		key += "/" + strconv.Itoa(a.Subject.Index)
+	}
+
+	return key
+}
+
+// PagerDutyOption is a func that is used to configure optional settings on
+// a PagerDuty.
+type PagerDutyOption func(*PagerDuty)
+
+// WithPagerDutyURL returns a PagerDutyOption for configuring the events
+// endpoint, e.g. for the EU service region.
+func WithPagerDutyURL(url string) PagerDutyOption {
+	return func(p *PagerDuty) {
+		p.url = url
+	}
+}
+
+// WithSeverity returns a PagerDutyOption for configuring the severity of
+// triggered incidents: critical, error, warning or info.
+func WithSeverity(severity string) PagerDutyOption {
+	return func(p *PagerDuty) {
+		p.severity = severity
+	}
+}
+
+// WithSource returns a PagerDutyOption for configuring the source of
+// triggered incidents, e.g. the foundation.
+func WithSource(source string) PagerDutyOption {
+	return func(p *PagerDuty) {
+		p.source = source
+	}
+}
+
+// WithPagerDutyHTTPClient returns a PagerDutyOption for configuring the
+// HTTPClient events are sent with.
+func WithPagerDutyHTTPClient(c HTTPClient) PagerDutyOption {
+	return func(p *PagerDuty) {
+		p.httpClient = c
+	}
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+)
+
+// Slack posts alerts to a Slack incoming webhook, or any chat service
+// accepting the same payload such as Mattermost or Rocket.Chat. Firing
+// alerts are shown in red, resolved alerts in green.
+type Slack struct {
+	url        string
+	channel    string
+	username   string
+	httpClient HTTPClient
+}
+
+// SlackMessage is the payload of an incoming webhook.
+type SlackMessage struct {
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Attachments []SlackAttachment `json:"attachments,omitempty"`
+}
+
+// SlackAttachment is a colored block below the text of a message.
+type SlackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Fields   []SlackField `json:"fields"`
+	Ts       int64        `json:"ts"`
+}
+
+// SlackField is a labelled value of an attachment.
+type SlackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// NewSlack returns a Slack posting to the incoming webhook at url.
+func NewSlack(url string, opts ...SlackOption) *Slack {
+	s := &Slack{
+		url:        url,
+		username:   "noisy-neighbor",
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+// Notify posts a message describing the alert.
+func (s *Slack) Notify(a alert.Alert) error {
+	body, err := json.Marshal(s.message(a))
+	if err != nil {
+		return err
+	}
+
+	return postJSON(s.httpClient, s.url, body)
+}
+
+func (s *Slack) message(a alert.Alert) SlackMessage {
+	color, ts := "danger", a.FiredAt
+	if a.Status == alert.Resolved {
+		color, ts = "good", a.ResolvedAt
+	}
+
+	fields := []SlackField{
+		{Title: "Org", Value: a.Subject.Org, Short: true},
+		{Title: "Space", Value: a.Subject.Space, Short: true},
+		{Title: "App", Value: a.Subject.App, Short: true},
+	}
+	if a.Subject.Index >= 0 {
+		fields = append(fields, SlackField{Title: "Instance", Value: strconv.Itoa(a.Subject.Index), Short: true})
+	}
+	fields = append(fields,
+		SlackField{Title: "Value", Value: encoding.FormatValue(a.Value), Short: true},
+		SlackField{Title: "Threshold", Value: encoding.FormatValue(a.Threshold), Short: true},
+	)
+
+	summary := Summary(a)
+
+	return SlackMessage{
+		Text:     summary,
+		Channel:  s.channel,
+		Username: s.username,
+		Attachments: []SlackAttachment{{
+			Fallback: summary,
+			Color:    color,
+			Title:    a.Rule + " " + string(a.Status),
+			Fields:   fields,
+			Ts:       ts,
+		}},
+	}
+}
+
+// SlackOption is a func that is used to configure optional settings on a
+// Slack.
+type SlackOption func(*Slack)
+
+// WithChannel returns a SlackOption for overriding the channel of the
+// incoming webhook.
+func WithChannel(channel string) SlackOption {
+	return func(s *Slack) {
+		s.channel = channel
+	}
+}
+
+// WithUsername returns a SlackOption for configuring the name messages are
+// posted as.
+func WithUsername(username string) SlackOption {
+	return func(s *Slack) {
+		s.username = username
+	}
+}
+
+// WithSlackHTTPClient returns a SlackOption for configuring the HTTPClient
+// messages are posted with.
+func WithSlackHTTPClient(c HTTPClient) SlackOption {
+	return func(s *Slack) {
+		s.httpClient = c
+	}
+}
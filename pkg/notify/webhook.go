@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/alert"
+)
+
+// DefaultWebhookTemplate renders the alert as JSON together with its
+// summary.
+const DefaultWebhookTemplate = `{"summary": {{ json .Summary }}, "alert": {{ json .Alert }}}`
+
+// Webhook posts a JSON document rendered from a template for every alert.
+//
+// The template is executed with a WebhookData and has a json function
+// encoding its argument as JSON, e.g.
+//
+//	{"text": {{ json .Summary }}, "app": {{ json .Alert.Subject.App }}}
+type Webhook struct {
+	url        string
+	template   *template.Template
+	httpClient HTTPClient
+}
+
+// WebhookData is what the template of a Webhook is executed with.
+type WebhookData struct {
+	Alert   alert.Alert
+	Summary string
+	Subject string
+}
+
+// NewWebhook returns a Webhook posting to url. The template is parsed when
+// the Webhook is created, so that mistakes surface at startup.
+func NewWebhook(url, tmpl string, opts ...WebhookOption) (*Webhook, error) {
+	t, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Webhook{
+		url:        url,
+		template:   t,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, o := range opts {
+		o(w)
+	}
+
+	return w, nil
+}
+
+// Notify posts the rendered template.
+func (w *Webhook) Notify(a alert.Alert) error {
+	var body bytes.Buffer
+	err := w.template.Execute(&body, WebhookData{
+		Alert:   a,
+		Summary: Summary(a),
+		Subject: Subject(a),
+	})
+	if err != nil {
+		return err
+	}
+
+	return postJSON(w.httpClient, w.url, body.Bytes())
+}
+
+// WebhookOption is a func that is used to configure optional settings on a
+// Webhook.
+type WebhookOption func(*Webhook)
+
+// WithWebhookHTTPClient returns a WebhookOption for configuring the
+// HTTPClient notifications are posted with.
+func WithWebhookHTTPClient(c HTTPClient) WebhookOption {
+	return func(w *Webhook) {
+		w.httpClient = c
+	}
+}
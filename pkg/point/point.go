@@ -0,0 +1,106 @@
+package point
+
+// Kinds of points.
+const (
+	// Ingress is the number of log envelopes an app instance emitted during
+	// an accumulator bucket.
+	Ingress = "ingress"
+	// Rate is the ingress of an app instance per second.
+	Rate = "rate"
+	// Share is the percentage of the total ingress of the foundation an app
+	// or app instance emitted. See Shares.
+	Share = "share"
+	// Delta is the change of the ingress of an app instance from the
+	// previous accumulator bucket.
+	Delta = "delta"
+	// ZScore is the deviation of the ingress of an app from its baseline, in
+	// standard deviations.
+	ZScore = "zscore"
+	// Score is how much an app is likely to hurt its neighbours, between 0
+	// and 100.
+	Score = "score"
+	// Rank is the position of an app when ordered by its score, starting at
+	// 1 for the highest score.
+	Rank = "rank"
+)
+
+// Point is a single value for an app instance, independent of the format it
+// is sent in. Sinks derive names, tags or labels from its structured
+// identity instead of parsing a pre-joined name.
+type Point struct {
+	// Name is the kind of the point, e.g. Ingress.
+	Name string
+	// Timestamp is the start of the accumulator bucket as a unix timestamp.
+	Timestamp int64
+	Value     float64
+	Identity  Identity
+	// Labels holds additional dimensions, e.g. the foundation. Sinks add
+	// them as tags, labels or attributes where they support them.
+	Labels map[string]string
+	// Total is the ingress of the whole foundation during the bucket,
+	// including app instances that could not be resolved and so have no
+	// points. It is set on ingress points and not sent by any sink.
+	Total float64
+}
+
+// Identity describes the app instance a point belongs to. The org and space
+// GUIDs are empty when the app metadata source does not provide them. Points
+// describing an app as a whole have an Index of -1.
+type Identity struct {
+	Org       string
+	OrgGUID   string
+	Space     string
+	SpaceGUID string
+	App       string
+	AppGUID   string
+	Index     int
+}
+
+// AppLevel reports whether the point describes an app as a whole rather
+// than a single instance.
+func (i Identity) AppLevel() bool {
+	return i.Index < 0
+}
+
+// Only returns the points of the kind.
+func Only(points []Point, kind string) []Point {
+	filtered := make([]Point, 0, len(points))
+	for _, p := range points {
+		if p.Name == kind {
+			filtered = append(filtered, p)
+		}
+	}
+
+	return filtered
+}
+
+// Shares returns the share of every ingress point of the total ingress of
+// the foundation, as a percentage between 0 and 100. Other kinds of points
+// have a share of 0. Points without a Total, e.g. ones not built from the
+// accumulator, are compared to the sum of the ingress points with their
+// timestamp instead.
+func Shares(points []Point) []float64 {
+	sums := make(map[int64]float64)
+	for _, p := range points {
+		if p.Name == Ingress {
+			sums[p.Timestamp] += p.Value
+		}
+	}
+
+	shares := make([]float64, len(points))
+	for i, p := range points {
+		if p.Name != Ingress {
+			continue
+		}
+
+		total := p.Total
+		if total == 0 {
+			total = sums[p.Timestamp]
+		}
+		if total > 0 {
+			shares[i] = 100 * p.Value / total
+		}
+	}
+
+	return shares
+}
@@ -0,0 +1,82 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Encoder appends fields in the protobuf wire format to a buffer. It covers
+// what is needed to encode the few messages the sinks send without
+// depending on generated code. Fields holding the proto3 default value are
+// omitted, except for fixed width fields which are always written.
+type Encoder struct {
+	buf []byte
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// Bytes returns the encoded message.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Varint appends a varint encoded field such as uint64, int64 or bool.
+func (e *Encoder) Varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = appendVarint(e.buf, v)
+}
+
+// Fixed64 appends a fixed64 or sfixed64 field.
+func (e *Encoder) Fixed64(field int, v uint64) {
+	e.tag(field, wireFixed64)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+// Double appends a double field.
+func (e *Encoder) Double(field int, v float64) {
+	e.Fixed64(field, math.Float64bits(v))
+}
+
+// String appends a string field.
+func (e *Encoder) String(field int, s string) {
+	if s == "" {
+		return
+	}
+	e.bytes(field, []byte(s))
+}
+
+// Message appends an embedded message that has already been encoded.
+// Messages are appended even when empty, so that repeated fields keep
+// their length.
+func (e *Encoder) Message(field int, b []byte) {
+	e.bytes(field, b)
+}
+
+func (e *Encoder) tag(field, wireType int) {
+	e.buf = appendVarint(e.buf, uint64(field<<3|wireType))
+}
+
+func (e *Encoder) bytes(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.buf = appendVarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// appendVarint appends the varint encoding of v to b, as
+// binary.PutUvarint writes it.
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
@@ -0,0 +1,104 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// Format renders a message in the protobuf wire format as indented text
+// with the field numbers, in the manner of protoc --decode_raw, so that it
+// can be read without the schema. Length delimited fields are shown as
+// strings when they are printable and as embedded messages otherwise.
+// Fixed width fields are shown as doubles unless they only make sense as
+// integers, e.g. timestamps in nanoseconds.
+func Format(b []byte) (string, error) {
+	var out strings.Builder
+	if err := format(&out, b, 0); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+func format(out *strings.Builder, b []byte, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		field := tag >> 3
+
+		switch tag & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+			fmt.Fprintf(out, "%s%d: %d\n", indent, field, v)
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			v := binary.LittleEndian.Uint64(b)
+			b = b[8:]
+			fmt.Fprintf(out, "%s%d: %s\n", indent, field, formatFixed64(v))
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errTruncated
+			}
+			v := b[n : n+int(l)]
+			b = b[n+int(l):]
+
+			if printable(v) {
+				fmt.Fprintf(out, "%s%d: %q\n", indent, field, v)
+				continue
+			}
+			var nested strings.Builder
+			if err := format(&nested, v, depth+1); err != nil {
+				fmt.Fprintf(out, "%s%d: %x\n", indent, field, v)
+				continue
+			}
+			fmt.Fprintf(out, "%s%d {\n%s%s}\n", indent, field, nested.String(), indent)
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d of field %d", tag&7, field)
+		}
+	}
+
+	return nil
+}
+
+// formatFixed64 shows v as a double, or as an integer when the double would
+// be denormal or vanishingly small, which is what the bits of an integer
+// below 2^60 look like.
+func formatFixed64(v uint64) string {
+	f := math.Float64frombits(v)
+	if f != 0 && math.Abs(f) < 1e-100 {
+		return fmt.Sprintf("%d", v)
+	}
+
+	return fmt.Sprintf("%v", f)
+}
+
+func printable(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
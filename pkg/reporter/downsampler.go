@@ -0,0 +1,373 @@
+package reporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Aggregation is the function a Downsampler combines the values of a series
+// within a window with.
+type Aggregation int
+
+// Supported aggregations.
+const (
+	SumAggregation Aggregation = iota
+	MaxAggregation
+	MeanAggregation
+)
+
+// ParseAggregation returns the Aggregation named s.
+func ParseAggregation(s string) (Aggregation, error) {
+	switch strings.ToLower(s) {
+	case "sum":
+		return SumAggregation, nil
+	case "max":
+		return MaxAggregation, nil
+	case "mean", "avg":
+		return MeanAggregation, nil
+	default:
+		return SumAggregation, fmt.Errorf("unknown aggregation %q", s)
+	}
+}
+
+func (a Aggregation) String() string {
+	switch a {
+	case MaxAggregation:
+		return "max"
+	case MeanAggregation:
+		return "mean"
+	default:
+		return "sum"
+	}
+}
+
+// Downsampler buffers the points reported every base interval and combines
+// them into one point per series and window of its own interval. Windows are
+// aligned to multiples of the interval since the unix epoch, and the points
+// of a window carry its start as their timestamp.
+//
+// A window is emitted as soon as the points of its last base interval have
+// been added. A window that did not see every base interval, e.g. the first
+// one after startup or one with a missed interval, is dropped with a
+// warning rather than emitted as a partial aggregate, and so are points of
+// a window that has already ended. The mean is taken over the base
+// intervals of the window, an instance missing from one of them counts as
+// zero. Only counts add up, points of kinds other than ingress are averaged
+// over the base intervals they were reported on unless the maximum is asked
+// for. The foundation total of the ingress points is aggregated like their
+// values, so shares stay relative to the whole foundation.
+//
+// Every report is expected to carry the whole base interval, i.e. a single
+// accumulator bucket as long as the base interval, otherwise the sum of a
+// window misses the buckets between reports.
+type Downsampler struct {
+	interval     int64
+	baseInterval int64
+	aggregation  Aggregation
+	logger       *logging.Logger
+
+	mu     sync.Mutex
+	window int64
+	// ended is the end of the last window emitted or dropped. Points before
+	// it are late and dropped.
+	ended   int64
+	buckets map[int64]bool
+	// totals holds the foundation total of the ingress of every bucket.
+	totals map[int64]float64
+	series map[seriesKey]*aggregate
+	order  []seriesKey
+}
+
+type seriesKey struct {
+	name     string
+	identity point.Identity
+	labels   string
+}
+
+type aggregate struct {
+	point point.Point
+	sum   float64
+	max   float64
+	n     int
+}
+
+// NewDownsampler returns a Downsampler emitting windows of the given
+// interval.
+func NewDownsampler(interval time.Duration, opts ...DownsamplerOption) *Downsampler {
+	d := &Downsampler{
+		interval:     int64(interval / time.Second),
+		baseInterval: 60,
+		logger:       logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(d)
+	}
+
+	if d.interval < 1 {
+		d.interval = 1
+	}
+	if d.interval%d.baseInterval != 0 {
+		d.logger.Warn("sink interval is not a multiple of the report interval, windows will cover a varying number of buckets", logging.Fields{
+			"interval":        interval.String(),
+			"report_interval": (time.Duration(d.baseInterval) * time.Second).String(),
+		})
+	}
+
+	d.reset(-1)
+
+	return d
+}
+
+// Interval returns the width of the windows.
+func (d *Downsampler) Interval() time.Duration {
+	return time.Duration(d.interval) * time.Second
+}
+
+// Add buffers the points of one or more base intervals and returns the
+// points of the windows they complete, if any.
+func (d *Downsampler) Add(points []point.Point) []point.Point {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	byTimestamp := make(map[int64][]point.Point)
+	var timestamps []int64
+	for _, p := range points {
+		if _, ok := byTimestamp[p.Timestamp]; !ok {
+			timestamps = append(timestamps, p.Timestamp)
+		}
+		byTimestamp[p.Timestamp] = append(byTimestamp[p.Timestamp], p)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	var emitted []point.Point
+	for _, ts := range timestamps {
+		window := ts - ts%d.interval
+
+		if ts < d.ended || (d.window >= 0 && window < d.window) {
+			d.logger.Warn("dropping points of a window that has already ended", logging.Fields{
+				"stage":     "aggregate",
+				"timestamp": ts,
+				"window":    window,
+			})
+			continue
+		}
+		if d.window >= 0 && window > d.window {
+			emitted = append(emitted, d.flush()...)
+		}
+		if d.window < 0 {
+			d.window = window
+		}
+
+		d.buckets[ts] = true
+		for _, p := range byTimestamp[ts] {
+			d.add(p)
+		}
+
+		if ts+d.baseInterval >= d.window+d.interval {
+			emitted = append(emitted, d.flush()...)
+		}
+	}
+
+	return emitted
+}
+
+// Flush ends the current window, e.g. on shutdown, and returns its points
+// if it saw every base interval.
+func (d *Downsampler) Flush() []point.Point {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.flush()
+}
+
+func (d *Downsampler) add(p point.Point) {
+	key := seriesKey{name: p.Name, identity: p.Identity, labels: labelsKey(p.Labels)}
+
+	a, ok := d.series[key]
+	if !ok {
+		a = &aggregate{point: p, max: p.Value}
+		d.series[key] = a
+		d.order = append(d.order, key)
+	}
+
+	if p.Name == point.Ingress && p.Total > 0 {
+		d.totals[p.Timestamp] = p.Total
+	}
+
+	a.sum += p.Value
+	a.n++
+	if p.Value > a.max {
+		a.max = p.Value
+	}
+}
+
+func (d *Downsampler) flush() []point.Point {
+	if d.window < 0 {
+		return nil
+	}
+	d.ended = d.window + d.interval
+
+	if expected := d.interval / d.baseInterval; d.interval%d.baseInterval == 0 && int64(len(d.buckets)) < expected {
+		d.logger.Warn("dropping window that did not see every report", logging.Fields{
+			"stage":    "aggregate",
+			"window":   d.window,
+			"reports":  len(d.buckets),
+			"expected": expected,
+		})
+		d.reset(-1)
+		return nil
+	}
+
+	var total, maxTotal float64
+	for _, t := range d.totals {
+		total += t
+		if t > maxTotal {
+			maxTotal = t
+		}
+	}
+	switch d.aggregation {
+	case MaxAggregation:
+		total = maxTotal
+	case MeanAggregation:
+		total /= float64(len(d.buckets))
+	}
+
+	points := make([]point.Point, 0, len(d.order))
+	for _, key := range d.order {
+		a := d.series[key]
+
+		p := a.point
+		p.Timestamp = d.window
+		switch {
+		case d.aggregation == MaxAggregation:
+			p.Value = a.max
+		case p.Name != point.Ingress && p.Name != "":
+			p.Value = a.sum / float64(a.n)
+		case d.aggregation == MeanAggregation:
+			p.Value = a.sum / float64(len(d.buckets))
+		default:
+			p.Value = a.sum
+		}
+		if p.Name == point.Ingress {
+			p.Total = total
+		}
+		points = append(points, p)
+	}
+
+	d.reset(-1)
+
+	return points
+}
+
+func (d *Downsampler) reset(window int64) {
+	d.window = window
+	d.buckets = make(map[int64]bool)
+	d.totals = make(map[int64]float64)
+	d.series = make(map[seriesKey]*aggregate)
+	d.order = nil
+}
+
+func labelsKey(labels map[string]string) string {
+	var b strings.Builder
+	for _, k := range encoding.SortedKeys(labels) {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+
+	return b.String()
+}
+
+// DownsamplingClient sends the points of a Client through a Downsampler, so
+// that the client only receives complete windows. It connects the client
+// only when a window is sent.
+type DownsamplingClient struct {
+	client      Client
+	downsampler *Downsampler
+}
+
+// NewDownsamplingClient returns a DownsamplingClient sending the windows of
+// downsampler to client.
+func NewDownsamplingClient(client Client, downsampler *Downsampler) *DownsamplingClient {
+	return &DownsamplingClient{
+		client:      client,
+		downsampler: downsampler,
+	}
+}
+
+// Connect is a no-op, the client is connected when a window is sent.
+func (c *DownsamplingClient) Connect() error {
+	return nil
+}
+
+// Disconnect is a no-op.
+func (c *DownsamplingClient) Disconnect() error {
+	return nil
+}
+
+// SendPoints buffers the points and sends the windows they complete.
+func (c *DownsamplingClient) SendPoints(points []point.Point) error {
+	return c.send(c.downsampler.Add(points))
+}
+
+// Close ends the current window, sending it if it saw every base interval.
+func (c *DownsamplingClient) Close() error {
+	return c.send(c.downsampler.Flush())
+}
+
+func (c *DownsamplingClient) send(points []point.Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := c.client.Connect(); err != nil {
+		return err
+	}
+
+	err := c.client.SendPoints(points)
+
+	if dErr := c.client.Disconnect(); err == nil {
+		err = dErr
+	}
+
+	return err
+}
+
+// DownsamplerOption is a func that is used to configure optional settings
+// on a Downsampler.
+type DownsamplerOption func(*Downsampler)
+
+// WithAggregation returns a DownsamplerOption for configuring how the values
+// of a window are combined. It defaults to their sum.
+func WithAggregation(a Aggregation) DownsamplerOption {
+	return func(d *Downsampler) {
+		d.aggregation = a
+	}
+}
+
+// WithBaseInterval returns a DownsamplerOption for configuring the interval
+// points are added at, i.e. the report interval. It defaults to a minute.
+func WithBaseInterval(i time.Duration) DownsamplerOption {
+	return func(d *Downsampler) {
+		if s := int64(i / time.Second); s > 0 {
+			d.baseInterval = s
+		}
+	}
+}
+
+// WithDownsamplerLogger returns a DownsamplerOption for configuring the
+// logger used by the Downsampler.
+func WithDownsamplerLogger(l *logging.Logger) DownsamplerOption {
+	return func(d *Downsampler) {
+		d.logger = l
+	}
+}
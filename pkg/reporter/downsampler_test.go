@@ -0,0 +1,199 @@
+package reporter_test
+
+import (
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/reporter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Downsampler", func() {
+	var (
+		app0 = point.Identity{App: "app", AppGUID: "a", Index: 0}
+		app1 = point.Identity{App: "app", AppGUID: "a", Index: 1}
+	)
+
+	tick := func(ts int64, values ...float64) []point.Point {
+		var points []point.Point
+		for i, v := range values {
+			identity := app0
+			if i == 1 {
+				identity = app1
+			}
+			points = append(points, point.Point{Name: point.Ingress, Timestamp: ts, Value: v, Identity: identity})
+		}
+		return points
+	}
+
+	newDownsampler := func(a reporter.Aggregation) *reporter.Downsampler {
+		return reporter.NewDownsampler(3*time.Minute,
+			reporter.WithAggregation(a),
+			reporter.WithBaseInterval(time.Minute),
+			reporter.WithDownsamplerLogger(logging.Discard()),
+		)
+	}
+
+	It("emits the sum of a window once its last bucket has been added", func() {
+		d := newDownsampler(reporter.SumAggregation)
+
+		Expect(d.Add(tick(1800, 1, 10))).To(BeEmpty())
+		Expect(d.Add(tick(1860, 2, 20))).To(BeEmpty())
+		Expect(d.Add(tick(1920, 3, 30))).To(Equal([]point.Point{
+			{Name: point.Ingress, Timestamp: 1800, Value: 6, Identity: app0},
+			{Name: point.Ingress, Timestamp: 1800, Value: 60, Identity: app1},
+		}))
+	})
+
+	It("takes the maximum of a window", func() {
+		d := newDownsampler(reporter.MaxAggregation)
+
+		d.Add(tick(1800, 1))
+		d.Add(tick(1860, 5))
+		Expect(d.Add(tick(1920, 3))).To(Equal([]point.Point{
+			{Name: point.Ingress, Timestamp: 1800, Value: 5, Identity: app0},
+		}))
+	})
+
+	It("takes the mean over all buckets of a window, counting missing instances as zero", func() {
+		d := newDownsampler(reporter.MeanAggregation)
+
+		d.Add(tick(1800, 3, 6))
+		d.Add(tick(1860, 3))
+		Expect(d.Add(tick(1920, 3))).To(Equal([]point.Point{
+			{Name: point.Ingress, Timestamp: 1800, Value: 3, Identity: app0},
+			{Name: point.Ingress, Timestamp: 1800, Value: 2, Identity: app1},
+		}))
+	})
+
+	It("aggregates the foundation total of the ingress like its values", func() {
+		d := newDownsampler(reporter.MeanAggregation)
+		withTotal := func(points []point.Point, total float64) []point.Point {
+			points[0].Total = total
+			return points
+		}
+
+		d.Add(withTotal(tick(1800, 1), 10))
+		d.Add(withTotal(tick(1860, 1), 20))
+		out := d.Add(withTotal(tick(1920, 1), 30))
+
+		Expect(out).To(Equal([]point.Point{
+			{Name: point.Ingress, Timestamp: 1800, Value: 1, Total: 20, Identity: app0},
+		}))
+		Expect(point.Shares(out)).To(Equal([]float64{5}))
+	})
+
+	It("averages points of other kinds over the buckets they were reported on", func() {
+		d := newDownsampler(reporter.SumAggregation)
+		app := point.Identity{App: "app", AppGUID: "a", Index: -1}
+
+		zscore := func(ts int64, v float64) point.Point {
+			return point.Point{Name: point.ZScore, Timestamp: ts, Value: v, Identity: app}
+		}
+
+		d.Add(append(tick(1800, 1), zscore(1800, 2)))
+		d.Add(tick(1860, 1))
+		Expect(d.Add(append(tick(1920, 1), zscore(1920, 4)))).To(Equal([]point.Point{
+			{Name: point.Ingress, Timestamp: 1800, Value: 3, Identity: app0},
+			{Name: point.ZScore, Timestamp: 1800, Value: 3, Identity: app},
+		}))
+	})
+
+	It("drops windows with a missed bucket", func() {
+		d := newDownsampler(reporter.SumAggregation)
+
+		d.Add(tick(1800, 1))
+		Expect(d.Add(tick(1920, 2))).To(BeEmpty())
+		Expect(d.Add(tick(1980, 2))).To(BeEmpty())
+		Expect(d.Flush()).To(BeEmpty())
+	})
+
+	It("drops the window it starts in the middle of", func() {
+		d := newDownsampler(reporter.MeanAggregation)
+
+		Expect(d.Add(tick(1860, 1))).To(BeEmpty())
+		Expect(d.Add(tick(1920, 1))).To(BeEmpty())
+		Expect(d.Add(tick(1980, 1))).To(BeEmpty())
+		Expect(d.Add(tick(2040, 2))).To(BeEmpty())
+		Expect(d.Add(tick(2100, 3))).To(Equal([]point.Point{
+			{Name: point.Ingress, Timestamp: 1980, Value: 2, Identity: app0},
+		}))
+	})
+
+	It("keeps series with different labels apart", func() {
+		d := newDownsampler(reporter.SumAggregation)
+
+		var points []point.Point
+		for _, ts := range []int64{1800, 1860, 1920} {
+			points = append(points,
+				point.Point{Name: point.Ingress, Timestamp: ts, Value: 1, Identity: app0, Labels: map[string]string{"foundation": "eu"}},
+				point.Point{Name: point.Ingress, Timestamp: ts, Value: 2, Identity: app0, Labels: map[string]string{"foundation": "us"}},
+			)
+		}
+		Expect(d.Add(points)).To(HaveLen(2))
+	})
+
+	It("drops points of a window that has already been emitted", func() {
+		d := newDownsampler(reporter.SumAggregation)
+
+		d.Add(tick(1800, 1))
+		d.Add(tick(1860, 1))
+		Expect(d.Add(tick(1920, 1))).To(HaveLen(1))
+
+		Expect(d.Add(tick(1860, 5))).To(BeEmpty())
+		d.Add(tick(1980, 1))
+		Expect(d.Add(tick(1920, 5))).To(BeEmpty())
+		d.Add(tick(2040, 1))
+		Expect(d.Add(tick(2100, 1))).To(Equal([]point.Point{
+			{Name: point.Ingress, Timestamp: 1980, Value: 3, Identity: app0},
+		}))
+	})
+
+	Describe("DownsamplingClient", func() {
+		It("only sends complete windows", func() {
+			sink := &fakeSink{}
+			c := reporter.NewDownsamplingClient(sink, newDownsampler(reporter.SumAggregation))
+
+			Expect(c.SendPoints(tick(1800, 1))).To(Succeed())
+			Expect(sink.connects()).To(Equal(0))
+			Expect(c.SendPoints(tick(1860, 1))).To(Succeed())
+			Expect(c.SendPoints(tick(1920, 1))).To(Succeed())
+			Expect(sink.batches()).To(HaveLen(1))
+
+			Expect(c.SendPoints(tick(1980, 4))).To(Succeed())
+			Expect(c.Close()).To(Succeed())
+			Expect(sink.batches()).To(Equal([][]point.Point{
+				{{Name: point.Ingress, Timestamp: 1800, Value: 3, Identity: app0}},
+			}))
+		})
+	})
+
+	It("is applied by a FanOut to the sinks that have one", func() {
+		every, downsampled := &fakeSink{}, &fakeSink{}
+		f := reporter.NewFanOut([]reporter.Sink{
+			{Name: "every", Client: every},
+			{Name: "downsampled", Client: downsampled, Downsampler: newDownsampler(reporter.SumAggregation)},
+		}, reporter.WithFanOutLogger(logging.Discard()))
+
+		for _, ts := range []int64{1800, 1860, 1920, 1980} {
+			Expect(f.SendPoints(tick(ts, 1))).To(Succeed())
+		}
+		Expect(f.Close()).To(Succeed())
+
+		Expect(every.batches()).To(HaveLen(4))
+		Expect(downsampled.batches()).To(Equal([][]point.Point{
+			{{Name: point.Ingress, Timestamp: 1800, Value: 3, Identity: app0}},
+		}))
+	})
+
+	It("parses aggregation names", func() {
+		a, err := reporter.ParseAggregation("MAX")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(a).To(Equal(reporter.MaxAggregation))
+
+		_, err = reporter.ParseAggregation("median")
+		Expect(err).To(HaveOccurred())
+	})
+})
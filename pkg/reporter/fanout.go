@@ -0,0 +1,331 @@
+package reporter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Sink configures one of the destinations of a FanOut.
+type Sink struct {
+	// Name identifies the sink in logs and stats.
+	Name   string
+	Client Client
+	// NewClient, when set, builds a replacement for a client whose attempt
+	// timed out. The timed out attempt is abandoned rather than waited for,
+	// so that a hung connection cannot block the sink. Without it the
+	// attempt is waited for, however long it takes.
+	NewClient func() Client
+
+	// QueueSize is the number of batches waiting to be sent. When the queue
+	// is full the oldest batch is dropped. It defaults to 10.
+	QueueSize int
+	// Timeout bounds a single attempt to connect, send and disconnect. It
+	// defaults to 30 seconds.
+	Timeout time.Duration
+	// Retries is the number of times a failed batch is attempted again,
+	// Backoff the wait before the first retry. The backoff doubles with
+	// every retry.
+	Retries int
+	Backoff time.Duration
+
+	// Downsampler, when set, combines the points of every tick into windows
+	// of the sink's own interval. Only complete windows are queued.
+	Downsampler *Downsampler
+}
+
+// SinkStats holds the counters of a sink.
+type SinkStats struct {
+	// Batches and Points count what has been sent successfully.
+	Batches int64
+	Points  int64
+	// Failures counts batches that could not be sent after all retries,
+	// Dropped batches that were removed from a full queue.
+	Failures int64
+	Dropped  int64
+	Retries  int64
+	Timeouts int64
+	// LastError is the error of the most recent failed attempt.
+	LastError string
+}
+
+// FanOut dispatches points to several sinks concurrently. It satisfies the
+// Client interface so that the points are built once per tick and
+// handed to every sink.
+//
+// Every sink has its own queue and worker, so that a slow or unavailable
+// sink only delays and drops its own batches. SendPoints never blocks on a
+// sink; its points are delivered in the background.
+type FanOut struct {
+	sinks  []*fanOutSink
+	logger *logging.Logger
+	wg     sync.WaitGroup
+}
+
+type fanOutSink struct {
+	Sink
+	queue  chan []point.Point
+	logger *logging.Logger
+
+	mu    sync.Mutex
+	stats SinkStats
+}
+
+// NewFanOut returns a FanOut dispatching to the given sinks and starts their
+// workers.
+func NewFanOut(sinks []Sink, opts ...FanOutOption) *FanOut {
+	f := &FanOut{
+		logger: logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(f)
+	}
+
+	for _, s := range sinks {
+		if s.QueueSize < 1 {
+			s.QueueSize = 10
+		}
+		if s.Timeout <= 0 {
+			s.Timeout = 30 * time.Second
+		}
+
+		fs := &fanOutSink{
+			Sink:   s,
+			queue:  make(chan []point.Point, s.QueueSize),
+			logger: f.logger.With(logging.Fields{"sink": s.Name}),
+		}
+		f.sinks = append(f.sinks, fs)
+
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			fs.run()
+		}()
+	}
+
+	return f
+}
+
+// Connect is a no-op, the workers connect their sinks for every batch.
+func (f *FanOut) Connect() error {
+	return nil
+}
+
+// Disconnect is a no-op.
+func (f *FanOut) Disconnect() error {
+	return nil
+}
+
+// SendPoints queues the points for every sink. Sinks with a Downsampler
+// only get the windows the points complete.
+func (f *FanOut) SendPoints(points []point.Point) error {
+	for _, s := range f.sinks {
+		batch := points
+		if s.Downsampler != nil {
+			batch = s.Downsampler.Add(points)
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		s.enqueue(batch)
+	}
+
+	return nil
+}
+
+// Close stops accepting batches, ends the current windows of downsampled
+// sinks, waits until the queued batches have been handled and
+// returns an error naming the sinks that failed to send or dropped a batch.
+// The FanOut must not be used afterwards.
+func (f *FanOut) Close() error {
+	for _, s := range f.sinks {
+		if s.Downsampler != nil {
+			if batch := s.Downsampler.Flush(); len(batch) > 0 {
+				s.enqueue(batch)
+			}
+		}
+		close(s.queue)
+	}
+	f.wg.Wait()
+
+	var failed []string
+	for _, s := range f.sinks {
+		stats := s.snapshot()
+		if stats.Failures > 0 || stats.Dropped > 0 {
+			failed = append(failed, fmt.Sprintf("%s (%d failed, %d dropped: %s)",
+				s.Name, stats.Failures, stats.Dropped, stats.LastError))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to send to sinks: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// Stats returns the counters of every sink by name.
+func (f *FanOut) Stats() map[string]SinkStats {
+	stats := make(map[string]SinkStats, len(f.sinks))
+	for _, s := range f.sinks {
+		stats[s.Name] = s.snapshot()
+	}
+
+	return stats
+}
+
+// enqueue adds a batch to the queue, dropping the oldest batch when it is
+// full.
+func (s *fanOutSink) enqueue(points []point.Point) {
+	for {
+		select {
+		case s.queue <- points:
+			return
+		default:
+		}
+
+		select {
+		case <-s.queue:
+			s.mu.Lock()
+			s.stats.Dropped++
+			s.mu.Unlock()
+
+			s.logger.Warn("sink queue is full, dropping oldest batch", logging.Fields{
+				"stage":      "send",
+				"queue_size": s.QueueSize,
+			})
+		default:
+		}
+	}
+}
+
+func (s *fanOutSink) run() {
+	for points := range s.queue {
+		s.send(points)
+	}
+}
+
+// send delivers a batch, retrying failed attempts with a doubling backoff.
+func (s *fanOutSink) send(points []point.Point) {
+	backoff := s.Backoff
+	for attempt := 0; ; attempt++ {
+		err := s.attempt(points)
+		if err == nil {
+			s.mu.Lock()
+			s.stats.Batches++
+			s.stats.Points += int64(len(points))
+			s.mu.Unlock()
+
+			s.logger.Debug("sent points to sink", logging.Fields{
+				"stage":  "send",
+				"points": len(points),
+			})
+			return
+		}
+
+		s.mu.Lock()
+		s.stats.LastError = err.Error()
+		if attempt >= s.Retries {
+			s.stats.Failures++
+		} else {
+			s.stats.Retries++
+		}
+		s.mu.Unlock()
+
+		if attempt >= s.Retries {
+			s.logger.Error("failed to send points to sink", logging.Fields{
+				"stage":    "send",
+				"attempts": attempt + 1,
+				"error":    err,
+			})
+			return
+		}
+
+		s.logger.Warn("failed to send points to sink, retrying", logging.Fields{
+			"stage":   "send",
+			"attempt": attempt + 1,
+			"backoff": backoff.String(),
+			"error":   err,
+		})
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// attempt connects, sends and disconnects within the timeout. The clients
+// are not safe for concurrent use, so a timed out attempt either leaves its
+// client behind for a new one or is waited for before the client is used
+// again.
+func (s *fanOutSink) attempt(points []point.Point) error {
+	client := s.Client
+	done := make(chan error, 1)
+	go func() {
+		done <- s.deliver(client, points)
+	}()
+
+	timer := time.NewTimer(s.Timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+	}
+
+	s.mu.Lock()
+	s.stats.Timeouts++
+	s.mu.Unlock()
+
+	if s.NewClient != nil {
+		s.logger.Warn("abandoning timed out sink client", logging.Fields{
+			"stage":   "send",
+			"timeout": s.Timeout.String(),
+		})
+		s.Client = s.NewClient()
+	} else {
+		<-done
+	}
+
+	return fmt.Errorf("sending to %s timed out after %s", s.Name, s.Timeout)
+}
+
+func (s *fanOutSink) deliver(client Client, points []point.Point) error {
+	if err := client.Connect(); err != nil {
+		return err
+	}
+
+	err := client.SendPoints(points)
+
+	if dErr := client.Disconnect(); dErr != nil {
+		s.logger.Warn("failed disconnecting from sink", logging.Fields{
+			"stage": "send",
+			"error": dErr,
+		})
+	}
+
+	return err
+}
+
+func (s *fanOutSink) snapshot() SinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+// FanOutOption is a func that is used to configure optional settings on a
+// FanOut.
+type FanOutOption func(*FanOut)
+
+// WithFanOutLogger returns a FanOutOption for configuring the logger used by
+// the FanOut.
+func WithFanOutLogger(l *logging.Logger) FanOutOption {
+	return func(f *FanOut) {
+		f.logger = l
+	}
+}
@@ -0,0 +1,200 @@
+package reporter_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/reporter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FanOut", func() {
+	var points = []point.Point{
+		{Name: point.Ingress, Value: 1, Timestamp: 1, Identity: point.Identity{App: "app", Index: 0}},
+		{Name: point.Ingress, Value: 2, Timestamp: 1, Identity: point.Identity{App: "app", Index: 1}},
+	}
+
+	It("sends the same points to every sink", func() {
+		a, b := &fakeSink{}, &fakeSink{}
+		f := reporter.NewFanOut([]reporter.Sink{
+			{Name: "a", Client: a},
+			{Name: "b", Client: b},
+		}, reporter.WithFanOutLogger(logging.Discard()))
+
+		Expect(f.SendPoints(points)).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		Expect(a.batches()).To(Equal([][]point.Point{points}))
+		Expect(b.batches()).To(Equal([][]point.Point{points}))
+		Expect(f.Stats()).To(Equal(map[string]reporter.SinkStats{
+			"a": {Batches: 1, Points: 2},
+			"b": {Batches: 1, Points: 2},
+		}))
+	})
+
+	It("does not block the other sinks while one is slow", func() {
+		release := make(chan struct{})
+		slow, fast := &fakeSink{block: release}, &fakeSink{}
+		f := reporter.NewFanOut([]reporter.Sink{
+			{Name: "slow", Client: slow, Timeout: time.Minute},
+			{Name: "fast", Client: fast},
+		}, reporter.WithFanOutLogger(logging.Discard()))
+
+		Expect(f.SendPoints(points)).To(Succeed())
+		Expect(f.SendPoints(points)).To(Succeed())
+
+		Eventually(fast.batches).Should(HaveLen(2))
+		Expect(slow.batches()).To(BeEmpty())
+
+		close(release)
+		Expect(f.Close()).To(Succeed())
+		Expect(slow.batches()).To(HaveLen(2))
+	})
+
+	It("drops the oldest batch when the queue of a sink is full", func() {
+		release := make(chan struct{})
+		slow := &fakeSink{block: release}
+		f := reporter.NewFanOut([]reporter.Sink{
+			{Name: "slow", Client: slow, QueueSize: 1, Timeout: time.Minute},
+		}, reporter.WithFanOutLogger(logging.Discard()))
+
+		first := []point.Point{{Name: "first"}}
+		second := []point.Point{{Name: "second"}}
+		third := []point.Point{{Name: "third"}}
+
+		Expect(f.SendPoints(first)).To(Succeed())
+		Eventually(slow.connects).Should(Equal(1))
+		Expect(f.SendPoints(second)).To(Succeed())
+		Expect(f.SendPoints(third)).To(Succeed())
+
+		close(release)
+		Expect(f.Close()).To(MatchError(ContainSubstring("slow (0 failed, 1 dropped")))
+		Expect(slow.batches()).To(Equal([][]point.Point{first, third}))
+		Expect(f.Stats()["slow"].Dropped).To(Equal(int64(1)))
+	})
+
+	It("retries failed batches with a backoff", func() {
+		flaky := &fakeSink{sendErrs: []error{errors.New("boom"), errors.New("boom")}}
+		f := reporter.NewFanOut([]reporter.Sink{
+			{Name: "flaky", Client: flaky, Retries: 2, Backoff: time.Millisecond},
+		}, reporter.WithFanOutLogger(logging.Discard()))
+
+		Expect(f.SendPoints(points)).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		Expect(flaky.batches()).To(HaveLen(3))
+		stats := f.Stats()["flaky"]
+		Expect(stats.Retries).To(Equal(int64(2)))
+		Expect(stats.Batches).To(Equal(int64(1)))
+		Expect(stats.Failures).To(BeZero())
+	})
+
+	It("counts batches that failed after all retries", func() {
+		broken := &fakeSink{connectErr: errors.New("connection refused")}
+		ok := &fakeSink{}
+		f := reporter.NewFanOut([]reporter.Sink{
+			{Name: "broken", Client: broken, Retries: 1, Backoff: time.Millisecond},
+			{Name: "ok", Client: ok},
+		}, reporter.WithFanOutLogger(logging.Discard()))
+
+		Expect(f.SendPoints(points)).To(Succeed())
+
+		Expect(f.Close()).To(MatchError("failed to send to sinks: broken (1 failed, 0 dropped: connection refused)"))
+		Expect(ok.batches()).To(HaveLen(1))
+		Expect(f.Stats()["broken"]).To(Equal(reporter.SinkStats{
+			Failures:  1,
+			Retries:   1,
+			LastError: "connection refused",
+		}))
+	})
+
+	It("times out attempts that take too long", func() {
+		release := make(chan struct{})
+		slow := &fakeSink{block: release}
+		f := reporter.NewFanOut([]reporter.Sink{
+			{Name: "slow", Client: slow, Timeout: 10 * time.Millisecond},
+		}, reporter.WithFanOutLogger(logging.Discard()))
+
+		Expect(f.SendPoints(points)).To(Succeed())
+		Eventually(func() int64 { return f.Stats()["slow"].Timeouts }).Should(Equal(int64(1)))
+
+		close(release)
+		Expect(f.Close()).To(MatchError(ContainSubstring("sending to slow timed out after 10ms")))
+	})
+
+	It("replaces a client whose attempt timed out instead of waiting for it", func() {
+		hung := &fakeSink{block: make(chan struct{})}
+		fresh := &fakeSink{}
+		f := reporter.NewFanOut([]reporter.Sink{{
+			Name:      "hung",
+			Client:    hung,
+			Timeout:   10 * time.Millisecond,
+			Retries:   1,
+			NewClient: func() reporter.Client { return fresh },
+		}}, reporter.WithFanOutLogger(logging.Discard()))
+
+		Expect(f.SendPoints(points)).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		Expect(hung.batches()).To(BeEmpty())
+		Expect(fresh.batches()).To(Equal([][]point.Point{points}))
+		Expect(f.Stats()["hung"].Timeouts).To(Equal(int64(1)))
+	})
+})
+
+type fakeSink struct {
+	mu         sync.Mutex
+	block      chan struct{}
+	connectErr error
+	sendErrs   []error
+	_connects  int
+	_batches   [][]point.Point
+}
+
+func (f *fakeSink) Connect() error {
+	f.mu.Lock()
+	f._connects++
+	f.mu.Unlock()
+
+	return f.connectErr
+}
+
+func (f *fakeSink) Disconnect() error {
+	return nil
+}
+
+func (f *fakeSink) SendPoints(points []point.Point) error {
+	if f.block != nil {
+		<-f.block
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f._batches = append(f._batches, points)
+	if len(f.sendErrs) > 0 {
+		err := f.sendErrs[0]
+		f.sendErrs = f.sendErrs[1:]
+		return err
+	}
+
+	return nil
+}
+
+func (f *fakeSink) batches() [][]point.Point {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f._batches
+}
+
+func (f *fakeSink) connects() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f._connects
+}
@@ -1,81 +1,197 @@
 package reporter
 
 import (
-	graphite "github.com/marpaia/graphite-golang"
-
-	"log"
+	"errors"
 	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/builder"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
 )
 
-// Reporter stores configuration for reporting to Graphite.
+// Reporter stores configuration for reporting to Graphite or any other
+// sink.
 type GraphiteReporter struct {
-	pointBuilder   PointBuilder
-	graphiteClient GraphiteClient
-	interval       time.Duration
-	metricsPrefix  string
+	pointBuilder  PointBuilder
+	client        Client
+	interval      time.Duration
+	bucketWidth   time.Duration
+	queryLag      time.Duration
+	retryInterval time.Duration
+	processors    []Processor
+	observers     []Observer
+	logger        *logging.Logger
 }
 
 // NewReporter initializes and returns a new Reporter.
-func NewReporter(pointBuilder PointBuilder, graphiteClient GraphiteClient, opts ...ReporterOption) *GraphiteReporter {
+func NewReporter(pointBuilder PointBuilder, client Client, opts ...ReporterOption) *GraphiteReporter {
 
 	r := &GraphiteReporter{
-		pointBuilder:   pointBuilder,
-		graphiteClient: graphiteClient,
-		interval:       time.Minute,
+		pointBuilder: pointBuilder,
+		client:       client,
+		interval:     time.Minute,
+		logger:       logging.Default(),
 	}
 
 	for _, o := range opts {
 		o(r)
 	}
 
+	// Unless configured explicitly the accumulator is assumed to poll on the
+	// report interval, and the rates are queried two buckets behind.
+	if r.bucketWidth == 0 {
+		r.bucketWidth = r.interval
+	}
+	if r.queryLag == 0 {
+		r.queryLag = 2 * r.bucketWidth
+	}
+	if r.retryInterval == 0 {
+		r.retryInterval = r.bucketWidth / 6
+	}
+
+	if r.interval%r.bucketWidth != 0 {
+		r.logger.Warn("report interval is not a multiple of the accumulator interval, some buckets will not be reported", logging.Fields{
+			"interval":             r.interval.String(),
+			"accumulator_interval": r.bucketWidth.String(),
+		})
+	}
+	if r.queryLag < r.bucketWidth {
+		r.logger.Warn("query lag is shorter than the accumulator interval, buckets will be queried before they are complete", logging.Fields{
+			"query_lag":            r.queryLag.String(),
+			"accumulator_interval": r.bucketWidth.String(),
+		})
+	}
+
 	return r
 }
 
-// Run reports metrics from the configured PointBuilder to Graphite on a
-// configured interval.
+// Run reports points from the configured PointBuilder to the Client on a
+// configured interval. Ticks are aligned to the accumulator bucket
+// boundaries.
 func (r *GraphiteReporter) Run() {
 
-	ticker := time.NewTicker(r.interval)
+	next := nextBoundary(time.Now(), r.bucketWidth)
 
-	for timestamp := range ticker.C {
-		func() {
-			log.Printf("graphite reporter ticked at %s", timestamp)
+	for {
+		time.Sleep(time.Until(next))
 
-			points, err := r.getAllPoints()
-			if err != nil {
-				log.Printf("failed to build points from points builder: %s", err)
-				return
-			}
+		_ = r.report(next, next.Add(r.interval))
 
-			err = r.graphiteClient.Connect()
-			defer func() {
-				err = r.graphiteClient.Disconnect()
-				if err != nil {
-					log.Printf("Failed disconnecting from graphite: %s", err)
-				}
-			}()
+		next = next.Add(r.interval)
+		if now := time.Now(); !next.After(now) {
+			r.logger.Warn("report cycle overran the report interval, skipping ticks", logging.Fields{
+				"tick": next.Unix(),
+			})
+			next = nextBoundary(now, r.bucketWidth)
+		}
+	}
 
-			if err != nil {
-				log.Printf("Failed connecting to graphite: %s", err)
-			}
+}
 
-			err = r.graphiteClient.SendMetrics(points)
-			if err != nil {
-				log.Printf("failed to post to graphite: %s", err)
-				return
-			}
+// RunOnce performs a single fetch, lookup, build and send cycle without
+// waiting for a tick. It returns an error if any stage of the cycle failed.
+// Clients and observers delivering in the background, such as a FanOut, are
+// closed so that their errors are included.
+func (r *GraphiteReporter) RunOnce() error {
+	now := time.Now()
 
-		}()
+	err := r.report(now, now.Add(r.interval))
+
+	closers := []interface{}{r.client}
+	for _, o := range r.observers {
+		closers = append(closers, o)
+	}
+	for _, c := range closers {
+		if c, ok := c.(interface{ Close() error }); ok {
+			if closeErr := c.Close(); err == nil {
+				err = closeErr
+			}
+		}
 	}
 
+	return err
 }
 
-func (r *GraphiteReporter) getAllPoints() (points []graphite.Metric, err error) {
-	ts := time.Now().
-		Add(-2 * r.interval).
-		Truncate(r.interval).
-		Unix()
+// report runs one report cycle for the tick at timestamp. If the bucket is
+// not available yet it is requested again until deadline.
+func (r *GraphiteReporter) report(timestamp, deadline time.Time) error {
+	bucket := bucketFor(timestamp, r.queryLag, r.bucketWidth)
+	logger := r.logger.With(logging.Fields{
+		"tick":   timestamp.Unix(),
+		"bucket": bucket,
+	})
+	logger.Debug("reporter ticked")
+
+	points, err := r.getAllPoints(bucket)
+	for errors.Is(err, builder.ErrRateNotAvailable) && time.Now().Add(r.retryInterval).Before(deadline) {
+		logger.Info("requested bucket is not available yet, retrying", logging.Fields{
+			"stage": "fetch",
+			"retry": r.retryInterval.String(),
+		})
+		time.Sleep(r.retryInterval)
 
+		points, err = r.getAllPoints(bucket)
+	}
+	if errors.Is(err, builder.ErrRateNotAvailable) {
+		logger.Error("requested bucket was not available within the report interval, consider increasing the query lag", logging.Fields{
+			"stage":     "fetch",
+			"query_lag": r.queryLag.String(),
+			"error":     err,
+		})
+		return err
+	}
+	if err != nil {
+		logger.Error("failed to build points from points builder", logging.Fields{
+			"stage": "build",
+			"error": err,
+		})
+		return err
+	}
+
+	for _, p := range r.processors {
+		points = p.ProcessPoints(points)
+	}
+	for _, o := range r.observers {
+		o.ObservePoints(points)
+	}
+
+	err = r.client.Connect()
+	if err != nil {
+		logger.Error("failed connecting to sink", logging.Fields{
+			"stage": "send",
+			"error": err,
+		})
+		return err
+	}
+
+	defer func() {
+		err := r.client.Disconnect()
+		if err != nil {
+			logger.Warn("failed disconnecting from sink", logging.Fields{
+				"stage": "send",
+				"error": err,
+			})
+		}
+	}()
+
+	err = r.client.SendPoints(points)
+	if err != nil {
+		logger.Error("failed to send points", logging.Fields{
+			"stage": "send",
+			"error": err,
+		})
+		return err
+	}
+
+	logger.Info("sent points", logging.Fields{
+		"stage":  "send",
+		"points": len(points),
+	})
+
+	return nil
+}
+
+func (r *GraphiteReporter) getAllPoints(ts int64) (points []point.Point, err error) {
 	points, err = r.pointBuilder.BuildPoints(ts)
 	if err != nil {
 		return nil, err
@@ -84,27 +200,101 @@ func (r *GraphiteReporter) getAllPoints() (points []graphite.Metric, err error)
 	return points, nil
 }
 
+// nextBoundary returns the first multiple of width after t.
+func nextBoundary(t time.Time, width time.Duration) time.Time {
+	return t.Truncate(width).Add(width)
+}
+
+// bucketFor returns the start of the accumulator bucket that is queried on
+// the tick at t, as a unix timestamp.
+func bucketFor(t time.Time, lag, width time.Duration) int64 {
+	return t.Add(-lag).Truncate(width).Unix()
+}
+
 // PointBuilder is the interface the GraphiteReporter will use to collect
-// metrics to send to Graphite.
+// the points of an accumulator bucket.
 type PointBuilder interface {
-	BuildPoints(int64) ([]graphite.Metric, error)
+	BuildPoints(int64) ([]point.Point, error)
+}
+
+// Processor is the interface used for deriving further points from the
+// points of every tick, e.g. the deviation from a baseline. The points it
+// returns are observed and sent instead.
+type Processor interface {
+	ProcessPoints([]point.Point) []point.Point
+}
+
+// Observer is the interface used for inspecting the points of every tick
+// before they are sent, e.g. to evaluate alert rules.
+type Observer interface {
+	ObservePoints([]point.Point)
 }
 
 // ReporterOption is a func that is used to configure optional settings on a
 // GraphiteReporter.
 type ReporterOption func(*GraphiteReporter)
 
-// WithInterval returns a ReporterOption for configuring the interval metrics
-// will be reported to Graphite.
+// WithInterval returns a ReporterOption for configuring the interval points
+// will be reported at.
 func WithInterval(d time.Duration) ReporterOption {
 	return func(r *GraphiteReporter) {
 		r.interval = d
 	}
 }
 
-// GraphiteClient is the interface used for sending requests to Graphite.
-type GraphiteClient interface {
-	SendMetrics([]graphite.Metric) error
+// WithBucketWidth returns a ReporterOption for configuring the width of the
+// buckets the accumulator aggregates rates into, i.e. its polling interval.
+// It defaults to the report interval.
+func WithBucketWidth(d time.Duration) ReporterOption {
+	return func(r *GraphiteReporter) {
+		r.bucketWidth = d
+	}
+}
+
+// WithQueryLag returns a ReporterOption for configuring how far behind the
+// tick the queried bucket is. It defaults to two bucket widths.
+func WithQueryLag(d time.Duration) ReporterOption {
+	return func(r *GraphiteReporter) {
+		r.queryLag = d
+	}
+}
+
+// WithRetryInterval returns a ReporterOption for configuring how long to wait
+// before requesting a bucket again that is not available yet. It defaults to
+// a sixth of the bucket width.
+func WithRetryInterval(d time.Duration) ReporterOption {
+	return func(r *GraphiteReporter) {
+		r.retryInterval = d
+	}
+}
+
+// WithProcessor returns a ReporterOption for adding a Processor the points
+// of every tick pass through, in the order they are added.
+func WithProcessor(p Processor) ReporterOption {
+	return func(r *GraphiteReporter) {
+		r.processors = append(r.processors, p)
+	}
+}
+
+// WithObserver returns a ReporterOption for adding an Observer that is
+// handed the points of every tick.
+func WithObserver(o Observer) ReporterOption {
+	return func(r *GraphiteReporter) {
+		r.observers = append(r.observers, o)
+	}
+}
+
+// WithLogger returns a ReporterOption for configuring the logger used by the
+// GraphiteReporter.
+func WithLogger(l *logging.Logger) ReporterOption {
+	return func(r *GraphiteReporter) {
+		r.logger = l
+	}
+}
+
+// Client is the interface used for sending points to a sink.
+type Client interface {
+	SendPoints([]point.Point) error
 	Connect() error
 	Disconnect() error
 }
@@ -1,11 +1,13 @@
 package reporter_test
 
 import (
+	"errors"
 	"sync"
 	"time"
 
-	graphite "github.com/marpaia/graphite-golang"
-
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/builder"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
 	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/reporter"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -14,10 +16,10 @@ import (
 var _ = Describe("GraphiteReporter", func() {
 	It("sends data points to graphite on an interval", func() {
 		pointBuilder := &spyPointBuilder{}
-		graphiteClient := &spyGraphiteClient{}
+		client := &spyClient{}
 
 		reporter := reporter.NewReporter(
-			pointBuilder, graphiteClient,
+			pointBuilder, client,
 			reporter.WithInterval(50*time.Millisecond),
 		)
 		go reporter.Run()
@@ -27,7 +29,137 @@ var _ = Describe("GraphiteReporter", func() {
 			time.Now().Add(-2*(50*time.Millisecond)).Truncate(50*time.Millisecond).Unix(),
 			1,
 		))
-		Eventually(graphiteClient._sendMetricsCount).Should(BeNumerically(">", 1))
+		Eventually(client._sendPointsCount).Should(BeNumerically(">", 1))
+	})
+
+	It("sends data points once without waiting for a tick", func() {
+		pointBuilder := &spyPointBuilder{}
+		client := &spyClient{}
+
+		reporter := reporter.NewReporter(pointBuilder, client)
+
+		Expect(reporter.RunOnce()).To(Succeed())
+		Expect(pointBuilder.buildCalled()).To(Equal(1))
+		Expect(client.sendPointsCount()).To(Equal(1))
+	})
+
+	It("waits for a fan-out to deliver and returns its errors when reporting once", func() {
+		pointBuilder := &spyPointBuilder{}
+		ok := &spyClient{}
+		broken := &spyClient{sendErr: errors.New("boom")}
+		fanOut := reporter.NewFanOut([]reporter.Sink{
+			{Name: "ok", Client: ok},
+			{Name: "broken", Client: broken},
+		}, reporter.WithFanOutLogger(logging.Discard()))
+
+		reporter := reporter.NewReporter(pointBuilder, fanOut)
+
+		Expect(reporter.RunOnce()).To(MatchError(ContainSubstring("broken")))
+		Expect(ok.sendPointsCount()).To(Equal(1))
+		Expect(broken.sendPointsCount()).To(Equal(1))
+	})
+
+	It("queries the bucket the configured lag behind the tick", func() {
+		pointBuilder := &spyPointBuilder{}
+		client := &spyClient{}
+
+		reporter := reporter.NewReporter(pointBuilder, client,
+			reporter.WithInterval(5*time.Minute),
+			reporter.WithBucketWidth(time.Minute),
+			reporter.WithQueryLag(90*time.Second),
+		)
+
+		Expect(reporter.RunOnce()).To(Succeed())
+		Expect(pointBuilder.buildPointsTimestamp()).To(BeNumerically("~",
+			time.Now().Add(-90*time.Second).Truncate(time.Minute).Unix(),
+			60,
+		))
+		Expect(pointBuilder.buildPointsTimestamp() % 60).To(BeZero())
+	})
+
+	It("requests a bucket that is not available yet again within the interval", func() {
+		pointBuilder := &spyPointBuilder{notAvailable: 2}
+		client := &spyClient{}
+
+		reporter := reporter.NewReporter(pointBuilder, client,
+			reporter.WithInterval(time.Second),
+			reporter.WithRetryInterval(10*time.Millisecond),
+		)
+
+		Expect(reporter.RunOnce()).To(Succeed())
+		Expect(pointBuilder.buildCalled()).To(Equal(3))
+		Expect(client.sendPointsCount()).To(Equal(1))
+	})
+
+	It("gives up on a bucket that does not become available within the interval", func() {
+		pointBuilder := &spyPointBuilder{notAvailable: 1000}
+		client := &spyClient{}
+
+		reporter := reporter.NewReporter(pointBuilder, client,
+			reporter.WithInterval(100*time.Millisecond),
+			reporter.WithRetryInterval(10*time.Millisecond),
+		)
+
+		Expect(reporter.RunOnce()).ToNot(Succeed())
+		Expect(pointBuilder.buildCalled()).To(BeNumerically("<", 11))
+		Expect(client.sendPointsCount()).To(Equal(0))
+	})
+
+	It("returns an error when building the points fails", func() {
+		pointBuilder := &spyPointBuilder{err: errors.New("accumulator unavailable")}
+		client := &spyClient{}
+
+		reporter := reporter.NewReporter(pointBuilder, client)
+
+		Expect(reporter.RunOnce()).ToNot(Succeed())
+		Expect(client.sendPointsCount()).To(Equal(0))
+	})
+
+	It("returns an error and does not send when connecting fails", func() {
+		pointBuilder := &spyPointBuilder{}
+		client := &spyClient{connectErr: errors.New("connection refused")}
+
+		reporter := reporter.NewReporter(pointBuilder, client)
+
+		Expect(reporter.RunOnce()).ToNot(Succeed())
+		Expect(client.sendPointsCount()).To(Equal(0))
+	})
+
+	It("hands the points to the observers before sending them", func() {
+		pointBuilder := &spyPointBuilder{}
+		client := &spyClient{sendErr: errors.New("broken pipe")}
+		observer := &spyObserver{}
+
+		reporter := reporter.NewReporter(pointBuilder, client,
+			reporter.WithObserver(observer),
+		)
+
+		Expect(reporter.RunOnce()).ToNot(Succeed())
+		Expect(observer.points).To(HaveLen(2))
+	})
+
+	It("observes the points returned by the processors", func() {
+		pointBuilder := &spyPointBuilder{}
+		client := &spyClient{sendErr: errors.New("broken pipe")}
+		observer := &spyObserver{}
+
+		reporter := reporter.NewReporter(pointBuilder, client,
+			reporter.WithProcessor(appendingProcessor{}),
+			reporter.WithObserver(observer),
+		)
+
+		Expect(reporter.RunOnce()).ToNot(Succeed())
+		Expect(observer.points).To(HaveLen(3))
+		Expect(observer.points[2].Name).To(Equal(point.ZScore))
+	})
+
+	It("returns an error when sending fails", func() {
+		pointBuilder := &spyPointBuilder{}
+		client := &spyClient{sendErr: errors.New("broken pipe")}
+
+		reporter := reporter.NewReporter(pointBuilder, client)
+
+		Expect(reporter.RunOnce()).ToNot(Succeed())
 	})
 })
 
@@ -35,24 +167,34 @@ type spyPointBuilder struct {
 	mu                    sync.Mutex
 	_buildCalled          int
 	_buildPointsTimestamp int64
+	err                   error
+	notAvailable          int
 }
 
-func (s *spyPointBuilder) BuildPoints(timestamp int64) ([]graphite.Metric, error) {
+func (s *spyPointBuilder) BuildPoints(timestamp int64) ([]point.Point, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s._buildCalled++
 	s._buildPointsTimestamp = timestamp
 
-	return []graphite.Metric{
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.notAvailable > 0 {
+		s.notAvailable--
+		return nil, builder.ErrRateNotAvailable
+	}
+
+	return []point.Point{
 		{
 			Name:      "application.ingress",
-			Value:     "1234",
+			Value:     1234,
 			Timestamp: 1257894000,
 		},
 		{
 			Name:      "application.ingress",
-			Value:     "1234",
+			Value:     1234,
 			Timestamp: 1257894000,
 		},
 	}, nil
@@ -82,34 +224,50 @@ func (s *spyReadCloser) Read([]byte) (int, error) {
 	return 0, nil
 }
 
-type spyGraphiteClient struct {
-	mu                sync.Mutex
-	_sendMetricsCount int
-	_url              string
-	_contentType      string
-	_body             string
+type spyClient struct {
+	mu               sync.Mutex
+	_sendPointsCount int
+	_url             string
+	_contentType     string
+	_body            string
+	connectErr       error
+	sendErr          error
 }
 
-func (s *spyGraphiteClient) SendMetrics(points []graphite.Metric) error {
+func (s *spyClient) SendPoints(points []point.Point) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s._sendMetricsCount++
+	s._sendPointsCount++
 
-	return nil
+	return s.sendErr
 }
 
-func (s *spyGraphiteClient) sendMetricsCount() int {
+func (s *spyClient) sendPointsCount() int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return s._sendMetricsCount
+	return s._sendPointsCount
 }
 
-func (s *spyGraphiteClient) Connect() error {
-	return nil
+func (s *spyClient) Connect() error {
+	return s.connectErr
 }
 
-func (s *spyGraphiteClient) Disconnect() error {
+func (s *spyClient) Disconnect() error {
 	return nil
 }
+
+type spyObserver struct {
+	points []point.Point
+}
+
+func (s *spyObserver) ObservePoints(points []point.Point) {
+	s.points = points
+}
+
+type appendingProcessor struct{}
+
+func (appendingProcessor) ProcessPoints(points []point.Point) []point.Point {
+	return append(points, point.Point{Name: point.ZScore, Identity: point.Identity{Index: -1}})
+}
@@ -0,0 +1,13 @@
+package score_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Score Suite")
+}
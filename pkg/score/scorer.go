@@ -0,0 +1,272 @@
+// Package score ranks apps by how much they are likely to hurt their
+// neighbours, rather than by their raw ingress.
+package score
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Entry is the score of an app on an interval.
+type Entry struct {
+	Rank    int    `json:"rank"`
+	Org     string `json:"org"`
+	Space   string `json:"space"`
+	App     string `json:"app"`
+	AppGUID string `json:"app_guid"`
+
+	Score       float64 `json:"score"`
+	Count       float64 `json:"count"`
+	Share       float64 `json:"share"`
+	Persistence float64 `json:"persistence"`
+	Spread      float64 `json:"spread"`
+	Instances   int     `json:"instances"`
+}
+
+// Ranking is the list of the highest scoring apps of an interval.
+type Ranking struct {
+	Timestamp int64   `json:"timestamp"`
+	Apps      []Entry `json:"apps"`
+}
+
+// Scorer adds a score and a rank point per app to the points of every
+// interval and keeps the top of the ranking.
+//
+// The score of an app is
+//
+//	share * (1 + persistence) / 2 * (1 + spread) / 2
+//
+// between 0 and 100, where share is its percentage of the total ingress
+// (see point.Shares), persistence the fraction of the recent intervals its
+// share was at least the noisy share, and spread the normalized entropy of
+// its ingress across its instances, 1 when evenly spread and 0 when a
+// single instance out of many emits everything. An app that is loud now but
+// not usually, or only on one instance, scores at most a quarter of an app
+// that is loud on all instances on every interval. Rank 1 is the highest score.
+type Scorer struct {
+	window     int
+	noisyShare float64
+	topK       int
+
+	mu      sync.Mutex
+	history map[string][]bool
+	ranking Ranking
+}
+
+// NewScorer returns a Scorer.
+func NewScorer(opts ...ScorerOption) *Scorer {
+	s := &Scorer{
+		window:     10,
+		noisyShare: 5,
+		topK:       10,
+		history:    make(map[string][]bool),
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	if s.window < 1 {
+		s.window = 1
+	}
+
+	return s
+}
+
+// app is the ingress of an app on an interval.
+type app struct {
+	entry     Entry
+	identity  point.Identity
+	labels    map[string]string
+	timestamp int64
+	instances map[int]float64
+}
+
+// ProcessPoints returns the points with a score and a rank point for every
+// app with ingress on the interval, and updates the ranking.
+func (s *Scorer) ProcessPoints(points []point.Point) []point.Point {
+	shares := point.Shares(points)
+	apps := make(map[string]*app)
+	for i, p := range points {
+		if p.Name != point.Ingress {
+			continue
+		}
+
+		a, ok := apps[p.Identity.AppGUID]
+		if !ok {
+			identity := p.Identity
+			identity.Index = -1
+			a = &app{
+				entry: Entry{
+					Org:     p.Identity.Org,
+					Space:   p.Identity.Space,
+					App:     p.Identity.App,
+					AppGUID: p.Identity.AppGUID,
+				},
+				identity:  identity,
+				labels:    p.Labels,
+				timestamp: p.Timestamp,
+				instances: make(map[int]float64),
+			}
+			apps[p.Identity.AppGUID] = a
+		}
+		a.entry.Count += p.Value
+		a.instances[p.Identity.Index] += p.Value
+		a.entry.Share += shares[i]
+	}
+	if len(apps) == 0 {
+		return points
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]*app, 0, len(apps))
+	for guid, a := range apps {
+		e := &a.entry
+		e.Persistence = s.remember(guid, e.Share >= s.noisyShare)
+		e.Spread = spread(a.instances, e.Count)
+		e.Instances = len(a.instances)
+		e.Score = e.Share * (1 + e.Persistence) / 2 * (1 + e.Spread) / 2
+
+		entries = append(entries, a)
+	}
+	s.forget(apps)
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].entry.Score != entries[j].entry.Score {
+			return entries[i].entry.Score > entries[j].entry.Score
+		}
+		return entries[i].entry.AppGUID < entries[j].entry.AppGUID
+	})
+
+	out := make([]point.Point, len(points), len(points)+2*len(entries))
+	copy(out, points)
+
+	ranking := Ranking{Timestamp: entries[0].timestamp}
+	for i, a := range entries {
+		a.entry.Rank = i + 1
+		if i < s.topK {
+			ranking.Apps = append(ranking.Apps, a.entry)
+		}
+
+		out = append(out,
+			point.Point{Name: point.Score, Timestamp: a.timestamp, Value: a.entry.Score, Identity: a.identity, Labels: a.labels},
+			point.Point{Name: point.Rank, Timestamp: a.timestamp, Value: float64(a.entry.Rank), Identity: a.identity, Labels: a.labels},
+		)
+	}
+	s.ranking = ranking
+
+	return out
+}
+
+// Ranking returns the top of the ranking of the last interval.
+func (s *Scorer) Ranking() Ranking {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.ranking
+	r.Apps = make([]Entry, len(s.ranking.Apps))
+	copy(r.Apps, s.ranking.Apps)
+
+	return r
+}
+
+// remember records whether an app was noisy on the interval and returns the
+// fraction of the intervals in the window it was. It must be called with
+// the lock held.
+func (s *Scorer) remember(guid string, noisy bool) float64 {
+	h := append(s.history[guid], noisy)
+	if len(h) > s.window {
+		h = h[len(h)-s.window:]
+	}
+	s.history[guid] = h
+
+	var n int
+	for _, v := range h {
+		if v {
+			n++
+		}
+	}
+
+	return float64(n) / float64(s.window)
+}
+
+// forget records the intervals apps without ingress were quiet on and
+// drops the history of apps that were quiet for the whole window. It must
+// be called with the lock held.
+func (s *Scorer) forget(apps map[string]*app) {
+	for guid, h := range s.history {
+		if _, ok := apps[guid]; ok {
+			continue
+		}
+
+		h = append(h, false)
+		if len(h) > s.window {
+			h = h[len(h)-s.window:]
+		}
+
+		quiet := true
+		for _, v := range h {
+			quiet = quiet && !v
+		}
+		if quiet {
+			delete(s.history, guid)
+			continue
+		}
+		s.history[guid] = h
+	}
+}
+
+// spread returns the normalized entropy of the ingress across the
+// instances. A single instance is as spread as the app can be.
+func spread(instances map[int]float64, total float64) float64 {
+	if len(instances) < 2 {
+		return 1
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	var h float64
+	for _, v := range instances {
+		if v > 0 {
+			p := v / total
+			h -= p * math.Log(p)
+		}
+	}
+
+	return h / math.Log(float64(len(instances)))
+}
+
+// ScorerOption is a func that is used to configure optional settings on a
+// Scorer.
+type ScorerOption func(*Scorer)
+
+// WithWindow returns a ScorerOption for configuring the number of intervals
+// the persistence is measured over. It defaults to 10.
+func WithWindow(n int) ScorerOption {
+	return func(s *Scorer) {
+		s.window = n
+	}
+}
+
+// WithNoisyShare returns a ScorerOption for configuring the percentage of
+// the total ingress from which an app counts as noisy on an interval. It
+// defaults to 5.
+func WithNoisyShare(share float64) ScorerOption {
+	return func(s *Scorer) {
+		s.noisyShare = share
+	}
+}
+
+// WithTopK returns a ScorerOption for configuring the number of apps kept in
+// the ranking. It defaults to 10.
+func WithTopK(k int) ScorerOption {
+	return func(s *Scorer) {
+		s.topK = k
+	}
+}
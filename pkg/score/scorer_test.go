@@ -0,0 +1,114 @@
+package score_test
+
+import (
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/score"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scorer", func() {
+	var (
+		steady = point.Identity{Org: "org", OrgGUID: "o", Space: "prod", App: "steady", AppGUID: "s"}
+		bursty = point.Identity{Org: "org", OrgGUID: "o", Space: "prod", App: "bursty", AppGUID: "b"}
+		quiet  = point.Identity{Org: "org", OrgGUID: "o", Space: "prod", App: "quiet", AppGUID: "q"}
+	)
+
+	at := func(ts int64, identity point.Identity, index int, value float64) point.Point {
+		identity.Index = index
+		return point.Point{Name: point.Ingress, Timestamp: ts, Value: value, Identity: identity}
+	}
+
+	of := func(points []point.Point, kind string) map[string]float64 {
+		values := make(map[string]float64)
+		for _, p := range points {
+			if p.Name == kind {
+				values[p.Identity.App] = p.Value
+			}
+		}
+		return values
+	}
+
+	It("emits a score and a rank per app", func() {
+		s := score.NewScorer(score.WithWindow(2))
+
+		in := []point.Point{
+			at(60, steady, 0, 30),
+			at(60, steady, 1, 30),
+			at(60, quiet, 0, 40),
+		}
+		out := s.ProcessPoints(in)
+
+		Expect(out[:3]).To(Equal(in))
+		Expect(out).To(HaveLen(7))
+		Expect(out[3].Name).To(Equal(point.Score))
+		Expect(out[3].Timestamp).To(Equal(int64(60)))
+		Expect(out[3].Identity).To(Equal(point.Identity{Org: "org", OrgGUID: "o", Space: "prod", App: "steady", AppGUID: "s", Index: -1}))
+		Expect(out[3].Value).To(BeNumerically("~", 45, 1e-9))
+		Expect(of(out, point.Score)["quiet"]).To(BeNumerically("~", 30, 1e-9))
+		Expect(of(out, point.Rank)).To(Equal(map[string]float64{"steady": 1, "quiet": 2}))
+	})
+
+	It("ranks apps noisy on every instance and interval above bursts", func() {
+		s := score.NewScorer(score.WithWindow(4), score.WithNoisyShare(20))
+
+		for ts := int64(60); ts < 240; ts += 60 {
+			s.ProcessPoints([]point.Point{
+				at(ts, steady, 0, 20),
+				at(ts, steady, 1, 20),
+				at(ts, quiet, 0, 60),
+			})
+		}
+
+		out := s.ProcessPoints([]point.Point{
+			at(240, steady, 0, 20),
+			at(240, steady, 1, 20),
+			at(240, bursty, 0, 48),
+			at(240, bursty, 1, 2),
+			at(240, quiet, 0, 10),
+		})
+
+		scores := of(out, point.Score)
+		Expect(scores["bursty"]).To(BeNumerically(">", 0))
+		Expect(scores["steady"]).To(BeNumerically(">", scores["bursty"]))
+		Expect(of(out, point.Rank)).To(Equal(map[string]float64{"steady": 1, "bursty": 2, "quiet": 3}))
+
+		ranking := s.Ranking()
+		Expect(ranking.Timestamp).To(Equal(int64(240)))
+		Expect(ranking.Apps).To(HaveLen(3))
+		Expect(ranking.Apps[0].App).To(Equal("steady"))
+		Expect(ranking.Apps[0].Persistence).To(Equal(1.0))
+		Expect(ranking.Apps[0].Spread).To(BeNumerically("~", 1))
+		Expect(ranking.Apps[0].Instances).To(Equal(2))
+		Expect(ranking.Apps[1].Persistence).To(Equal(0.25))
+		Expect(ranking.Apps[1].Spread).To(BeNumerically("<", 0.5))
+		Expect(ranking.Apps[2].Persistence).To(Equal(0.75))
+	})
+
+	It("keeps the top of the ranking", func() {
+		s := score.NewScorer(score.WithTopK(1))
+
+		out := s.ProcessPoints([]point.Point{at(60, steady, 0, 10), at(60, quiet, 0, 1)})
+
+		Expect(of(out, point.Rank)).To(HaveLen(2))
+		ranking := s.Ranking()
+		Expect(ranking.Apps).To(HaveLen(1))
+
+		e := ranking.Apps[0]
+		Expect(e.Rank).To(Equal(1))
+		Expect([]string{e.Org, e.Space, e.App, e.AppGUID}).To(Equal([]string{"org", "prod", "steady", "s"}))
+		Expect(e.Count).To(Equal(10.0))
+		Expect(e.Share).To(BeNumerically("~", 1000.0/11, 1e-9))
+		Expect(e.Persistence).To(Equal(0.1))
+		Expect(e.Spread).To(Equal(1.0))
+		Expect(e.Instances).To(Equal(1))
+		Expect(e.Score).To(BeNumerically("~", 100*10.0/11*0.55, 1e-9))
+	})
+
+	It("passes intervals without ingress through", func() {
+		s := score.NewScorer()
+
+		Expect(s.ProcessPoints(nil)).To(BeEmpty())
+		Expect(s.Ranking().Apps).To(BeEmpty())
+	})
+})
@@ -0,0 +1,262 @@
+package datadog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// DefaultURL is the series endpoint of the US Datadog site.
+const DefaultURL = "https://api.datadoghq.com/api/v1/series"
+
+// Client posts points as Datadog gauge series. Every series is tagged with
+// the org, space, app, app GUID and instance it belongs to. Series are sent
+// in gzipped batches and requests failing with a 5xx status are retried.
+type Client struct {
+	url        string
+	apiKey     string
+	httpClient HTTPClient
+	metricName string
+	host       string
+	batchSize  int
+	maxRetries int
+	backoff    time.Duration
+	logger     *logging.Logger
+}
+
+// NewClient returns a Client posting to the series endpoint at url with the
+// given API key.
+func NewClient(url, apiKey string, opts ...ClientOption) *Client {
+	c := &Client{
+		url:        url,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		metricName: "application.ingress",
+		batchSize:  1000,
+		maxRetries: 3,
+		backoff:    time.Second,
+		logger:     logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	if c.batchSize < 1 {
+		c.batchSize = 1
+	}
+
+	return c
+}
+
+// Connect is a no-op, every batch is sent with its own request.
+func (c *Client) Connect() error {
+	return nil
+}
+
+// Disconnect is a no-op.
+func (c *Client) Disconnect() error {
+	return nil
+}
+
+// SendPoints posts the points in batches of the configured size.
+func (c *Client) SendPoints(points []point.Point) error {
+	series := make([]Series, 0, len(points))
+	for _, p := range points {
+		series = append(series, c.series(p))
+	}
+
+	for start := 0; start < len(series); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(series) {
+			end = len(series)
+		}
+
+		if err := c.post(series[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) series(p point.Point) Series {
+	tags := []string{
+		tag("org", p.Identity.Org),
+		tag("space", p.Identity.Space),
+		tag("app", p.Identity.App),
+		tag("app_guid", p.Identity.AppGUID),
+	}
+	if !p.Identity.AppLevel() {
+		tags = append(tags, tag("instance", strconv.Itoa(p.Identity.Index)))
+	}
+	for _, k := range encoding.SortedKeys(p.Labels) {
+		tags = append(tags, tag(k, p.Labels[k]))
+	}
+
+	return Series{
+		Metric: encoding.MetricName(c.metricName, ".", p.Name),
+		Points: [][2]float64{{float64(p.Timestamp), p.Value}},
+		Type:   "gauge",
+		Host:   c.host,
+		Tags:   tags,
+	}
+}
+
+func (c *Client) post(series []Series) error {
+	body, err := encode(series)
+	if err != nil {
+		return err
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.postOnce(body)
+		if err == nil || !retry || attempt >= c.maxRetries {
+			return err
+		}
+
+		c.logger.Warn("failed to post to datadog, retrying", logging.Fields{
+			"stage":   "send",
+			"attempt": attempt + 1,
+			"error":   err,
+		})
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postOnce sends a single request and reports whether it is worth retrying
+// when it failed.
+func (c *Client) postOnce(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("DD-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("expected successful status code from datadog, got %d: %s", resp.StatusCode, respBody)
+
+		return resp.StatusCode >= 500, err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	return false, nil
+}
+
+func encode(series []Series) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+
+	err := json.NewEncoder(gz).Encode(map[string][]Series{"series": series})
+	if err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// tag renders a Datadog tag. Tags are lower-cased by Datadog and may not
+// contain commas or whitespace.
+func tag(key, value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r == ',' || r == ' ' || r == '\t' || r == '\n' {
+			return '_'
+		}
+		return r
+	}, value)
+
+	return key + ":" + value
+}
+
+// Series represents a single metric in a series request.
+type Series struct {
+	Metric string       `json:"metric"`
+	Points [][2]float64 `json:"points"`
+	Type   string       `json:"type"`
+	Host   string       `json:"host,omitempty"`
+	Tags   []string     `json:"tags"`
+}
+
+// HTTPClient is the interface used for sending requests to Datadog.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// ClientOption is a func that is used to configure optional settings on a
+// Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient returns a ClientOption for configuring the HTTPClient used
+// to post series.
+func WithHTTPClient(h HTTPClient) ClientOption {
+	return func(c *Client) {
+		c.httpClient = h
+	}
+}
+
+// WithMetricName returns a ClientOption for configuring the name of the
+// series.
+func WithMetricName(name string) ClientOption {
+	return func(c *Client) {
+		c.metricName = name
+	}
+}
+
+// WithHost returns a ClientOption for configuring the host reported with
+// every series.
+func WithHost(host string) ClientOption {
+	return func(c *Client) {
+		c.host = host
+	}
+}
+
+// WithBatchSize returns a ClientOption for configuring the maximum number of
+// series per request.
+func WithBatchSize(n int) ClientOption {
+	return func(c *Client) {
+		c.batchSize = n
+	}
+}
+
+// WithRetries returns a ClientOption for configuring how often a request
+// failing with a 5xx status is retried, and the backoff before the first
+// retry. The backoff doubles with every retry.
+func WithRetries(n int, backoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = n
+		c.backoff = backoff
+	}
+}
+
+// WithLogger returns a ClientOption for configuring the logger used by the
+// Client.
+func WithLogger(l *logging.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
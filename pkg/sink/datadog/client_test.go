@@ -0,0 +1,171 @@
+package datadog_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/datadog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		api    *fakeDatadog
+		server *httptest.Server
+		points []point.Point
+	)
+
+	BeforeEach(func() {
+		api = &fakeDatadog{}
+		server = httptest.NewServer(api)
+
+		points = []point.Point{
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259517,
+				Value:     2,
+				Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: 0},
+			},
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259517,
+				Value:     3,
+				Identity:  point.Identity{Org: "org2", Space: "space 2", App: "app2", AppGUID: "b", Index: 1},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("posts gzipped series tagged with the app instance", func() {
+		client := datadog.NewClient(server.URL+"/api/v1/series", "secret",
+			datadog.WithHost("reporter-host"),
+			datadog.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(api.requests()).To(HaveLen(1))
+		req := api.requests()[0]
+		Expect(req.path).To(Equal("/api/v1/series"))
+		Expect(req.apiKey).To(Equal("secret"))
+		Expect(req.series).To(Equal([]datadog.Series{
+			{
+				Metric: "application.ingress",
+				Points: [][2]float64{{1520259517, 2}},
+				Type:   "gauge",
+				Host:   "reporter-host",
+				Tags:   []string{"org:org1", "space:space1", "app:app1", "app_guid:a", "instance:0"},
+			},
+			{
+				Metric: "application.ingress",
+				Points: [][2]float64{{1520259517, 3}},
+				Type:   "gauge",
+				Host:   "reporter-host",
+				Tags:   []string{"org:org2", "space:space_2", "app:app2", "app_guid:b", "instance:1"},
+			},
+		}))
+	})
+
+	It("sends series in batches", func() {
+		client := datadog.NewClient(server.URL, "secret",
+			datadog.WithBatchSize(1),
+			datadog.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(api.requests()).To(HaveLen(2))
+	})
+
+	It("retries requests failing with a 5xx status", func() {
+		api.statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+		client := datadog.NewClient(server.URL, "secret",
+			datadog.WithRetries(3, time.Millisecond),
+			datadog.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(api.requests()).To(HaveLen(3))
+	})
+
+	It("gives up after the configured number of retries", func() {
+		api.statuses = []int{500, 500, 500}
+		client := datadog.NewClient(server.URL, "secret",
+			datadog.WithRetries(1, time.Millisecond),
+			datadog.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).ToNot(Succeed())
+
+		Expect(api.requests()).To(HaveLen(2))
+	})
+
+	It("does not retry requests failing with a 4xx status", func() {
+		api.statuses = []int{http.StatusForbidden}
+		client := datadog.NewClient(server.URL, "invalid",
+			datadog.WithRetries(3, time.Millisecond),
+			datadog.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).ToNot(Succeed())
+
+		Expect(api.requests()).To(HaveLen(1))
+	})
+})
+
+type request struct {
+	path   string
+	apiKey string
+	series []datadog.Series
+}
+
+type fakeDatadog struct {
+	mu        sync.Mutex
+	statuses  []int
+	_requests []request
+}
+
+func (f *fakeDatadog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+
+	Expect(r.Header.Get("Content-Encoding")).To(Equal("gzip"))
+	gz, err := gzip.NewReader(r.Body)
+	Expect(err).ToNot(HaveOccurred())
+
+	var body map[string][]datadog.Series
+	Expect(json.NewDecoder(gz).Decode(&body)).To(Succeed())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f._requests = append(f._requests, request{
+		path:   r.URL.Path,
+		apiKey: r.Header.Get("DD-API-KEY"),
+		series: body["series"],
+	})
+
+	if len(f.statuses) > 0 {
+		w.WriteHeader(f.statuses[0])
+		f.statuses = f.statuses[1:]
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (f *fakeDatadog) requests() []request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f._requests
+}
@@ -0,0 +1,316 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Client indexes one document per app instance and interval into
+// Elasticsearch or OpenSearch using the _bulk API.
+//
+// Documents are written into indices named after the prefix and the UTC date
+// of their interval, e.g. noisy-neighbor-2018.03.05. Their IDs are derived
+// from the app instance and the interval, so that sending an interval again
+// overwrites its documents instead of duplicating them.
+type Client struct {
+	url         string
+	httpClient  HTTPClient
+	indexPrefix string
+	dateFormat  string
+	foundation  string
+	batchSize   int
+	username    string
+	password    string
+	apiKey      string
+	logger      *logging.Logger
+}
+
+// NewClient returns a Client indexing into the cluster at url.
+func NewClient(url string, opts ...ClientOption) *Client {
+	c := &Client{
+		url:         strings.TrimRight(url, "/"),
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		indexPrefix: "noisy-neighbor-",
+		dateFormat:  "2006.01.02",
+		batchSize:   500,
+		logger:      logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	if c.batchSize < 1 {
+		c.batchSize = 1
+	}
+
+	return c
+}
+
+// Connect is a no-op, every batch is sent with its own request.
+func (c *Client) Connect() error {
+	return nil
+}
+
+// Disconnect is a no-op.
+func (c *Client) Disconnect() error {
+	return nil
+}
+
+// SendPoints indexes one document per ingress point, in bulk requests of
+// the configured size. Other kinds of points are not indexed, documents
+// carry the share of the ingress instead.
+func (c *Client) SendPoints(points []point.Point) error {
+	shares := point.Shares(points)
+
+	docs := make([]Document, 0, len(points))
+	for i, p := range points {
+		if p.Name != point.Ingress {
+			continue
+		}
+		d := c.document(p)
+		d.Share = shares[i]
+		docs = append(docs, d)
+	}
+
+	for start := 0; start < len(docs); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		if err := c.bulk(docs[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) document(p point.Point) Document {
+	return Document{
+		Timestamp:     time.Unix(p.Timestamp, 0).UTC(),
+		Foundation:    c.foundation,
+		Org:           p.Identity.Org,
+		Space:         p.Identity.Space,
+		App:           p.Identity.App,
+		AppGUID:       p.Identity.AppGUID,
+		InstanceIndex: p.Identity.Index,
+		Count:         int64(p.Value),
+	}
+}
+
+func (c *Client) bulk(docs []Document) error {
+	body := &bytes.Buffer{}
+	enc := json.NewEncoder(body)
+	for _, d := range docs {
+		action := map[string]bulkAction{"index": {
+			Index: c.indexPrefix + d.Timestamp.Format(c.dateFormat),
+			ID:    fmt.Sprintf("%s-%d-%d", d.AppGUID, d.InstanceIndex, d.Timestamp.Unix()),
+		}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url+"/_bulk", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	switch {
+	case c.apiKey != "":
+		req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(respBody) > 1024 {
+			respBody = respBody[:1024]
+		}
+		return fmt.Errorf("expected successful status code from elasticsearch, got %d: %s", resp.StatusCode, respBody)
+	}
+
+	if len(respBody) == 0 {
+		return nil
+	}
+
+	var result BulkResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %s", err)
+	}
+
+	return c.checkResult(result, len(docs))
+}
+
+// checkResult reports the items of a bulk request that failed. A bulk
+// request succeeds as a whole even when some of its items are rejected,
+// e.g. because of a mapping conflict.
+func (c *Client) checkResult(result BulkResponse, sent int) error {
+	if !result.Errors {
+		return nil
+	}
+
+	var failed []BulkItem
+	for _, item := range result.Items {
+		for _, i := range item {
+			if i.Error != nil {
+				failed = append(failed, i)
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	for _, i := range failed {
+		c.logger.Debug("elasticsearch rejected document", logging.Fields{
+			"stage":  "send",
+			"index":  i.Index,
+			"id":     i.ID,
+			"status": i.Status,
+			"error":  i.Error.Type + ": " + i.Error.Reason,
+		})
+	}
+
+	return fmt.Errorf("elasticsearch rejected %d of %d documents: %s: %s",
+		len(failed), sent, failed[0].Error.Type, failed[0].Error.Reason)
+}
+
+// Document describes the ingress of an app instance during an interval.
+// Share is the percentage of the total ingress of the foundation during
+// that interval, see point.Shares.
+type Document struct {
+	Timestamp     time.Time `json:"@timestamp"`
+	Foundation    string    `json:"foundation,omitempty"`
+	Org           string    `json:"org"`
+	Space         string    `json:"space"`
+	App           string    `json:"app"`
+	AppGUID       string    `json:"app_guid"`
+	InstanceIndex int       `json:"instance_index"`
+	Count         int64     `json:"count"`
+	Share         float64   `json:"share"`
+}
+
+type bulkAction struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+// BulkResponse is the response of the _bulk API.
+type BulkResponse struct {
+	Errors bool                  `json:"errors"`
+	Items  []map[string]BulkItem `json:"items"`
+}
+
+// BulkItem is the result of a single action of a bulk request.
+type BulkItem struct {
+	Index  string     `json:"_index"`
+	ID     string     `json:"_id"`
+	Status int        `json:"status"`
+	Error  *BulkError `json:"error,omitempty"`
+}
+
+// BulkError describes why an action failed.
+type BulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// HTTPClient is the interface used for sending requests to Elasticsearch.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// ClientOption is a func that is used to configure optional settings on a
+// Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient returns a ClientOption for configuring the HTTPClient used
+// to send bulk requests.
+func WithHTTPClient(h HTTPClient) ClientOption {
+	return func(c *Client) {
+		c.httpClient = h
+	}
+}
+
+// WithIndexPrefix returns a ClientOption for configuring the prefix of the
+// index names.
+func WithIndexPrefix(prefix string) ClientOption {
+	return func(c *Client) {
+		c.indexPrefix = prefix
+	}
+}
+
+// WithIndexDateFormat returns a ClientOption for configuring the layout,
+// as understood by time.Format, of the date suffix of the index names.
+func WithIndexDateFormat(layout string) ClientOption {
+	return func(c *Client) {
+		c.dateFormat = layout
+	}
+}
+
+// WithFoundation returns a ClientOption for configuring the foundation
+// recorded in every document.
+func WithFoundation(foundation string) ClientOption {
+	return func(c *Client) {
+		c.foundation = foundation
+	}
+}
+
+// WithBatchSize returns a ClientOption for configuring the maximum number of
+// documents per bulk request.
+func WithBatchSize(n int) ClientOption {
+	return func(c *Client) {
+		c.batchSize = n
+	}
+}
+
+// WithBasicAuth returns a ClientOption for authenticating with basic auth.
+func WithBasicAuth(username, password string) ClientOption {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithAPIKey returns a ClientOption for authenticating with a base64 encoded
+// API key. It takes precedence over basic auth.
+func WithAPIKey(key string) ClientOption {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithLogger returns a ClientOption for configuring the logger used by the
+// Client.
+func WithLogger(l *logging.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
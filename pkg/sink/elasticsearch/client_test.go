@@ -0,0 +1,184 @@
+package elasticsearch_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/elasticsearch"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		cluster *fakeCluster
+		server  *httptest.Server
+		points  []point.Point
+	)
+
+	BeforeEach(func() {
+		cluster = &fakeCluster{}
+		server = httptest.NewServer(cluster)
+
+		points = []point.Point{
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     1,
+				Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: 0},
+			},
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     3,
+				Identity:  point.Identity{Org: "org2", Space: "space2", App: "app2", AppGUID: "b", Index: 1},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("indexes one document per app instance into a date-suffixed index", func() {
+		client := elasticsearch.NewClient(server.URL+"/",
+			elasticsearch.WithFoundation("eu"),
+			elasticsearch.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(cluster.requests()).To(HaveLen(1))
+		req := cluster.requests()[0]
+		Expect(req.path).To(Equal("/_bulk"))
+		Expect(req.contentType).To(Equal("application/x-ndjson"))
+		Expect(req.lines).To(HaveLen(4))
+
+		Expect(req.lines[0]).To(MatchJSON(`{"index":{"_index":"noisy-neighbor-2018.03.05","_id":"a-0-1520259480"}}`))
+		Expect(req.lines[1]).To(MatchJSON(`{
+			"@timestamp": "2018-03-05T14:18:00Z",
+			"foundation": "eu",
+			"org": "org1",
+			"space": "space1",
+			"app": "app1",
+			"app_guid": "a",
+			"instance_index": 0,
+			"count": 1,
+			"share": 25
+		}`))
+		Expect(req.lines[2]).To(MatchJSON(`{"index":{"_index":"noisy-neighbor-2018.03.05","_id":"b-1-1520259480"}}`))
+		Expect(req.lines[3]).To(ContainSubstring(`"share":75`))
+	})
+
+	It("uses the configured index prefix and date format", func() {
+		client := elasticsearch.NewClient(server.URL,
+			elasticsearch.WithIndexPrefix("nn-"),
+			elasticsearch.WithIndexDateFormat("2006-01"),
+			elasticsearch.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points[:1])).To(Succeed())
+
+		Expect(cluster.requests()[0].lines[0]).To(ContainSubstring(`"_index":"nn-2018-03"`))
+	})
+
+	It("sends documents in batches", func() {
+		client := elasticsearch.NewClient(server.URL,
+			elasticsearch.WithBatchSize(1),
+			elasticsearch.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(cluster.requests()).To(HaveLen(2))
+	})
+
+	It("authenticates with an API key", func() {
+		client := elasticsearch.NewClient(server.URL,
+			elasticsearch.WithBasicAuth("user", "pass"),
+			elasticsearch.WithAPIKey("key"),
+			elasticsearch.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(cluster.requests()[0].authorization).To(Equal("ApiKey key"))
+	})
+
+	It("returns the errors of rejected items", func() {
+		cluster.response = `{"errors":true,"items":[
+			{"index":{"_index":"noisy-neighbor-2018.03.05","_id":"a-0-1520259480","status":201}},
+			{"index":{"_index":"noisy-neighbor-2018.03.05","_id":"b-1-1520259480","status":400,
+				"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [count]"}}}
+		]}`
+		client := elasticsearch.NewClient(server.URL, elasticsearch.WithLogger(logging.Discard()))
+
+		err := client.SendPoints(points)
+
+		Expect(err).To(MatchError("elasticsearch rejected 1 of 2 documents: mapper_parsing_exception: failed to parse field [count]"))
+	})
+
+	It("returns an error when the bulk request fails", func() {
+		cluster.status = http.StatusUnauthorized
+		client := elasticsearch.NewClient(server.URL, elasticsearch.WithLogger(logging.Discard()))
+
+		Expect(client.SendPoints(points)).ToNot(Succeed())
+	})
+})
+
+type request struct {
+	path          string
+	contentType   string
+	authorization string
+	lines         []string
+}
+
+type fakeCluster struct {
+	mu        sync.Mutex
+	status    int
+	response  string
+	_requests []request
+}
+
+func (f *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+
+	var lines []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		Expect(json.Valid(scanner.Bytes())).To(BeTrue())
+		lines = append(lines, scanner.Text())
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f._requests = append(f._requests, request{
+		path:          r.URL.Path,
+		contentType:   r.Header.Get("Content-Type"),
+		authorization: r.Header.Get("Authorization"),
+		lines:         lines,
+	})
+
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	if f.response != "" {
+		w.Write([]byte(f.response))
+		return
+	}
+	w.Write([]byte(`{"errors":false,"items":[]}`))
+}
+
+func (f *fakeCluster) requests() []request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f._requests
+}
@@ -0,0 +1,390 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Format is the encoding of the records written to the file.
+type Format int
+
+// Supported formats.
+const (
+	JSONLinesFormat Format = iota
+	CSVFormat
+)
+
+// csvHeader is the first line of every CSV file.
+var csvHeader = []string{"timestamp", "org", "space", "app", "app_guid", "index", "count"}
+
+// Client writes one record per point to a local file.
+//
+// The file is rotated once it exceeds the maximum size or has been written
+// to for longer than the rotation interval. Rotated files are renamed with
+// the time of their rotation, e.g. points-20180305T141800.000.jsonl,
+// optionally gzipped, and only the configured number of them is kept.
+type Client struct {
+	path           string
+	format         Format
+	maxSize        int64
+	rotateInterval time.Duration
+	compress       bool
+	maxBackups     int
+	now            func() time.Time
+	output         io.Writer
+	logger         *logging.Logger
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewClient returns a Client writing to the file at path.
+func NewClient(path string, opts ...ClientOption) *Client {
+	c := &Client{
+		path:    path,
+		maxSize: 100 << 20,
+		now:     time.Now,
+		logger:  logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// Connect opens the file, creating it if needed.
+func (c *Client) Connect() error {
+	if c.file != nil || c.output != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	c.file = f
+	c.size = info.Size()
+	if c.openedAt.IsZero() {
+		c.openedAt = c.now()
+	}
+
+	return nil
+}
+
+// Disconnect closes the file.
+func (c *Client) Disconnect() error {
+	if c.file == nil {
+		return nil
+	}
+
+	err := c.file.Close()
+	c.file = nil
+
+	return err
+}
+
+// SendPoints appends one record per ingress point to the file, rotating it
+// first when it is due. Records only hold counts, other kinds of points are
+// not written.
+func (c *Client) SendPoints(points []point.Point) error {
+	points = point.Only(points, point.Ingress)
+
+	var out io.Writer = c.output
+	if out == nil {
+		if c.file == nil {
+			return fmt.Errorf("file %s is not open", c.path)
+		}
+
+		if c.rotationDue() {
+			if err := c.rotate(); err != nil {
+				return err
+			}
+		}
+		out = c.file
+	}
+
+	w := &countingWriter{w: out}
+	buf := bufio.NewWriter(w)
+
+	var err error
+	switch c.format {
+	case CSVFormat:
+		err = c.writeCSV(buf, points)
+	default:
+		err = c.writeJSONLines(buf, points)
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	c.size += w.n
+
+	return err
+}
+
+func (c *Client) writeJSONLines(w io.Writer, points []point.Point) error {
+	enc := json.NewEncoder(w)
+	for _, p := range points {
+		if err := enc.Encode(record(p)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) writeCSV(w io.Writer, points []point.Point) error {
+	cw := csv.NewWriter(w)
+	if c.size == 0 {
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range points {
+		r := record(p)
+		err := cw.Write([]string{
+			r.Timestamp.Format(time.RFC3339),
+			r.Org,
+			r.Space,
+			r.App,
+			r.AppGUID,
+			strconv.Itoa(r.Index),
+			strconv.FormatInt(r.Count, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func record(p point.Point) Record {
+	return Record{
+		Timestamp: time.Unix(p.Timestamp, 0).UTC(),
+		Org:       p.Identity.Org,
+		Space:     p.Identity.Space,
+		App:       p.Identity.App,
+		AppGUID:   p.Identity.AppGUID,
+		Index:     p.Identity.Index,
+		Count:     int64(p.Value),
+	}
+}
+
+func (c *Client) rotationDue() bool {
+	if c.size == 0 {
+		return false
+	}
+	if c.maxSize > 0 && c.size >= c.maxSize {
+		return true
+	}
+
+	return c.rotateInterval > 0 && c.now().Sub(c.openedAt) >= c.rotateInterval
+}
+
+// rotate moves the current file aside, compresses it if configured, removes
+// rotated files beyond the retention and opens a new file.
+func (c *Client) rotate() error {
+	if err := c.Disconnect(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(c.path)
+	rotated := fmt.Sprintf("%s-%s%s",
+		strings.TrimSuffix(c.path, ext),
+		c.now().UTC().Format("20060102T150405.000"),
+		ext,
+	)
+	if err := os.Rename(c.path, rotated); err != nil {
+		return err
+	}
+
+	if c.compress {
+		if err := gzipFile(rotated); err != nil {
+			c.logger.Warn("failed to compress rotated file", logging.Fields{
+				"stage": "send",
+				"file":  rotated,
+				"error": err,
+			})
+		}
+	}
+
+	if c.maxBackups > 0 {
+		c.removeOldBackups()
+	}
+
+	c.openedAt = time.Time{}
+
+	return c.Connect()
+}
+
+// removeOldBackups removes the oldest rotated files beyond the retention.
+// Their names sort by rotation time.
+func (c *Client) removeOldBackups() {
+	ext := filepath.Ext(c.path)
+	pattern := strings.TrimSuffix(c.path, ext) + "-*" + ext + "*"
+
+	backups, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+	sort.Strings(backups)
+
+	for len(backups) > c.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			c.logger.Warn("failed to remove rotated file", logging.Fields{
+				"stage": "send",
+				"file":  backups[0],
+				"error": err,
+			})
+		}
+		backups = backups[1:]
+	}
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// Record is a single point as written to the file.
+type Record struct {
+	Timestamp time.Time `json:"timestamp"`
+	Org       string    `json:"org"`
+	Space     string    `json:"space"`
+	App       string    `json:"app"`
+	AppGUID   string    `json:"app_guid"`
+	Index     int       `json:"index"`
+	Count     int64     `json:"count"`
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// ClientOption is a func that is used to configure optional settings on a
+// Client.
+type ClientOption func(*Client)
+
+// WithFormat returns a ClientOption for configuring the format of the
+// records. It defaults to JSON Lines.
+func WithFormat(f Format) ClientOption {
+	return func(c *Client) {
+		c.format = f
+	}
+}
+
+// WithMaxSize returns a ClientOption for configuring the size in bytes after
+// which the file is rotated. Zero disables size based rotation. It defaults
+// to 100MiB.
+func WithMaxSize(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxSize = n
+	}
+}
+
+// WithRotateInterval returns a ClientOption for configuring how long a file
+// is written to before it is rotated. Zero, the default, disables time based
+// rotation.
+func WithRotateInterval(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.rotateInterval = d
+	}
+}
+
+// WithCompress returns a ClientOption for gzipping rotated files.
+func WithCompress(compress bool) ClientOption {
+	return func(c *Client) {
+		c.compress = compress
+	}
+}
+
+// WithMaxBackups returns a ClientOption for configuring how many rotated
+// files are kept. Zero, the default, keeps all of them.
+func WithMaxBackups(n int) ClientOption {
+	return func(c *Client) {
+		c.maxBackups = n
+	}
+}
+
+// WithClock returns a ClientOption for configuring the source of the
+// current time, which drives time based rotation and the names of rotated
+// files.
+func WithClock(now func() time.Time) ClientOption {
+	return func(c *Client) {
+		c.now = now
+	}
+}
+
+// WithOutput returns a ClientOption for writing the records to w instead of
+// the file. Nothing is rotated and a CSV header is only written once.
+func WithOutput(w io.Writer) ClientOption {
+	return func(c *Client) {
+		c.output = w
+	}
+}
+
+// WithLogger returns a ClientOption for configuring the logger used by the
+// Client.
+func WithLogger(l *logging.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
@@ -0,0 +1,190 @@
+package file_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/file"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		dir    string
+		path   string
+		now    time.Time
+		clock  func() time.Time
+		points []point.Point
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "file-sink")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "points.jsonl")
+
+		now = time.Date(2018, 3, 5, 14, 20, 0, 0, time.UTC)
+		clock = func() time.Time { return now }
+
+		points = []point.Point{
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     2,
+				Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: 0},
+			},
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     3,
+				Identity:  point.Identity{Org: "org2", Space: "space,2", App: "app2", AppGUID: "b", Index: 1},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	send := func(c *file.Client, points []point.Point) {
+		Expect(c.Connect()).To(Succeed())
+		Expect(c.SendPoints(points)).To(Succeed())
+		Expect(c.Disconnect()).To(Succeed())
+	}
+
+	It("appends JSON Lines records", func() {
+		client := file.NewClient(path, file.WithLogger(logging.Discard()))
+
+		send(client, points)
+		send(client, points[:1])
+
+		Expect(readFile(path)).To(Equal(
+			`{"timestamp":"2018-03-05T14:18:00Z","org":"org1","space":"space1","app":"app1","app_guid":"a","index":0,"count":2}` + "\n" +
+				`{"timestamp":"2018-03-05T14:18:00Z","org":"org2","space":"space,2","app":"app2","app_guid":"b","index":1,"count":3}` + "\n" +
+				`{"timestamp":"2018-03-05T14:18:00Z","org":"org1","space":"space1","app":"app1","app_guid":"a","index":0,"count":2}` + "\n",
+		))
+	})
+
+	It("writes CSV records with a header", func() {
+		path = filepath.Join(dir, "points.csv")
+		client := file.NewClient(path,
+			file.WithFormat(file.CSVFormat),
+			file.WithLogger(logging.Discard()),
+		)
+
+		send(client, points)
+		send(client, points[:1])
+
+		Expect(readFile(path)).To(Equal(
+			"timestamp,org,space,app,app_guid,index,count\n" +
+				"2018-03-05T14:18:00Z,org1,space1,app1,a,0,2\n" +
+				"2018-03-05T14:18:00Z,org2,\"space,2\",app2,b,1,3\n" +
+				"2018-03-05T14:18:00Z,org1,space1,app1,a,0,2\n",
+		))
+	})
+
+	It("rotates the file once it exceeds the maximum size", func() {
+		client := file.NewClient(path,
+			file.WithMaxSize(10),
+			file.WithClock(clock),
+			file.WithLogger(logging.Discard()),
+		)
+
+		send(client, points[:1])
+		send(client, points[1:2])
+
+		rotated := filepath.Join(dir, "points-20180305T142000.000.jsonl")
+		Expect(readFile(rotated)).To(ContainSubstring(`"app":"app1"`))
+		Expect(readFile(path)).To(ContainSubstring(`"app":"app2"`))
+		Expect(readFile(path)).ToNot(ContainSubstring(`"app":"app1"`))
+	})
+
+	It("rotates the file after the rotation interval", func() {
+		client := file.NewClient(path,
+			file.WithRotateInterval(time.Hour),
+			file.WithClock(clock),
+			file.WithLogger(logging.Discard()),
+		)
+
+		send(client, points[:1])
+		now = now.Add(30 * time.Minute)
+		send(client, points[:1])
+		Expect(filepath.Glob(filepath.Join(dir, "*"))).To(HaveLen(1))
+
+		now = now.Add(30 * time.Minute)
+		send(client, points[:1])
+		Expect(filepath.Glob(filepath.Join(dir, "*"))).To(HaveLen(2))
+	})
+
+	It("gzips rotated files and keeps the configured number of them", func() {
+		client := file.NewClient(path,
+			file.WithMaxSize(1),
+			file.WithCompress(true),
+			file.WithMaxBackups(2),
+			file.WithClock(clock),
+			file.WithLogger(logging.Discard()),
+		)
+
+		for i := 0; i < 4; i++ {
+			send(client, points[:1])
+			now = now.Add(time.Minute)
+		}
+
+		rotated, err := filepath.Glob(filepath.Join(dir, "points-*"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rotated).To(Equal([]string{
+			filepath.Join(dir, "points-20180305T142200.000.jsonl.gz"),
+			filepath.Join(dir, "points-20180305T142300.000.jsonl.gz"),
+		}))
+
+		f, err := os.Open(rotated[0])
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		Expect(err).ToNot(HaveOccurred())
+		content, err := ioutil.ReadAll(gz)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring(`"app":"app1"`))
+	})
+
+	It("writes records to the output instead when configured", func() {
+		out := &bytes.Buffer{}
+		client := file.NewClient(path,
+			file.WithFormat(file.CSVFormat),
+			file.WithMaxSize(1),
+			file.WithOutput(out),
+			file.WithLogger(logging.Discard()),
+		)
+
+		send(client, points[:1])
+		send(client, points[:1])
+
+		Expect(out.String()).To(Equal(
+			"timestamp,org,space,app,app_guid,index,count\n" +
+				"2018-03-05T14:18:00Z,org1,space1,app1,a,0,2\n" +
+				"2018-03-05T14:18:00Z,org1,space1,app1,a,0,2\n",
+		))
+		_, err := os.Stat(path)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("returns an error when the file is not open", func() {
+		client := file.NewClient(path, file.WithLogger(logging.Discard()))
+
+		Expect(client.SendPoints(points)).ToNot(Succeed())
+	})
+})
+
+func readFile(path string) string {
+	content, err := ioutil.ReadFile(path)
+	Expect(err).ToNot(HaveOccurred())
+
+	return string(content)
+}
@@ -0,0 +1,117 @@
+package graphite
+
+import (
+	graphite_golang "github.com/marpaia/graphite-golang"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// MetricSender is the interface satisfied by the Graphite connection of
+// github.com/marpaia/graphite-golang.
+type MetricSender interface {
+	SendMetrics([]graphite_golang.Metric) error
+	Connect() error
+	Disconnect() error
+}
+
+// Client encodes points as Graphite metrics and sends them with a
+// MetricSender.
+type Client struct {
+	sender  MetricSender
+	encoder encoding.GraphiteEncoder
+	logger  *logging.Logger
+}
+
+// NewClient returns a Client sending with sender. Metric names start with
+// prefix.
+func NewClient(sender MetricSender, prefix string, opts ...ClientOption) *Client {
+	c := &Client{
+		sender:  sender,
+		encoder: encoding.GraphiteEncoder{Prefix: prefix},
+		logger:  logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// Connect connects the sender.
+func (c *Client) Connect() error {
+	return c.sender.Connect()
+}
+
+// Disconnect disconnects the sender.
+func (c *Client) Disconnect() error {
+	return c.sender.Disconnect()
+}
+
+// SendPoints sends one metric per point, or two when dual writing. Points
+// the name template fails to render are logged and dropped.
+func (c *Client) SendPoints(points []point.Point) error {
+	metrics, err := c.encoder.Metrics(points)
+	if err != nil {
+		c.logger.Error("failed to name points", logging.Fields{
+			"stage": "send",
+			"error": err,
+		})
+	}
+
+	return c.sender.SendMetrics(metrics)
+}
+
+// ClientOption is a func that is used to configure optional settings on a
+// Client.
+type ClientOption func(*Client)
+
+// WithTaggedNames returns a ClientOption for naming metrics with Graphite
+// tags instead of dotted paths.
+func WithTaggedNames() ClientOption {
+	return func(c *Client) {
+		c.encoder.Tagged = true
+	}
+}
+
+// WithSchema returns a ClientOption for configuring the layout of dotted
+// paths. It defaults to the legacy layout.
+func WithSchema(schema encoding.GraphiteSchema) ClientOption {
+	return func(c *Client) {
+		c.encoder.Schema = schema
+	}
+}
+
+// WithDualWrite returns a ClientOption for sending every point under both
+// the legacy and the v2 dotted path.
+func WithDualWrite() ClientOption {
+	return func(c *Client) {
+		c.encoder.DualWrite = true
+	}
+}
+
+// WithNameTemplate returns a ClientOption for rendering the dotted metric names
+// with a template instead of a schema.
+func WithNameTemplate(t *encoding.NameTemplate) ClientOption {
+	return func(c *Client) {
+		c.encoder.Template = t
+	}
+}
+
+// WithGUIDKeys returns a ClientOption for keying the dotted metric names on
+// the app GUID instead of the org, space and app names.
+func WithGUIDKeys() ClientOption {
+	return func(c *Client) {
+		c.encoder.GUIDKeys = true
+	}
+}
+
+// WithLogger returns a ClientOption for configuring the logger used by the
+// Client.
+func WithLogger(l *logging.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
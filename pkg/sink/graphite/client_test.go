@@ -0,0 +1,220 @@
+package graphite_test
+
+import (
+	"bytes"
+
+	graphite_golang "github.com/marpaia/graphite-golang"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/graphite"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var points = []point.Point{
+		{
+			Name:      point.Ingress,
+			Timestamp: 1520259480,
+			Value:     2,
+			Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: 0},
+		},
+		{
+			Name:      point.Ingress,
+			Timestamp: 1520259480,
+			Value:     3,
+			Identity:  point.Identity{Org: "org2", Space: "space 2", App: "app2", AppGUID: "b", Index: 1},
+			Labels:    map[string]string{"foundation": "eu"},
+		},
+	}
+
+	It("sends dotted metrics as they have always been named", func() {
+		sender := &spySender{}
+		client := graphite.NewClient(sender, "test")
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(sender.metrics).To(Equal([]graphite_golang.Metric{
+			{Name: "test.org1.space1.app1.0", Value: "2", Timestamp: 1520259480},
+			{Name: "test.org2.space 2.app2.1", Value: "3", Timestamp: 1520259480},
+		}))
+	})
+
+	It("sends app level points of other kinds below a sibling of the prefix", func() {
+		sender := &spySender{}
+		client := graphite.NewClient(sender, "test")
+
+		Expect(client.SendPoints([]point.Point{{
+			Name:      point.ZScore,
+			Timestamp: 1520259480,
+			Value:     3.5,
+			Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: -1},
+		}})).To(Succeed())
+
+		Expect(sender.metrics).To(Equal([]graphite_golang.Metric{
+			{Name: "test_zscore.org1.space1.app1", Value: "3.5", Timestamp: 1520259480},
+		}))
+
+		client = graphite.NewClient(sender, "test", graphite.WithTaggedNames())
+		Expect(client.SendPoints([]point.Point{{
+			Name:      point.ZScore,
+			Timestamp: 1520259480,
+			Value:     3.5,
+			Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: -1},
+		}})).To(Succeed())
+
+		Expect(sender.metrics[0].Name).To(Equal("test.ingress.zscore;org=org1;space=space1;app=app1;app_guid=a"))
+	})
+
+	It("sends paths with explicit leaf names with the v2 schema", func() {
+		sender := &spySender{}
+		client := graphite.NewClient(sender, "test", graphite.WithSchema(encoding.GraphiteSchemaV2))
+
+		Expect(client.SendPoints(append(points, point.Point{
+			Name:      point.ZScore,
+			Timestamp: 1520259480,
+			Value:     3.5,
+			Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: -1},
+		}))).To(Succeed())
+
+		Expect(sender.metrics).To(Equal([]graphite_golang.Metric{
+			{Name: "test.v2.org1.space1.app1.instances.0.ingress", Value: "2", Timestamp: 1520259480},
+			{Name: "test.v2.org2.space 2.app2.instances.1.ingress", Value: "3", Timestamp: 1520259480},
+			{Name: "test.v2.org1.space1.app1.zscore", Value: "3.5", Timestamp: 1520259480},
+		}))
+	})
+
+	It("sends both layouts when dual writing", func() {
+		sender := &spySender{}
+		client := graphite.NewClient(sender, "test", graphite.WithDualWrite())
+
+		Expect(client.SendPoints(points[:1])).To(Succeed())
+
+		Expect(sender.metrics).To(Equal([]graphite_golang.Metric{
+			{Name: "test.org1.space1.app1.0", Value: "2", Timestamp: 1520259480},
+			{Name: "test.v2.org1.space1.app1.instances.0.ingress", Value: "2", Timestamp: 1520259480},
+		}))
+	})
+
+	It("keys paths on the app GUID", func() {
+		sender := &spySender{}
+		client := graphite.NewClient(sender, "test", graphite.WithGUIDKeys(), graphite.WithDualWrite())
+
+		Expect(client.SendPoints(points[:1])).To(Succeed())
+
+		Expect(sender.metrics).To(Equal([]graphite_golang.Metric{
+			{Name: "test.a.0", Value: "2", Timestamp: 1520259480},
+			{Name: "test.v2.a.instances.0.ingress", Value: "2", Timestamp: 1520259480},
+		}))
+	})
+
+	It("sends names rendered with a template", func() {
+		tmpl, err := encoding.ParseNameTemplate(
+			"{{.Foundation}}.{{.Prefix}}.{{.AppGUID}}{{if not .AppLevel}}.{{.Index}}{{end}}.{{.Kind}}", "test", "eu", point.ZScore)
+		Expect(err).ToNot(HaveOccurred())
+
+		sender := &spySender{}
+		client := graphite.NewClient(sender, "test", graphite.WithNameTemplate(tmpl))
+
+		Expect(client.SendPoints(append(points, point.Point{
+			Name:      point.ZScore,
+			Timestamp: 1520259480,
+			Value:     3.5,
+			Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: -1},
+		}))).To(Succeed())
+
+		Expect(sender.metrics).To(Equal([]graphite_golang.Metric{
+			{Name: "eu.test.a.0.ingress", Value: "2", Timestamp: 1520259480},
+			{Name: "eu.test.b.1.ingress", Value: "3", Timestamp: 1520259480},
+			{Name: "eu.test.a.zscore", Value: "3.5", Timestamp: 1520259480},
+		}))
+	})
+
+	It("drops and logs points the template fails to render", func() {
+		tmpl, err := encoding.ParseNameTemplate(
+			`{{.Prefix}}.{{.AppGUID}}{{if not .AppLevel}}.{{.Index}}{{end}}.{{.Kind}}{{if eq .App "app2"}}{{index .Org 99}}{{end}}`, "test", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		var buf bytes.Buffer
+		sender := &spySender{}
+		client := graphite.NewClient(sender, "test",
+			graphite.WithNameTemplate(tmpl),
+			graphite.WithLogger(logging.New(&buf)),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(sender.metrics).To(Equal([]graphite_golang.Metric{
+			{Name: "test.a.0.ingress", Value: "2", Timestamp: 1520259480},
+		}))
+		Expect(buf.String()).To(ContainSubstring("failed to name points"))
+		Expect(buf.String()).To(ContainSubstring("dropped 1 of 2 points"))
+	})
+
+	It("rejects templates that do not render distinct dotted paths", func() {
+		for text, msg := range map[string]string{
+			"{{.Org}.{{.App}}": "bad character",
+			"{{.Foundation}}.{{.App}}.{{.Index}}.{{.Kind}}":      "empty path segment",
+			"test.{{.App}}.{{.Index}}":                           "both the ingress",
+			"test.{{.Org}}.{{.Index}}.{{.Kind}}":                 "app sample-worker",
+			"test.{{.App}}.{{.Kind}}":                            "instance 1",
+			"test.{{.App}}.{{.Index}}.{{.Kind}}.{{call .App}}":   "call",
+			"test.{{.App}}.{{.Index}}.{{.Kind | replace \"-\"}}": "replace",
+		} {
+			_, err := encoding.ParseNameTemplate(text, "test", "", point.Rate, point.ZScore)
+			Expect(err).To(MatchError(ContainSubstring(msg)), text)
+		}
+	})
+
+	It("only requires the kind in the template when other kinds are sent", func() {
+		text := "{{.Prefix}}.{{.Org}}.{{.Space}}.{{.App}}.{{.Index}}"
+
+		_, err := encoding.ParseNameTemplate(text, "test", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = encoding.ParseNameTemplate(text, "test", "", point.Rate)
+		Expect(err).To(MatchError(ContainSubstring("both the ingress of instance 0 of app sample-web and the rate")))
+
+		_, err = encoding.ParseNameTemplate("{{.Prefix}}.{{.App}}.{{if not .AppLevel}}{{.Index}}.{{end}}{{.Kind}}", "test", "", point.Share)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("sends tagged metrics", func() {
+		sender := &spySender{}
+		client := graphite.NewClient(sender, "test", graphite.WithTaggedNames())
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(sender.metrics).To(Equal([]graphite_golang.Metric{
+			{
+				Name:      "test.ingress;org=org1;space=space1;app=app1;app_guid=a;instance=0",
+				Value:     "2",
+				Timestamp: 1520259480,
+			},
+			{
+				Name:      "test.ingress;org=org2;space=space_2;app=app2;app_guid=b;instance=1;foundation=eu",
+				Value:     "3",
+				Timestamp: 1520259480,
+			},
+		}))
+	})
+})
+
+type spySender struct {
+	metrics []graphite_golang.Metric
+}
+
+func (s *spySender) SendMetrics(metrics []graphite_golang.Metric) error {
+	s.metrics = metrics
+	return nil
+}
+
+func (s *spySender) Connect() error {
+	return nil
+}
+
+func (s *spySender) Disconnect() error {
+	return nil
+}
@@ -0,0 +1,13 @@
+package graphite_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestGraphite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Graphite Suite")
+}
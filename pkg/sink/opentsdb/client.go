@@ -0,0 +1,370 @@
+package opentsdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Client sends points to OpenTSDB, either with the telnet style put protocol
+// or as JSON to the HTTP /api/put endpoint. Every data point is tagged with
+// the org, space, app, app GUID and instance it belongs to.
+type Client struct {
+	addr       string
+	url        string
+	httpClient HTTPClient
+	metricName string
+	tags       map[string]string
+	batchSize  int
+	output     io.Writer
+	logger     *logging.Logger
+
+	conn net.Conn
+}
+
+// NewTelnetClient returns a Client writing put lines to the OpenTSDB daemon
+// at addr over TCP.
+func NewTelnetClient(addr string, opts ...ClientOption) *Client {
+	c := newClient(opts)
+	c.addr = addr
+
+	return c
+}
+
+// NewHTTPClient returns a Client posting data points to the /api/put
+// endpoint at url.
+func NewHTTPClient(url string, opts ...ClientOption) *Client {
+	c := newClient(opts)
+	c.url = url
+
+	return c
+}
+
+func newClient(opts []ClientOption) *Client {
+	c := &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		metricName: "cf.app.ingress",
+		batchSize:  50,
+		logger:     logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	if c.batchSize < 1 {
+		c.batchSize = 1
+	}
+
+	return c
+}
+
+// Connect opens the TCP connection of a telnet client. It is a no-op for
+// HTTP clients.
+func (c *Client) Connect() error {
+	if c.url != "" || c.output != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", c.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+
+	return nil
+}
+
+// Disconnect closes the TCP connection of a telnet client.
+func (c *Client) Disconnect() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+
+	return err
+}
+
+// SendPoints sends one data point per point.
+func (c *Client) SendPoints(points []point.Point) error {
+	dataPoints := make([]DataPoint, 0, len(points))
+	for _, p := range points {
+		dataPoints = append(dataPoints, c.dataPoint(p))
+	}
+
+	if c.url != "" {
+		return c.post(dataPoints)
+	}
+
+	return c.put(dataPoints)
+}
+
+func (c *Client) dataPoint(p point.Point) DataPoint {
+	tags := map[string]string{
+		"org":      sanitize(p.Identity.Org),
+		"space":    sanitize(p.Identity.Space),
+		"app":      sanitize(p.Identity.App),
+		"app_guid": sanitize(p.Identity.AppGUID),
+	}
+	if !p.Identity.AppLevel() {
+		tags["instance"] = strconv.Itoa(p.Identity.Index)
+	}
+	for _, extra := range []map[string]string{p.Labels, c.tags} {
+		for k, v := range extra {
+			k = sanitize(k)
+			if _, ok := tags[k]; !ok {
+				tags[k] = sanitize(v)
+			}
+		}
+	}
+
+	return DataPoint{
+		Metric:    sanitize(encoding.MetricName(c.metricName, ".", p.Name)),
+		Timestamp: p.Timestamp,
+		Value:     json.Number(encoding.FormatValue(p.Value)),
+		Tags:      tags,
+	}
+}
+
+// put writes the data points as telnet style put lines. OpenTSDB does not
+// acknowledge them, invalid lines are only reported in its own log.
+func (c *Client) put(points []DataPoint) error {
+	w := c.output
+	if w == nil {
+		if c.conn == nil {
+			return fmt.Errorf("not connected to opentsdb at %s", c.addr)
+		}
+		w = c.conn
+	}
+
+	buf := bufio.NewWriter(w)
+	for _, p := range points {
+		if _, err := buf.WriteString(p.line()); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
+
+// post sends the data points in chunks of the configured batch size. The
+// details of failed data points are requested, so that the error can say
+// why they were rejected.
+func (c *Client) post(points []DataPoint) error {
+	for start := 0; start < len(points); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(points) {
+			end = len(points)
+		}
+
+		if err := c.postChunk(points[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) postChunk(points []DataPoint) error {
+	body, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("details", "")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	var result PutResult
+	if len(respBody) > 0 && json.Unmarshal(respBody, &result) == nil && (result.Success > 0 || result.Failed > 0) {
+		return c.checkResult(result, len(points))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(respBody) > 1024 {
+			respBody = respBody[:1024]
+		}
+		return fmt.Errorf("expected successful status code from opentsdb, got %d: %s", resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+func (c *Client) checkResult(result PutResult, sent int) error {
+	if result.Failed == 0 {
+		return nil
+	}
+
+	for _, e := range result.Errors {
+		c.logger.Debug("opentsdb rejected data point", logging.Fields{
+			"stage":  "send",
+			"metric": e.DataPoint.Metric,
+			"tags":   e.DataPoint.Tags,
+			"error":  e.Error,
+		})
+	}
+
+	err := fmt.Errorf("opentsdb rejected %d of %d data points", result.Failed, sent)
+	if len(result.Errors) > 0 {
+		err = fmt.Errorf("%v: %s", err, result.Errors[0].Error)
+	}
+
+	return err
+}
+
+// DataPoint is a single data point as accepted by /api/put.
+type DataPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     json.Number       `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// line renders the data point as a telnet style put line. Tags are sorted
+// to keep lines stable.
+func (p DataPoint) line() string {
+	keys := make([]string, 0, len(p.Tags))
+	for k := range p.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "put %s %d %s", p.Metric, p.Timestamp, p.Value)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, p.Tags[k])
+	}
+	b.WriteByte('\n')
+
+	return b.String()
+}
+
+// PutResult is the response of /api/put when details are requested.
+type PutResult struct {
+	Success int        `json:"success"`
+	Failed  int        `json:"failed"`
+	Errors  []PutError `json:"errors"`
+}
+
+// PutError describes why a data point was rejected.
+type PutError struct {
+	DataPoint DataPoint `json:"datapoint"`
+	Error     string    `json:"error"`
+}
+
+// sanitize replaces the characters OpenTSDB does not allow in metric names
+// and tags. Only letters, digits, '-', '_', '.' and '/' are allowed, and
+// empty values are rejected.
+func sanitize(s string) string {
+	if s == "" {
+		return "_"
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			return r
+		case r == '-', r == '_', r == '.', r == '/':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// HTTPClient is the interface used for sending requests to OpenTSDB.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// ClientOption is a func that is used to configure optional settings on a
+// Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient returns a ClientOption for configuring the HTTPClient used
+// to post data points.
+func WithHTTPClient(h HTTPClient) ClientOption {
+	return func(c *Client) {
+		c.httpClient = h
+	}
+}
+
+// WithMetricName returns a ClientOption for configuring the name of the
+// metric.
+func WithMetricName(name string) ClientOption {
+	return func(c *Client) {
+		c.metricName = name
+	}
+}
+
+// WithTags returns a ClientOption for configuring tags added to every data
+// point, e.g. the foundation. They do not override the tags describing the
+// app instance. OpenTSDB limits the number of tags per data point, eight by
+// default.
+func WithTags(tags map[string]string) ClientOption {
+	return func(c *Client) {
+		c.tags = tags
+	}
+}
+
+// WithBatchSize returns a ClientOption for configuring the maximum number of
+// data points per HTTP request.
+func WithBatchSize(n int) ClientOption {
+	return func(c *Client) {
+		c.batchSize = n
+	}
+}
+
+// WithOutput returns a ClientOption for writing the put lines of a telnet
+// client to w instead of sending them.
+func WithOutput(w io.Writer) ClientOption {
+	return func(c *Client) {
+		c.output = w
+	}
+}
+
+// WithLogger returns a ClientOption for configuring the logger used by the
+// Client.
+func WithLogger(l *logging.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
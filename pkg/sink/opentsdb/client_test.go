@@ -0,0 +1,213 @@
+package opentsdb_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/opentsdb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		points []point.Point
+	)
+
+	BeforeEach(func() {
+		points = []point.Point{
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     2,
+				Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: 0},
+			},
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     3,
+				Identity:  point.Identity{Org: "org2", Space: "space 2", App: "app:2", AppGUID: "b", Index: 1},
+			},
+		}
+	})
+
+	Context("with the telnet protocol", func() {
+		It("writes put lines with sanitized tags", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer listener.Close()
+
+			lines := make(chan string, 10)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+
+			client := opentsdb.NewTelnetClient(listener.Addr().String(),
+				opentsdb.WithTags(map[string]string{"foundation": "eu 1"}),
+				opentsdb.WithLogger(logging.Discard()),
+			)
+			Expect(client.Connect()).To(Succeed())
+			Expect(client.SendPoints(points)).To(Succeed())
+			Expect(client.Disconnect()).To(Succeed())
+
+			Eventually(lines).Should(Receive(Equal(
+				"put cf.app.ingress 1520259480 2 app=app1 app_guid=a foundation=eu_1 instance=0 org=org1 space=space1",
+			)))
+			Eventually(lines).Should(Receive(Equal(
+				"put cf.app.ingress 1520259480 3 app=app_2 app_guid=b foundation=eu_1 instance=1 org=org2 space=space_2",
+			)))
+		})
+
+		It("writes put lines to the output instead when configured", func() {
+			out := &bytes.Buffer{}
+			client := opentsdb.NewTelnetClient("unused:4242",
+				opentsdb.WithOutput(out),
+				opentsdb.WithLogger(logging.Discard()),
+			)
+			Expect(client.Connect()).To(Succeed())
+
+			Expect(client.SendPoints(points[:1])).To(Succeed())
+
+			Expect(out.String()).To(Equal(
+				"put cf.app.ingress 1520259480 2 app=app1 app_guid=a instance=0 org=org1 space=space1\n",
+			))
+		})
+
+		It("returns an error when not connected", func() {
+			client := opentsdb.NewTelnetClient("127.0.0.1:4242", opentsdb.WithLogger(logging.Discard()))
+
+			Expect(client.SendPoints(points)).ToNot(Succeed())
+		})
+	})
+
+	Context("with the HTTP protocol", func() {
+		var (
+			tsd    *fakeTSD
+			server *httptest.Server
+		)
+
+		BeforeEach(func() {
+			tsd = &fakeTSD{}
+			server = httptest.NewServer(tsd)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("posts data points as JSON requesting details", func() {
+			client := opentsdb.NewHTTPClient(server.URL+"/api/put",
+				opentsdb.WithLogger(logging.Discard()),
+			)
+
+			Expect(client.SendPoints(points)).To(Succeed())
+
+			Expect(tsd.requests()).To(HaveLen(1))
+			req := tsd.requests()[0]
+			Expect(req.path).To(Equal("/api/put"))
+			Expect(req.query).To(HaveKey("details"))
+			Expect(req.points).To(Equal([]opentsdb.DataPoint{
+				{
+					Metric:    "cf.app.ingress",
+					Timestamp: 1520259480,
+					Value:     "2",
+					Tags:      map[string]string{"org": "org1", "space": "space1", "app": "app1", "app_guid": "a", "instance": "0"},
+				},
+				{
+					Metric:    "cf.app.ingress",
+					Timestamp: 1520259480,
+					Value:     "3",
+					Tags:      map[string]string{"org": "org2", "space": "space_2", "app": "app_2", "app_guid": "b", "instance": "1"},
+				},
+			}))
+		})
+
+		It("posts data points in chunks", func() {
+			client := opentsdb.NewHTTPClient(server.URL,
+				opentsdb.WithBatchSize(1),
+				opentsdb.WithLogger(logging.Discard()),
+			)
+
+			Expect(client.SendPoints(points)).To(Succeed())
+
+			Expect(tsd.requests()).To(HaveLen(2))
+		})
+
+		It("returns the details of rejected data points", func() {
+			tsd.status = http.StatusBadRequest
+			tsd.response = `{"success":1,"failed":1,"errors":[{"datapoint":{"metric":"cf.app.ingress","timestamp":1520259480,"value":"3","tags":{}},"error":"Too many tags"}]}`
+			client := opentsdb.NewHTTPClient(server.URL, opentsdb.WithLogger(logging.Discard()))
+
+			err := client.SendPoints(points)
+
+			Expect(err).To(MatchError("opentsdb rejected 1 of 2 data points: Too many tags"))
+		})
+
+		It("returns an error for other failures", func() {
+			tsd.status = http.StatusInternalServerError
+			tsd.response = `{"error":{"code":500,"message":"boom"}}`
+			client := opentsdb.NewHTTPClient(server.URL, opentsdb.WithLogger(logging.Discard()))
+
+			Expect(client.SendPoints(points)).ToNot(Succeed())
+		})
+	})
+})
+
+type request struct {
+	path   string
+	query  map[string][]string
+	points []opentsdb.DataPoint
+}
+
+type fakeTSD struct {
+	mu        sync.Mutex
+	status    int
+	response  string
+	_requests []request
+}
+
+func (f *fakeTSD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+
+	var points []opentsdb.DataPoint
+	Expect(json.NewDecoder(r.Body).Decode(&points)).To(Succeed())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f._requests = append(f._requests, request{
+		path:   r.URL.Path,
+		query:  r.URL.Query(),
+		points: points,
+	})
+
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		w.Write([]byte(f.response))
+		return
+	}
+	w.Write([]byte(`{"success":1,"failed":0,"errors":[]}`))
+}
+
+func (f *fakeTSD) requests() []request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f._requests
+}
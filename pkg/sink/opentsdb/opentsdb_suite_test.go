@@ -0,0 +1,13 @@
+package opentsdb_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOpenTSDB(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "OpenTSDB Suite")
+}
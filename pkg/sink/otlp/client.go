@@ -0,0 +1,324 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// DefaultURL is the metrics endpoint of an OpenTelemetry collector running
+// on the same host.
+const DefaultURL = "http://localhost:4318/v1/metrics"
+
+// Encoding is the body encoding of OTLP/HTTP requests.
+type Encoding int
+
+// Supported encodings.
+const (
+	JSONEncoding Encoding = iota
+	ProtobufEncoding
+)
+
+// Kind is the OTLP metric type the ingress counts are exported as.
+type Kind int
+
+// Supported kinds. A delta sum covers the accumulator bucket of its data
+// point, a gauge is reported at the start of the bucket like in Graphite.
+const (
+	GaugeKind Kind = iota
+	DeltaSumKind
+)
+
+// Client exports points as OTLP metrics over HTTP. The resource carries the
+// configured attributes, e.g. the foundation, and every data point carries
+// the org, space, app and instance it belongs to.
+type Client struct {
+	url                string
+	httpClient         HTTPClient
+	encoding           Encoding
+	kind               Kind
+	metricName         string
+	bucketWidth        time.Duration
+	headers            map[string]string
+	resourceAttributes []KeyValue
+	logger             *logging.Logger
+}
+
+// NewClient returns a Client exporting to the OTLP/HTTP metrics endpoint at
+// url.
+func NewClient(url string, opts ...ClientOption) *Client {
+	c := &Client{
+		url:         url,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		metricName:  "cloudfoundry.app.ingress",
+		bucketWidth: time.Minute,
+		resourceAttributes: []KeyValue{
+			stringAttribute("service.name", "noisy-neighbor-reporter"),
+		},
+		logger: logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// Connect is a no-op, every export is sent with its own request.
+func (c *Client) Connect() error {
+	return nil
+}
+
+// Disconnect is a no-op.
+func (c *Client) Disconnect() error {
+	return nil
+}
+
+// SendPoints exports the points as data points of one metric per kind of
+// point.
+func (c *Client) SendPoints(points []point.Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	var kinds []string
+	dataPoints := make(map[string][]NumberDataPoint)
+	for _, p := range points {
+		if _, ok := dataPoints[p.Name]; !ok {
+			kinds = append(kinds, p.Name)
+		}
+		dataPoints[p.Name] = append(dataPoints[p.Name], c.dataPoint(p))
+	}
+
+	metrics := make([]Metric, 0, len(kinds))
+	for _, kind := range kinds {
+		metrics = append(metrics, c.metric(kind, dataPoints[kind]))
+	}
+
+	return c.export(c.request(metrics))
+}
+
+// sum reports whether points of the kind are exported as a delta sum.
+// Only the ingress is a count, the other kinds are always gauges.
+func (c *Client) sum(kind string) bool {
+	return c.kind == DeltaSumKind && (kind == point.Ingress || kind == "")
+}
+
+func (c *Client) dataPoint(p point.Point) NumberDataPoint {
+	dp := numberDataPoint(p.Value)
+
+	start := time.Unix(p.Timestamp, 0)
+	if c.sum(p.Name) {
+		dp.StartTimeUnixNano = uint64(start.UnixNano())
+		dp.TimeUnixNano = uint64(start.Add(c.bucketWidth).UnixNano())
+	} else {
+		dp.TimeUnixNano = uint64(start.UnixNano())
+	}
+
+	dp.Attributes = []KeyValue{
+		stringAttribute("cloudfoundry.org.name", p.Identity.Org),
+		stringAttribute("cloudfoundry.space.name", p.Identity.Space),
+		stringAttribute("cloudfoundry.app.name", p.Identity.App),
+		stringAttribute("cloudfoundry.app.id", p.Identity.AppGUID),
+	}
+	if !p.Identity.AppLevel() {
+		dp.Attributes = append(dp.Attributes, stringAttribute("cloudfoundry.app.instance.id", strconv.Itoa(p.Identity.Index)))
+	}
+	for _, k := range encoding.SortedKeys(p.Labels) {
+		dp.Attributes = append(dp.Attributes, stringAttribute(k, p.Labels[k]))
+	}
+
+	return dp
+}
+
+// kindMetrics describes the metrics of the kinds of points other than the
+// ingress.
+var kindMetrics = map[string]Metric{
+	point.Rate: {
+		Description: "Number of log envelopes emitted by an app instance per second.",
+		Unit:        "{envelope}/s",
+	},
+	point.Share: {
+		Description: "Percentage of the log envelopes of the foundation emitted by an app or app instance.",
+		Unit:        "%",
+	},
+	point.Delta: {
+		Description: "Change of the number of log envelopes emitted by an app instance from the previous accumulator bucket.",
+		Unit:        "{envelope}",
+	},
+	point.ZScore: {
+		Description: "Deviation of the ingress of an app from its baseline, in standard deviations.",
+		Unit:        "1",
+	},
+	point.Score: {
+		Description: "How much an app is likely to hurt its neighbours, between 0 and 100.",
+		Unit:        "1",
+	},
+	point.Rank: {
+		Description: "Position of an app when ordered by its noisy neighbour score, starting at 1.",
+		Unit:        "1",
+	},
+}
+
+func (c *Client) metric(kind string, dataPoints []NumberDataPoint) Metric {
+	metric := Metric{
+		Name:        c.metricName,
+		Description: "Number of log envelopes emitted by an app instance per accumulator bucket.",
+		Unit:        "{envelope}",
+	}
+	if kind != point.Ingress && kind != "" {
+		metric = kindMetrics[kind]
+		metric.Name = encoding.MetricName(c.metricName, ".", kind)
+	}
+
+	if c.sum(kind) {
+		metric.Sum = &Sum{
+			DataPoints:             dataPoints,
+			AggregationTemporality: AggregationTemporalityDelta,
+			IsMonotonic:            true,
+		}
+	} else {
+		metric.Gauge = &Gauge{DataPoints: dataPoints}
+	}
+
+	return metric
+}
+
+func (c *Client) request(metrics []Metric) ExportMetricsServiceRequest {
+	return ExportMetricsServiceRequest{
+		ResourceMetrics: []ResourceMetrics{{
+			Resource: Resource{Attributes: c.resourceAttributes},
+			ScopeMetrics: []ScopeMetrics{{
+				Scope:   InstrumentationScope{Name: "github.com/SpringerPE/noisy-neighbor-reporters"},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+func (c *Client) export(r ExportMetricsServiceRequest) error {
+	var (
+		body        []byte
+		contentType string
+	)
+
+	switch c.encoding {
+	case ProtobufEncoding:
+		body = r.MarshalProto()
+		contentType = "application/x-protobuf"
+	default:
+		var err error
+		body, err = json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("expected successful status code from otlp endpoint, got %d: %s", resp.StatusCode, respBody)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
+
+// HTTPClient is the interface used for sending requests to the OTLP
+// endpoint.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// ClientOption is a func that is used to configure optional settings on a
+// Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient returns a ClientOption for configuring the HTTPClient used
+// to export metrics.
+func WithHTTPClient(h HTTPClient) ClientOption {
+	return func(c *Client) {
+		c.httpClient = h
+	}
+}
+
+// WithEncoding returns a ClientOption for configuring the body encoding. It
+// defaults to JSON.
+func WithEncoding(e Encoding) ClientOption {
+	return func(c *Client) {
+		c.encoding = e
+	}
+}
+
+// WithKind returns a ClientOption for configuring the metric type. It
+// defaults to a gauge.
+func WithKind(k Kind) ClientOption {
+	return func(c *Client) {
+		c.kind = k
+	}
+}
+
+// WithMetricName returns a ClientOption for configuring the name of the
+// metric.
+func WithMetricName(name string) ClientOption {
+	return func(c *Client) {
+		c.metricName = name
+	}
+}
+
+// WithBucketWidth returns a ClientOption for configuring the width of the
+// accumulator buckets, which is the interval covered by a delta sum.
+func WithBucketWidth(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.bucketWidth = d
+	}
+}
+
+// WithHeaders returns a ClientOption for configuring headers sent with every
+// request, e.g. for authentication.
+func WithHeaders(headers map[string]string) ClientOption {
+	return func(c *Client) {
+		c.headers = headers
+	}
+}
+
+// WithResourceAttribute returns a ClientOption for adding a string attribute
+// to the resource, e.g. the foundation.
+func WithResourceAttribute(key, value string) ClientOption {
+	return func(c *Client) {
+		c.resourceAttributes = append(c.resourceAttributes, stringAttribute(key, value))
+	}
+}
+
+// WithLogger returns a ClientOption for configuring the logger used by the
+// Client.
+func WithLogger(l *logging.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
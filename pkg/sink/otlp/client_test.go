@@ -0,0 +1,245 @@
+package otlp_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/otlp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		collector *fakeCollector
+		server    *httptest.Server
+		points    []point.Point
+	)
+
+	BeforeEach(func() {
+		collector = &fakeCollector{}
+		server = httptest.NewServer(collector)
+
+		points = []point.Point{
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     2,
+				Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: 0},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("exports gauge data points as OTLP/HTTP JSON", func() {
+		client := otlp.NewClient(server.URL+"/v1/metrics",
+			otlp.WithResourceAttribute("cloudfoundry.foundation", "eu-1"),
+			otlp.WithHeaders(map[string]string{"Authorization": "Bearer token"}),
+			otlp.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(collector.requests()).To(HaveLen(1))
+		req := collector.requests()[0]
+		Expect(req.path).To(Equal("/v1/metrics"))
+		Expect(req.contentType).To(Equal("application/json"))
+		Expect(req.authorization).To(Equal("Bearer token"))
+		Expect(req.body).To(MatchJSON(`{
+			"resourceMetrics": [{
+				"resource": {"attributes": [
+					{"key": "service.name", "value": {"stringValue": "noisy-neighbor-reporter"}},
+					{"key": "cloudfoundry.foundation", "value": {"stringValue": "eu-1"}}
+				]},
+				"scopeMetrics": [{
+					"scope": {"name": "github.com/SpringerPE/noisy-neighbor-reporters"},
+					"metrics": [{
+						"name": "cloudfoundry.app.ingress",
+						"description": "Number of log envelopes emitted by an app instance per accumulator bucket.",
+						"unit": "{envelope}",
+						"gauge": {"dataPoints": [{
+							"attributes": [
+								{"key": "cloudfoundry.org.name", "value": {"stringValue": "org1"}},
+								{"key": "cloudfoundry.space.name", "value": {"stringValue": "space1"}},
+								{"key": "cloudfoundry.app.name", "value": {"stringValue": "app1"}},
+								{"key": "cloudfoundry.app.id", "value": {"stringValue": "a"}},
+								{"key": "cloudfoundry.app.instance.id", "value": {"stringValue": "0"}}
+							],
+							"timeUnixNano": "1520259480000000000",
+							"asInt": "2"
+						}]}
+					}]
+				}]
+			}]
+		}`))
+	})
+
+	It("exports delta sums covering the accumulator bucket", func() {
+		client := otlp.NewClient(server.URL,
+			otlp.WithKind(otlp.DeltaSumKind),
+			otlp.WithBucketWidth(time.Minute),
+			otlp.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		var body otlp.ExportMetricsServiceRequest
+		Expect(json.Unmarshal(collector.requests()[0].body, &body)).To(Succeed())
+		sum := body.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Sum
+		Expect(sum).ToNot(BeNil())
+		Expect(sum.AggregationTemporality).To(Equal(otlp.AggregationTemporalityDelta))
+		Expect(sum.IsMonotonic).To(BeTrue())
+		Expect(sum.DataPoints[0].StartTimeUnixNano).To(Equal(uint64(1520259480000000000)))
+		Expect(sum.DataPoints[0].TimeUnixNano).To(Equal(uint64(1520259540000000000)))
+	})
+
+	It("exports other kinds of points as gauges of their own metric", func() {
+		client := otlp.NewClient(server.URL,
+			otlp.WithKind(otlp.DeltaSumKind),
+			otlp.WithLogger(logging.Discard()),
+		)
+
+		points = append(points, point.Point{
+			Name:      point.ZScore,
+			Timestamp: 1520259480,
+			Value:     2.5,
+			Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: -1},
+		})
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		var body otlp.ExportMetricsServiceRequest
+		Expect(json.Unmarshal(collector.requests()[0].body, &body)).To(Succeed())
+		metrics := body.ResourceMetrics[0].ScopeMetrics[0].Metrics
+		Expect(metrics).To(HaveLen(2))
+		Expect(metrics[0].Name).To(Equal("cloudfoundry.app.ingress"))
+		Expect(metrics[0].Sum).ToNot(BeNil())
+
+		Expect(metrics[1].Name).To(Equal("cloudfoundry.app.ingress.zscore"))
+		Expect(metrics[1].Unit).To(Equal("1"))
+		Expect(metrics[1].Sum).To(BeNil())
+		Expect(metrics[1].Gauge.DataPoints).To(HaveLen(1))
+		dp := metrics[1].Gauge.DataPoints[0]
+		Expect(dp.StartTimeUnixNano).To(BeZero())
+		Expect(dp.Attributes).To(HaveLen(4))
+	})
+
+	It("exports data points as protobuf", func() {
+		client := otlp.NewClient(server.URL,
+			otlp.WithEncoding(otlp.ProtobufEncoding),
+			otlp.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		req := collector.requests()[0]
+		Expect(req.contentType).To(Equal("application/x-protobuf"))
+
+		resourceMetrics := protoFields(req.body)[1][0]
+		scopeMetrics := protoFields(resourceMetrics)[2][0]
+		metric := protoFields(protoFields(scopeMetrics)[2][0])
+		Expect(string(metric[1][0])).To(Equal("cloudfoundry.app.ingress"))
+
+		dataPoint := protoFields(protoFields(metric[5][0])[1][0])
+		Expect(binary.LittleEndian.Uint64(dataPoint[3][0])).To(Equal(uint64(1520259480000000000)))
+		Expect(binary.LittleEndian.Uint64(dataPoint[6][0])).To(Equal(uint64(2)))
+		Expect(dataPoint[7]).To(HaveLen(5))
+
+		attribute := protoFields(dataPoint[7][0])
+		Expect(string(attribute[1][0])).To(Equal("cloudfoundry.org.name"))
+		Expect(string(protoFields(attribute[2][0])[1][0])).To(Equal("org1"))
+	})
+
+	It("does not export anything without data points", func() {
+		client := otlp.NewClient(server.URL, otlp.WithLogger(logging.Discard()))
+
+		Expect(client.SendPoints(nil)).To(Succeed())
+
+		Expect(collector.requests()).To(BeEmpty())
+	})
+
+	It("returns an error when the collector rejects the export", func() {
+		collector.status = http.StatusBadRequest
+		client := otlp.NewClient(server.URL, otlp.WithLogger(logging.Discard()))
+
+		Expect(client.SendPoints(points)).ToNot(Succeed())
+	})
+})
+
+// protoFields decodes the top level fields of a protobuf message. Varints
+// are not needed by the tests and are skipped.
+func protoFields(b []byte) map[int][][]byte {
+	fields := make(map[int][][]byte)
+
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		Expect(n).To(BeNumerically(">", 0))
+		b = b[n:]
+
+		field, wireType := int(key>>3), key&7
+		switch wireType {
+		case 0:
+			_, n := binary.Uvarint(b)
+			b = b[n:]
+		case 1:
+			fields[field] = append(fields[field], b[:8])
+			b = b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[field] = append(fields[field], b[:length])
+			b = b[length:]
+		default:
+			Fail("unexpected wire type")
+		}
+	}
+
+	return fields
+}
+
+type request struct {
+	path          string
+	contentType   string
+	authorization string
+	body          []byte
+}
+
+type fakeCollector struct {
+	mu        sync.Mutex
+	status    int
+	_requests []request
+}
+
+func (f *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f._requests = append(f._requests, request{
+		path:          r.URL.Path,
+		contentType:   r.Header.Get("Content-Type"),
+		authorization: r.Header.Get("Authorization"),
+		body:          body,
+	})
+
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+	}
+}
+
+func (f *fakeCollector) requests() []request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f._requests
+}
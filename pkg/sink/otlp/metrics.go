@@ -0,0 +1,208 @@
+package otlp
+
+import (
+	"math"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/protobuf"
+)
+
+// The types in this file mirror the subset of the OTLP metrics data model
+// that is needed to export gauges and sums. They are encoded both with the
+// OTLP/HTTP JSON mapping and as protobuf, without depending on generated
+// code.
+
+// ExportMetricsServiceRequest is the body of an OTLP/HTTP metrics request.
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+// ResourceMetrics holds the metrics of a single resource.
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+// Resource describes the entity producing the metrics.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// ScopeMetrics holds the metrics produced by a single instrumentation scope.
+type ScopeMetrics struct {
+	Scope   InstrumentationScope `json:"scope"`
+	Metrics []Metric             `json:"metrics"`
+}
+
+// InstrumentationScope identifies the library producing the metrics.
+type InstrumentationScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Metric is a named gauge or sum.
+type Metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	Gauge       *Gauge `json:"gauge,omitempty"`
+	Sum         *Sum   `json:"sum,omitempty"`
+}
+
+// Gauge holds data points that are sampled values.
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+// Sum holds data points that are counts over an interval.
+type Sum struct {
+	DataPoints             []NumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+// AggregationTemporalityDelta marks sums reporting the count of their own
+// interval only.
+const AggregationTemporalityDelta = 1
+
+// NumberDataPoint is a single value with its attributes. Timestamps and
+// integer values are encoded as strings in JSON, as required by the proto3
+// JSON mapping for 64 bit integers.
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano uint64     `json:"startTimeUnixNano,string,omitempty"`
+	TimeUnixNano      uint64     `json:"timeUnixNano,string"`
+	AsInt             *int64     `json:"asInt,string,omitempty"`
+	AsDouble          *float64   `json:"asDouble,omitempty"`
+}
+
+// KeyValue is a string attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue holds the value of an attribute. Only strings are used.
+type AnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// MarshalProto encodes the request in the protobuf wire format.
+func (r ExportMetricsServiceRequest) MarshalProto() []byte {
+	var e protobuf.Encoder
+	for _, rm := range r.ResourceMetrics {
+		e.Message(1, rm.marshalProto())
+	}
+	return e.Bytes()
+}
+
+func (rm ResourceMetrics) marshalProto() []byte {
+	var e protobuf.Encoder
+	e.Message(1, rm.Resource.marshalProto())
+	for _, sm := range rm.ScopeMetrics {
+		e.Message(2, sm.marshalProto())
+	}
+	return e.Bytes()
+}
+
+func (r Resource) marshalProto() []byte {
+	var e protobuf.Encoder
+	for _, kv := range r.Attributes {
+		e.Message(1, kv.marshalProto())
+	}
+	return e.Bytes()
+}
+
+func (sm ScopeMetrics) marshalProto() []byte {
+	var e protobuf.Encoder
+	e.Message(1, sm.Scope.marshalProto())
+	for _, m := range sm.Metrics {
+		e.Message(2, m.marshalProto())
+	}
+	return e.Bytes()
+}
+
+func (s InstrumentationScope) marshalProto() []byte {
+	var e protobuf.Encoder
+	e.String(1, s.Name)
+	e.String(2, s.Version)
+	return e.Bytes()
+}
+
+func (m Metric) marshalProto() []byte {
+	var e protobuf.Encoder
+	e.String(1, m.Name)
+	e.String(2, m.Description)
+	e.String(3, m.Unit)
+	if m.Gauge != nil {
+		e.Message(5, m.Gauge.marshalProto())
+	}
+	if m.Sum != nil {
+		e.Message(7, m.Sum.marshalProto())
+	}
+	return e.Bytes()
+}
+
+func (g Gauge) marshalProto() []byte {
+	var e protobuf.Encoder
+	for _, dp := range g.DataPoints {
+		e.Message(1, dp.marshalProto())
+	}
+	return e.Bytes()
+}
+
+func (s Sum) marshalProto() []byte {
+	var e protobuf.Encoder
+	for _, dp := range s.DataPoints {
+		e.Message(1, dp.marshalProto())
+	}
+	e.Varint(2, uint64(s.AggregationTemporality))
+	if s.IsMonotonic {
+		e.Varint(3, 1)
+	}
+	return e.Bytes()
+}
+
+func (dp NumberDataPoint) marshalProto() []byte {
+	var e protobuf.Encoder
+	if dp.StartTimeUnixNano != 0 {
+		e.Fixed64(2, dp.StartTimeUnixNano)
+	}
+	e.Fixed64(3, dp.TimeUnixNano)
+	if dp.AsDouble != nil {
+		e.Double(4, *dp.AsDouble)
+	}
+	if dp.AsInt != nil {
+		e.Fixed64(6, uint64(*dp.AsInt))
+	}
+	for _, kv := range dp.Attributes {
+		e.Message(7, kv.marshalProto())
+	}
+	return e.Bytes()
+}
+
+func (kv KeyValue) marshalProto() []byte {
+	var e protobuf.Encoder
+	e.String(1, kv.Key)
+
+	var v protobuf.Encoder
+	v.String(1, kv.Value.StringValue)
+	e.Message(2, v.Bytes())
+
+	return e.Bytes()
+}
+
+// stringAttribute returns a KeyValue with a string value.
+func stringAttribute(key, value string) KeyValue {
+	return KeyValue{Key: key, Value: AnyValue{StringValue: value}}
+}
+
+// numberDataPoint returns a data point holding value as an integer if it is
+// one, and as a double otherwise.
+func numberDataPoint(value float64) NumberDataPoint {
+	if value == math.Trunc(value) && math.Abs(value) < 1<<63 {
+		i := int64(value)
+		return NumberDataPoint{AsInt: &i}
+	}
+
+	return NumberDataPoint{AsDouble: &value}
+}
@@ -0,0 +1,320 @@
+package remotewrite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/protobuf"
+)
+
+// Client pushes points to a Prometheus remote write endpoint such as Cortex,
+// Mimir or VictoriaMetrics. Every point becomes a series labelled with the
+// org, space, app and instance it belongs to, with a single sample at the
+// accumulator bucket timestamp.
+type Client struct {
+	url         string
+	httpClient  HTTPClient
+	metricName  string
+	labels      map[string]string
+	batchSize   int
+	maxRetries  int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	username    string
+	password    string
+	bearerToken string
+	logger      *logging.Logger
+}
+
+// NewClient returns a Client writing to the remote write endpoint at url.
+func NewClient(url string, opts ...ClientOption) *Client {
+	c := &Client{
+		url:        url,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		metricName: "cf_app_ingress",
+		batchSize:  2000,
+		maxRetries: 3,
+		minBackoff: time.Second,
+		maxBackoff: 30 * time.Second,
+		logger:     logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	if c.batchSize < 1 {
+		c.batchSize = 1
+	}
+
+	return c
+}
+
+// Connect is a no-op, every batch is sent with its own request.
+func (c *Client) Connect() error {
+	return nil
+}
+
+// Disconnect is a no-op.
+func (c *Client) Disconnect() error {
+	return nil
+}
+
+// SendPoints writes the points in batches of the configured size.
+func (c *Client) SendPoints(points []point.Point) error {
+	series := make([]TimeSeries, 0, len(points))
+	for _, p := range points {
+		series = append(series, c.timeSeries(p))
+	}
+
+	for start := 0; start < len(series); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(series) {
+			end = len(series)
+		}
+
+		if err := c.write(WriteRequest{Timeseries: series[start:end]}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) timeSeries(p point.Point) TimeSeries {
+	labels := map[string]string{
+		"__name__": encoding.MetricName(c.metricName, "_", p.Name),
+		"org":      p.Identity.Org,
+		"space":    p.Identity.Space,
+		"app":      p.Identity.App,
+		"app_guid": p.Identity.AppGUID,
+	}
+	if !p.Identity.AppLevel() {
+		labels["instance"] = strconv.Itoa(p.Identity.Index)
+	}
+	for _, extra := range []map[string]string{p.Labels, c.labels} {
+		for k, v := range extra {
+			if _, ok := labels[k]; !ok {
+				labels[k] = v
+			}
+		}
+	}
+
+	return TimeSeries{
+		Labels:  sortedLabels(labels),
+		Samples: []Sample{{Value: p.Value, Timestamp: p.Timestamp * 1000}},
+	}
+}
+
+func (c *Client) write(r WriteRequest) error {
+	body := snappy.Encode(nil, r.MarshalProto())
+
+	backoff := c.minBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.writeOnce(body)
+		if err == nil || !retry || attempt >= c.maxRetries {
+			return err
+		}
+
+		c.logger.Warn("failed to remote write, retrying", logging.Fields{
+			"stage":   "send",
+			"attempt": attempt + 1,
+			"backoff": backoff.String(),
+			"error":   err,
+		})
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// writeOnce sends a single request and reports whether it is worth retrying
+// when it failed. As recommended by the remote write specification, 5xx and
+// 429 responses are retried while other 4xx responses are not.
+func (c *Client) writeOnce(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "noisy-neighbor-reporter")
+
+	switch {
+	case c.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("expected successful status code from remote write endpoint, got %d: %s", resp.StatusCode, respBody)
+
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	return false, nil
+}
+
+func sortedLabels(labels map[string]string) []Label {
+	sorted := make([]Label, 0, len(labels))
+	for k, v := range labels {
+		sorted = append(sorted, Label{Name: k, Value: v})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// WriteRequest is the body of a remote write request.
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+// TimeSeries is a labelled series of samples. Labels are sorted by name.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// Label is a single label of a series.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value at a timestamp in milliseconds.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// MarshalProto encodes the request in the protobuf wire format of
+// prometheus.WriteRequest.
+func (r WriteRequest) MarshalProto() []byte {
+	var e protobuf.Encoder
+	for _, ts := range r.Timeseries {
+		e.Message(1, ts.marshalProto())
+	}
+	return e.Bytes()
+}
+
+func (ts TimeSeries) marshalProto() []byte {
+	var e protobuf.Encoder
+	for _, l := range ts.Labels {
+		var le protobuf.Encoder
+		le.String(1, l.Name)
+		le.String(2, l.Value)
+		e.Message(1, le.Bytes())
+	}
+	for _, s := range ts.Samples {
+		var se protobuf.Encoder
+		se.Double(1, s.Value)
+		se.Varint(2, uint64(s.Timestamp))
+		e.Message(2, se.Bytes())
+	}
+	return e.Bytes()
+}
+
+// HTTPClient is the interface used for sending requests to the remote write
+// endpoint.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// ClientOption is a func that is used to configure optional settings on a
+// Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient returns a ClientOption for configuring the HTTPClient used
+// to write series.
+func WithHTTPClient(h HTTPClient) ClientOption {
+	return func(c *Client) {
+		c.httpClient = h
+	}
+}
+
+// WithMetricName returns a ClientOption for configuring the name of the
+// series.
+func WithMetricName(name string) ClientOption {
+	return func(c *Client) {
+		c.metricName = name
+	}
+}
+
+// WithLabels returns a ClientOption for configuring labels added to every
+// series, e.g. the foundation. They do not override the labels describing
+// the app instance.
+func WithLabels(labels map[string]string) ClientOption {
+	return func(c *Client) {
+		c.labels = labels
+	}
+}
+
+// WithBatchSize returns a ClientOption for configuring the maximum number of
+// series per request.
+func WithBatchSize(n int) ClientOption {
+	return func(c *Client) {
+		c.batchSize = n
+	}
+}
+
+// WithBasicAuth returns a ClientOption for authenticating with basic auth.
+func WithBasicAuth(username, password string) ClientOption {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithBearerToken returns a ClientOption for authenticating with a bearer
+// token. It takes precedence over basic auth.
+func WithBearerToken(token string) ClientOption {
+	return func(c *Client) {
+		c.bearerToken = token
+	}
+}
+
+// WithRetries returns a ClientOption for configuring how often a failed
+// request is retried, and the bounds of the exponential backoff between
+// attempts.
+func WithRetries(n int, minBackoff, maxBackoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = n
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithLogger returns a ClientOption for configuring the logger used by the
+// Client.
+func WithLogger(l *logging.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
@@ -0,0 +1,262 @@
+package remotewrite_test
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/remotewrite"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		receiver *fakeReceiver
+		server   *httptest.Server
+		points   []point.Point
+	)
+
+	BeforeEach(func() {
+		receiver = &fakeReceiver{}
+		server = httptest.NewServer(receiver)
+
+		points = []point.Point{
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     2,
+				Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: 0},
+			},
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259480,
+				Value:     3,
+				Identity:  point.Identity{Org: "org2", Space: "space2", App: "app2", AppGUID: "b", Index: 1},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("writes labelled series with snappy compressed protobuf", func() {
+		client := remotewrite.NewClient(server.URL+"/api/v1/push",
+			remotewrite.WithLabels(map[string]string{"foundation": "eu", "app": "ignored"}),
+			remotewrite.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(receiver.requests()).To(HaveLen(1))
+		req := receiver.requests()[0]
+		Expect(req.path).To(Equal("/api/v1/push"))
+		Expect(req.header.Get("Content-Type")).To(Equal("application/x-protobuf"))
+		Expect(req.header.Get("Content-Encoding")).To(Equal("snappy"))
+		Expect(req.header.Get("X-Prometheus-Remote-Write-Version")).To(Equal("0.1.0"))
+
+		series := protoFields(req.body)[1]
+		Expect(series).To(HaveLen(2))
+
+		ts := protoFields(series[0])
+		var labels [][2]string
+		for _, l := range ts[1] {
+			fields := protoFields(l)
+			labels = append(labels, [2]string{string(fields[1][0]), string(fields[2][0])})
+		}
+		Expect(labels).To(Equal([][2]string{
+			{"__name__", "cf_app_ingress"},
+			{"app", "app1"},
+			{"app_guid", "a"},
+			{"foundation", "eu"},
+			{"instance", "0"},
+			{"org", "org1"},
+			{"space", "space1"},
+		}))
+
+		sample := protoFields(ts[2][0])
+		Expect(math.Float64frombits(binary.LittleEndian.Uint64(sample[1][0]))).To(Equal(2.0))
+		Expect(binary.LittleEndian.Uint64(sample[2][0])).To(Equal(uint64(1520259480000)))
+	})
+
+	It("names app level series of other kinds after the kind without an instance label", func() {
+		client := remotewrite.NewClient(server.URL, remotewrite.WithLogger(logging.Discard()))
+
+		Expect(client.SendPoints([]point.Point{{
+			Name:      point.ZScore,
+			Timestamp: 1520259480,
+			Value:     -1.5,
+			Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: -1},
+		}})).To(Succeed())
+
+		ts := protoFields(protoFields(receiver.requests()[0].body)[1][0])
+		var labels [][2]string
+		for _, l := range ts[1] {
+			fields := protoFields(l)
+			labels = append(labels, [2]string{string(fields[1][0]), string(fields[2][0])})
+		}
+		Expect(labels).To(Equal([][2]string{
+			{"__name__", "cf_app_ingress_zscore"},
+			{"app", "app1"},
+			{"app_guid", "a"},
+			{"org", "org1"},
+			{"space", "space1"},
+		}))
+	})
+
+	It("writes series in batches", func() {
+		client := remotewrite.NewClient(server.URL,
+			remotewrite.WithBatchSize(1),
+			remotewrite.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(receiver.requests()).To(HaveLen(2))
+	})
+
+	It("authenticates with basic auth", func() {
+		client := remotewrite.NewClient(server.URL,
+			remotewrite.WithBasicAuth("user", "pass"),
+			remotewrite.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(receiver.requests()[0].header.Get("Authorization")).To(Equal("Basic dXNlcjpwYXNz"))
+	})
+
+	It("authenticates with a bearer token", func() {
+		client := remotewrite.NewClient(server.URL,
+			remotewrite.WithBearerToken("token"),
+			remotewrite.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(receiver.requests()[0].header.Get("Authorization")).To(Equal("Bearer token"))
+	})
+
+	It("retries requests failing with a 5xx or 429 status", func() {
+		receiver.statuses = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+		client := remotewrite.NewClient(server.URL,
+			remotewrite.WithRetries(3, time.Millisecond, 2*time.Millisecond),
+			remotewrite.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(receiver.requests()).To(HaveLen(3))
+	})
+
+	It("gives up after the configured number of retries", func() {
+		receiver.statuses = []int{500, 500, 500}
+		client := remotewrite.NewClient(server.URL,
+			remotewrite.WithRetries(1, time.Millisecond, time.Millisecond),
+			remotewrite.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).ToNot(Succeed())
+
+		Expect(receiver.requests()).To(HaveLen(2))
+	})
+
+	It("does not retry requests failing with another 4xx status", func() {
+		receiver.statuses = []int{http.StatusBadRequest}
+		client := remotewrite.NewClient(server.URL,
+			remotewrite.WithRetries(3, time.Millisecond, time.Millisecond),
+			remotewrite.WithLogger(logging.Discard()),
+		)
+
+		Expect(client.SendPoints(points)).ToNot(Succeed())
+
+		Expect(receiver.requests()).To(HaveLen(1))
+	})
+})
+
+// protoFields decodes the top level fields of a protobuf message. Varints
+// are returned as 8 little endian bytes, like fixed64 fields.
+func protoFields(b []byte) map[int][][]byte {
+	fields := make(map[int][][]byte)
+
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		Expect(n).To(BeNumerically(">", 0))
+		b = b[n:]
+
+		field, wireType := int(key>>3), key&7
+		switch wireType {
+		case 0:
+			v, n := binary.Uvarint(b)
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], v)
+			fields[field] = append(fields[field], buf[:])
+			b = b[n:]
+		case 1:
+			fields[field] = append(fields[field], b[:8])
+			b = b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[field] = append(fields[field], b[:length])
+			b = b[length:]
+		default:
+			Fail("unexpected wire type")
+		}
+	}
+
+	return fields
+}
+
+type request struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+type fakeReceiver struct {
+	mu        sync.Mutex
+	statuses  []int
+	_requests []request
+}
+
+func (f *fakeReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+
+	compressed, err := ioutil.ReadAll(r.Body)
+	Expect(err).ToNot(HaveOccurred())
+	body, err := snappy.Decode(nil, compressed)
+	Expect(err).ToNot(HaveOccurred())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f._requests = append(f._requests, request{
+		path:   r.URL.Path,
+		header: r.Header,
+		body:   body,
+	})
+
+	if len(f.statuses) > 0 {
+		w.WriteHeader(f.statuses[0])
+		f.statuses = f.statuses[1:]
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeReceiver) requests() []request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f._requests
+}
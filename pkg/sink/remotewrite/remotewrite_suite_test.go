@@ -0,0 +1,13 @@
+package remotewrite_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRemoteWrite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Remote Write Suite")
+}
@@ -0,0 +1,255 @@
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/logging"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Client sends points as StatsD gauges over UDP. Multiple lines are packed
+// into a datagram up to the configured MTU.
+//
+// By default each gauge is named after the dotted Graphite metric. With
+// DogStatsD tags enabled all gauges share a single name and the app instance
+// is described by org, space, app and instance tags instead.
+type Client struct {
+	addr       string
+	encoder    encoding.GraphiteEncoder
+	mtu        int
+	dogStatsD  bool
+	metricName string
+	output     io.Writer
+	logger     *logging.Logger
+
+	conn io.WriteCloser
+}
+
+// NewClient returns a Client sending to the StatsD agent at addr.
+func NewClient(addr string, opts ...ClientOption) *Client {
+	c := &Client{
+		addr:       addr,
+		mtu:        1432,
+		metricName: "noisy_neighbor.ingress",
+		logger:     logging.Default(),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// Connect opens the UDP socket.
+func (c *Client) Connect() error {
+	if c.output != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("udp", c.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+
+	return nil
+}
+
+// Disconnect closes the UDP socket.
+func (c *Client) Disconnect() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+
+	return err
+}
+
+// SendPoints sends one gauge per point, or two when dual writing dotted
+// names. Points the name template fails to render are logged and dropped.
+func (c *Client) SendPoints(points []point.Point) error {
+	var datagram bytes.Buffer
+
+	var dropped int
+	var firstErr error
+	for _, p := range points {
+		lines, err := c.lines(p)
+		if err != nil {
+			if dropped == 0 {
+				firstErr = err
+			}
+			dropped++
+			continue
+		}
+
+		for _, line := range lines {
+			if datagram.Len() > 0 && datagram.Len()+1+len(line) > c.mtu {
+				if err := c.write(datagram.Bytes()); err != nil {
+					return err
+				}
+				datagram.Reset()
+			}
+
+			if datagram.Len() > 0 {
+				datagram.WriteByte('\n')
+			}
+			datagram.WriteString(line)
+		}
+	}
+
+	if dropped > 0 {
+		c.logger.Error("failed to name points", logging.Fields{
+			"stage":   "send",
+			"dropped": dropped,
+			"error":   firstErr,
+		})
+	}
+
+	if datagram.Len() > 0 {
+		return c.write(datagram.Bytes())
+	}
+
+	return nil
+}
+
+func (c *Client) lines(p point.Point) ([]string, error) {
+	value := encoding.FormatValue(p.Value)
+	if !c.dogStatsD {
+		names, err := c.encoder.Names(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var lines []string
+		for _, name := range names {
+			lines = append(lines, fmt.Sprintf("%s:%s|g", sanitizeName(name), value))
+		}
+		return lines, nil
+	}
+
+	line := fmt.Sprintf("%s:%s|g|#org:%s,space:%s,app:%s,app_guid:%s",
+		sanitizeName(encoding.MetricName(c.metricName, ".", p.Name)),
+		value,
+		sanitizeTag(p.Identity.Org),
+		sanitizeTag(p.Identity.Space),
+		sanitizeTag(p.Identity.App),
+		sanitizeTag(p.Identity.AppGUID),
+	)
+	if !p.Identity.AppLevel() {
+		line += ",instance:" + strconv.Itoa(p.Identity.Index)
+	}
+	for _, k := range encoding.SortedKeys(p.Labels) {
+		line += "," + sanitizeTag(k) + ":" + sanitizeTag(p.Labels[k])
+	}
+
+	return []string{line}, nil
+}
+
+func (c *Client) write(datagram []byte) error {
+	if c.output != nil {
+		_, err := fmt.Fprintf(c.output, "%s\n", datagram)
+		return err
+	}
+
+	if c.conn == nil {
+		return fmt.Errorf("not connected to statsd at %s", c.addr)
+	}
+
+	_, err := c.conn.Write(datagram)
+	return err
+}
+
+// sanitizeName replaces the characters that delimit the fields of a StatsD
+// line.
+var sanitizeName = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_", " ", "_").Replace
+
+// sanitizeTag replaces the characters that delimit DogStatsD tags.
+var sanitizeTag = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_", " ", "_").Replace
+
+// ClientOption is a func that is used to configure optional settings on a
+// Client.
+type ClientOption func(*Client)
+
+// WithPrefix returns a ClientOption for configuring the prefix of the gauge
+// names when DogStatsD tags are disabled.
+func WithPrefix(prefix string) ClientOption {
+	return func(c *Client) {
+		c.encoder.Prefix = prefix
+	}
+}
+
+// WithSchema returns a ClientOption for configuring the layout of the
+// dotted gauge names. It defaults to the legacy layout.
+func WithSchema(schema encoding.GraphiteSchema) ClientOption {
+	return func(c *Client) {
+		c.encoder.Schema = schema
+	}
+}
+
+// WithDualWrite returns a ClientOption for sending every gauge under both
+// the legacy and the v2 dotted name.
+func WithDualWrite() ClientOption {
+	return func(c *Client) {
+		c.encoder.DualWrite = true
+	}
+}
+
+// WithMTU returns a ClientOption for configuring the maximum size of a
+// datagram. A single line larger than the MTU is sent on its own.
+func WithMTU(mtu int) ClientOption {
+	return func(c *Client) {
+		c.mtu = mtu
+	}
+}
+
+// WithDogStatsDTags returns a ClientOption for tagging every gauge with the
+// org, space, app and instance it belongs to, using the DogStatsD tag
+// extension. All gauges are then named metricName.
+func WithDogStatsDTags(metricName string) ClientOption {
+	return func(c *Client) {
+		c.dogStatsD = true
+		c.metricName = metricName
+	}
+}
+
+// WithOutput returns a ClientOption for writing every datagram, followed by
+// a newline, to w instead of sending it.
+func WithOutput(w io.Writer) ClientOption {
+	return func(c *Client) {
+		c.output = w
+	}
+}
+
+// WithLogger returns a ClientOption for configuring the logger used by the
+// Client.
+func WithLogger(l *logging.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithNameTemplate returns a ClientOption for rendering the dotted gauge names
+// with a template instead of a schema.
+func WithNameTemplate(t *encoding.NameTemplate) ClientOption {
+	return func(c *Client) {
+		c.encoder.Template = t
+	}
+}
+
+// WithGUIDKeys returns a ClientOption for keying the dotted gauge names on
+// the app GUID instead of the org, space and app names.
+func WithGUIDKeys() ClientOption {
+	return func(c *Client) {
+		c.encoder.GUIDKeys = true
+	}
+}
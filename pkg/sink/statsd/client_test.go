@@ -0,0 +1,141 @@
+package statsd_test
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/encoding"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink/statsd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		agent  net.PacketConn
+		points []point.Point
+	)
+
+	BeforeEach(func() {
+		var err error
+		agent, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		points = []point.Point{
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259517,
+				Value:     2,
+				Identity:  point.Identity{Org: "org1", Space: "space1", App: "app1", AppGUID: "a", Index: 0},
+			},
+			{
+				Name:      point.Ingress,
+				Timestamp: 1520259517,
+				Value:     3,
+				Identity:  point.Identity{Org: "org2", Space: "space 2", App: "app2", AppGUID: "b", Index: 1},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		agent.Close()
+	})
+
+	It("sends plain StatsD gauges named after the graphite metric", func() {
+		client := statsd.NewClient(agent.LocalAddr().String(), statsd.WithPrefix("test"))
+		Expect(client.Connect()).To(Succeed())
+		defer client.Disconnect()
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(readDatagram(agent)).To(Equal(
+			"test.org1.space1.app1.0:2|g\ntest.org2.space_2.app2.1:3|g",
+		))
+	})
+
+	It("sends gauges under the v1 and v2 names when dual writing", func() {
+		client := statsd.NewClient(agent.LocalAddr().String(),
+			statsd.WithPrefix("test"),
+			statsd.WithSchema(encoding.GraphiteSchemaV2),
+			statsd.WithDualWrite(),
+		)
+		Expect(client.Connect()).To(Succeed())
+		defer client.Disconnect()
+
+		Expect(client.SendPoints(points[:1])).To(Succeed())
+
+		Expect(readDatagram(agent)).To(Equal(
+			"test.org1.space1.app1.0:2|g\ntest.v2.org1.space1.app1.instances.0.ingress:2|g",
+		))
+	})
+
+	It("tags gauges using the DogStatsD extension", func() {
+		client := statsd.NewClient(agent.LocalAddr().String(),
+			statsd.WithDogStatsDTags("noisy_neighbor.ingress"),
+		)
+		Expect(client.Connect()).To(Succeed())
+		defer client.Disconnect()
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(readDatagram(agent)).To(Equal(
+			"noisy_neighbor.ingress:2|g|#org:org1,space:space1,app:app1,app_guid:a,instance:0\n" +
+				"noisy_neighbor.ingress:3|g|#org:org2,space:space_2,app:app2,app_guid:b,instance:1",
+		))
+	})
+
+	It("adds the labels of the points as tags", func() {
+		buf := &bytes.Buffer{}
+		client := statsd.NewClient(agent.LocalAddr().String(),
+			statsd.WithDogStatsDTags("noisy_neighbor.ingress"),
+			statsd.WithOutput(buf),
+		)
+		points[0].Labels = map[string]string{"foundation": "eu"}
+
+		Expect(client.SendPoints(points[:1])).To(Succeed())
+
+		Expect(buf.String()).To(Equal(
+			"noisy_neighbor.ingress:2|g|#org:org1,space:space1,app:app1,app_guid:a,instance:0,foundation:eu\n",
+		))
+	})
+
+	It("packs lines into datagrams up to the MTU", func() {
+		client := statsd.NewClient(agent.LocalAddr().String(),
+			statsd.WithPrefix("test"),
+			statsd.WithMTU(40),
+		)
+		Expect(client.Connect()).To(Succeed())
+		defer client.Disconnect()
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(readDatagram(agent)).To(Equal("test.org1.space1.app1.0:2|g"))
+		Expect(readDatagram(agent)).To(Equal("test.org2.space_2.app2.1:3|g"))
+	})
+
+	It("writes datagrams to the output instead of sending them", func() {
+		buf := &bytes.Buffer{}
+		client := statsd.NewClient(agent.LocalAddr().String(),
+			statsd.WithPrefix("test"),
+			statsd.WithOutput(buf),
+		)
+		Expect(client.Connect()).To(Succeed())
+
+		Expect(client.SendPoints(points)).To(Succeed())
+
+		Expect(buf.String()).To(Equal(
+			"test.org1.space1.app1.0:2|g\ntest.org2.space_2.app2.1:3|g\n",
+		))
+	})
+})
+
+func readDatagram(conn net.PacketConn) string {
+	buf := make([]byte, 65536)
+	Expect(conn.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+	n, _, err := conn.ReadFrom(buf)
+	Expect(err).ToNot(HaveOccurred())
+
+	return string(buf[:n])
+}
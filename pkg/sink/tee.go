@@ -0,0 +1,108 @@
+package sink
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+)
+
+// Client is the interface satisfied by all sinks. It matches the Client
+// interface of the reporter.
+type Client interface {
+	SendPoints([]point.Point) error
+	Connect() error
+	Disconnect() error
+}
+
+// Tee sends the same points to several clients one after the other. A client
+// that fails to connect is skipped for the current report and its error is
+// returned by SendPoints, so that it does not keep the other clients from
+// receiving the points.
+type Tee struct {
+	clients    []Client
+	connectErr []error
+}
+
+// NewTee returns a Tee sending to the given clients.
+func NewTee(clients ...Client) *Tee {
+	return &Tee{
+		clients:    clients,
+		connectErr: make([]error, len(clients)),
+	}
+}
+
+// Connect connects all clients. It only fails when none of them could be
+// connected.
+func (t *Tee) Connect() error {
+	var errs []string
+	for i, c := range t.clients {
+		t.connectErr[i] = c.Connect()
+		if t.connectErr[i] != nil {
+			errs = append(errs, t.connectErr[i].Error())
+		}
+	}
+
+	if len(errs) < len(t.clients) {
+		return nil
+	}
+
+	return joinErrors(errs)
+}
+
+// Disconnect disconnects all connected clients.
+func (t *Tee) Disconnect() error {
+	var errs []string
+	for i, c := range t.clients {
+		if t.connectErr[i] != nil {
+			continue
+		}
+		if err := c.Disconnect(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// SendPoints sends the points to all connected clients. It fails if any
+// client failed to connect or send.
+func (t *Tee) SendPoints(points []point.Point) error {
+	var errs []string
+	for i, c := range t.clients {
+		if t.connectErr[i] != nil {
+			errs = append(errs, t.connectErr[i].Error())
+			continue
+		}
+		if err := c.SendPoints(points); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// Close closes the clients that deliver in the background or buffer
+// points, so that their errors are returned and nothing is lost.
+func (t *Tee) Close() error {
+	var errs []string
+	for _, c := range t.clients {
+		closer, ok := c.(interface{ Close() error })
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+func joinErrors(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,98 @@
+package sink_test
+
+import (
+	"errors"
+
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/point"
+	"github.com/SpringerPE/noisy-neighbor-reporters/pkg/sink"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tee", func() {
+	var points = []point.Point{{Name: point.Ingress, Timestamp: 1, Value: 1}}
+
+	It("sends the points to all clients", func() {
+		a, b := &spyClient{}, &spyClient{}
+		tee := sink.NewTee(a, b)
+
+		Expect(tee.Connect()).To(Succeed())
+		Expect(tee.SendPoints(points)).To(Succeed())
+		Expect(tee.Disconnect()).To(Succeed())
+
+		Expect(a.sent).To(Equal(points))
+		Expect(b.sent).To(Equal(points))
+		Expect(a.disconnected).To(BeTrue())
+		Expect(b.disconnected).To(BeTrue())
+	})
+
+	It("keeps sending to the other clients when one fails to connect", func() {
+		a, b := &spyClient{connectErr: errors.New("refused")}, &spyClient{}
+		tee := sink.NewTee(a, b)
+
+		Expect(tee.Connect()).To(Succeed())
+		Expect(tee.SendPoints(points)).To(MatchError("refused"))
+		Expect(tee.Disconnect()).To(Succeed())
+
+		Expect(a.sent).To(BeNil())
+		Expect(a.disconnected).To(BeFalse())
+		Expect(b.sent).To(Equal(points))
+	})
+
+	It("fails to connect when no client connects", func() {
+		tee := sink.NewTee(&spyClient{connectErr: errors.New("refused")})
+
+		Expect(tee.Connect()).To(MatchError("refused"))
+	})
+
+	It("returns the errors of all failing clients", func() {
+		tee := sink.NewTee(
+			&spyClient{sendErr: errors.New("a failed")},
+			&spyClient{},
+			&spyClient{sendErr: errors.New("c failed")},
+		)
+		Expect(tee.Connect()).To(Succeed())
+
+		Expect(tee.SendPoints(points)).To(MatchError("a failed; c failed"))
+	})
+
+	It("closes the clients that can be closed", func() {
+		closer := &spyClosingClient{closeErr: errors.New("flush failed")}
+		tee := sink.NewTee(&spyClient{}, closer)
+
+		Expect(tee.Close()).To(MatchError("flush failed"))
+		Expect(closer.closed).To(BeTrue())
+	})
+})
+
+type spyClosingClient struct {
+	spyClient
+	closeErr error
+	closed   bool
+}
+
+func (s *spyClosingClient) Close() error {
+	s.closed = true
+	return s.closeErr
+}
+
+type spyClient struct {
+	connectErr   error
+	sendErr      error
+	sent         []point.Point
+	disconnected bool
+}
+
+func (s *spyClient) Connect() error {
+	return s.connectErr
+}
+
+func (s *spyClient) Disconnect() error {
+	s.disconnected = true
+	return nil
+}
+
+func (s *spyClient) SendPoints(points []point.Point) error {
+	s.sent = points
+	return s.sendErr
+}